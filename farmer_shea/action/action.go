@@ -0,0 +1,164 @@
+package action
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+)
+
+// Kind is the type of an action.
+type Kind string
+
+const (
+	Deposit       Kind = "deposit"
+	Withdraw      Kind = "withdraw"
+	Stake         Kind = "stake"
+	MintLP        Kind = "mint_lp"
+	Swap          Kind = "swap"
+	PlaceOrder    Kind = "place_order"
+	VaultDeposit  Kind = "vault_deposit"
+	VaultWithdraw Kind = "vault_withdraw"
+)
+
+// Params describing the second token of a two-sided action, e.g. an LP
+// mint. Positions opened by such actions keep them in their details.
+const (
+	ParamTokenB    = "token_b"
+	ParamAmountB   = "amount_b"
+	ParamDecimalsB = "decimals_b"
+)
+
+// Action is a single on-chain operation a strategy wants to perform. Strategies
+// emit actions from Plan; the executor validates, checks and submits them.
+type Action struct {
+	Kind  Kind
+	Chain chain.ID
+	// Strategy is the name of the strategy that planned the action. The
+	// executor fills it in.
+	Strategy string
+	// Wallet is the name of the wallet whose keys sign the action. The
+	// executor fills it in.
+	Wallet string
+	// Protocol is the protocol the action interacts with, e.g. "solend".
+	Protocol string
+	// Asset identifies the asset moved by the action: a mint, a token
+	// address or a symbol.
+	Asset string
+	// Amount is in the asset's smallest unit; Decimals converts it to whole
+	// units.
+	Amount   *big.Int
+	Decimals uint8
+	// Target is the reserve, vault, pool or market the action applies to.
+	Target string
+	// Rationale explains why the strategy chose this action.
+	Rationale string
+	// Params holds kind-specific details, e.g. the second token of an LP.
+	Params map[string]string
+}
+
+// Validate checks that the action is well formed.
+func (a Action) Validate() error {
+	var problems []string
+	if a.Kind == "" {
+		problems = append(problems, "missing kind")
+	}
+	if !a.Chain.Valid() {
+		problems = append(problems, fmt.Sprintf("unknown chain %q", a.Chain))
+	}
+	if a.Asset == "" {
+		problems = append(problems, "missing asset")
+	}
+	if a.Amount == nil || a.Amount.Sign() <= 0 {
+		problems = append(problems, "amount must be positive")
+	}
+	if _, err := a.Legs(); err != nil {
+		problems = append(problems, "malformed second token")
+	}
+	if len(problems) > 0 {
+		return errkind.Wrapf(errkind.Permanent, "invalid %s action: %s", a.Kind, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Units returns the amount in whole units of the asset.
+func (a Action) Units() float64 {
+	if a.Amount == nil {
+		return 0
+	}
+	f, _ := new(big.Rat).SetFrac(a.Amount, pow10(a.Decimals)).Float64()
+	return f
+}
+
+// Legs returns each asset the action moves as an action of its own: a
+// itself and, if it has a ParamTokenB, its second token.
+func (a Action) Legs() ([]Action, error) {
+	token, ok := a.Params[ParamTokenB]
+	if !ok {
+		return []Action{a}, nil
+	}
+	amount, okAmount := new(big.Int).SetString(a.Params[ParamAmountB], 10)
+	decimals, err := strconv.ParseUint(a.Params[ParamDecimalsB], 10, 8)
+	if token == "" || !okAmount || amount.Sign() < 0 || err != nil {
+		return nil, errkind.Wrapf(errkind.Permanent, "malformed second token of %s action: %v", a.Kind, a.Params)
+	}
+	b := a
+	b.Asset, b.Amount, b.Decimals, b.Params = token, amount, uint8(decimals), nil
+	return []Action{a, b}, nil
+}
+
+func (a Action) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s on %s", a.Kind, FormatAmount(a.Amount, a.Decimals), a.Asset, a.Chain)
+	if a.Protocol != "" {
+		fmt.Fprintf(&b, " via %s", a.Protocol)
+	}
+	if a.Target != "" {
+		fmt.Fprintf(&b, " (%s)", a.Target)
+	}
+
+	keys := make([]string, 0, len(a.Params))
+	for k := range a.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, a.Params[k])
+	}
+	return b.String()
+}
+
+// ParseAmount parses a decimal amount in whole units, e.g. "0.05", into the
+// asset's smallest unit.
+func ParseAmount(s string, decimals uint8) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(decimals)))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// FormatAmount formats an amount in the asset's smallest unit as a decimal
+// amount in whole units.
+func FormatAmount(amount *big.Int, decimals uint8) string {
+	if amount == nil {
+		return "0"
+	}
+	s := new(big.Rat).SetFrac(amount, pow10(decimals)).FloatString(int(decimals))
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func pow10(n uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
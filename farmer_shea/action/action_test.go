@@ -0,0 +1,55 @@
+package action
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+func TestLegs(t *testing.T) {
+	a := Action{
+		Kind:     MintLP,
+		Chain:    chain.Base,
+		Asset:    "0x4200000000000000000000000000000000000006",
+		Amount:   big.NewInt(50_000_000_000_000_000),
+		Decimals: 18,
+		Params: map[string]string{
+			ParamTokenB:    "0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913",
+			ParamAmountB:   "100000000",
+			ParamDecimalsB: "6",
+			"fee":          "500",
+		},
+	}
+	legs, err := a.Legs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(legs) != 2 || legs[0].Asset != a.Asset || legs[0].Units() != 0.05 {
+		t.Fatalf("Legs() = %v, want the action and its second token", legs)
+	}
+	if b := legs[1]; b.Asset != a.Params[ParamTokenB] || b.Units() != 100 || b.Chain != chain.Base || b.Kind != MintLP {
+		t.Errorf("second leg = %v, want 100 of token B on base", b)
+	}
+
+	single := Action{Kind: Deposit, Asset: "USDC", Amount: big.NewInt(1)}
+	if legs, err := single.Legs(); err != nil || len(legs) != 1 {
+		t.Errorf("Legs() of a one-sided action = %v, %v", legs, err)
+	}
+
+	for _, params := range []map[string]string{
+		{ParamTokenB: "0x1", ParamAmountB: "x", ParamDecimalsB: "6"},
+		{ParamTokenB: "0x1", ParamAmountB: "1", ParamDecimalsB: "256"},
+		{ParamTokenB: "0x1", ParamAmountB: "-1", ParamDecimalsB: "6"},
+		{ParamTokenB: "", ParamAmountB: "1", ParamDecimalsB: "6"},
+	} {
+		a.Params = params
+		if _, err := a.Legs(); err == nil {
+			t.Errorf("Legs() with params %v succeeded, want an error", params)
+		}
+		a.Chain = chain.Base
+		if err := a.Validate(); err == nil {
+			t.Errorf("Validate() with params %v succeeded, want an error", params)
+		}
+	}
+}
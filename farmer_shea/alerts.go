@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sheawinkler/farmer-shea/config"
+	"github.com/sheawinkler/farmer-shea/notify"
+	"github.com/sheawinkler/farmer-shea/schedule"
+)
+
+// buildNotifier creates a notifier sending through the configured alert
+// channels.
+func buildNotifier(cfg config.AlertsConfig) (*notify.Notifier, error) {
+	routes := make([]notify.Route, 0, len(cfg.Channels))
+	for _, ac := range cfg.Channels {
+		channel, err := buildChannel(ac)
+		if err != nil {
+			return nil, fmt.Errorf("alert channel %s: %w", ac.Name, err)
+		}
+		// The config has been validated, so the severity is known.
+		severity, _ := notify.ParseSeverity(ac.MinSeverity)
+		routes = append(routes, notify.Route{
+			Name:        ac.Name,
+			Channel:     channel,
+			MinSeverity: severity,
+			RateLimit:   ac.RateLimit,
+			RateWindow:  ac.RateWindow,
+			DedupWindow: ac.DedupWindow,
+			Digest:      ac.Digest,
+		})
+	}
+
+	n := notify.New(routes...)
+	n.FailureThreshold = cfg.FailureThreshold
+	if cfg.Digest != "" {
+		digest, err := schedule.Parse(cfg.Digest)
+		if err != nil {
+			return nil, err
+		}
+		n.Digest = digest
+	}
+	return n, nil
+}
+
+// buildChannel creates the channel an alert channel config describes,
+// reading its secrets.
+func buildChannel(ac config.AlertChannelConfig) (notify.Channel, error) {
+	switch ac.Type {
+	case "webhook":
+		return &notify.Webhook{URL: ac.URL}, nil
+	case "slack":
+		return &notify.Slack{URL: ac.URL}, nil
+	case "telegram":
+		token, err := readSecret(ac.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		return &notify.Telegram{APIURL: ac.URL, Token: token, ChatID: ac.ChatID}, nil
+	case "email":
+		var password string
+		if ac.PasswordFile != "" {
+			var err error
+			if password, err = readSecret(ac.PasswordFile); err != nil {
+				return nil, err
+			}
+		}
+		return &notify.Email{Addr: ac.SMTPAddr, From: ac.From, To: ac.To, Username: ac.Username, Password: password}, nil
+	}
+	return nil, fmt.Errorf("unknown channel type %q", ac.Type)
+}
+
+// readSecret reads a secret from a file, without surrounding whitespace.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
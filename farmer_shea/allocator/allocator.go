@@ -0,0 +1,374 @@
+package allocator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/strategy"
+)
+
+// DefaultInterval is how often capital is reallocated when no interval is
+// set.
+const DefaultInterval = time.Hour
+
+// Policy decides the weights capital is allocated by.
+type Policy string
+
+const (
+	// Fixed allocates capital by configured target weights.
+	Fixed Policy = "fixed"
+	// MaxYield allocates capital in proportion to the risk-adjusted APY
+	// each strategy reports.
+	MaxYield Policy = "yield"
+)
+
+// Config controls how capital is allocated.
+type Config struct {
+	// Capital is the total capital to allocate, in USD.
+	Capital float64
+	Policy  Policy
+	// Weights are the target shares of Capital by strategy name, under the
+	// Fixed policy. Names are matched case-insensitively.
+	Weights map[string]float64
+	// MaxWeight caps the share of Capital any strategy gets under the
+	// MaxYield policy. Zero means no cap.
+	MaxWeight float64
+	// Threshold is the drift from its target, as a share of Capital, past
+	// which a strategy is rebalanced.
+	Threshold float64
+}
+
+// Prices values the positions strategies hold, e.g. *portfolio.Tracker.
+type Prices interface {
+	Price(ctx context.Context, c chain.ID, asset string) (float64, error)
+}
+
+// Target is how much a strategy should hold, and how much it does.
+type Target struct {
+	Strategy string   `json:"strategy"`
+	Chain    chain.ID `json:"chain,omitempty"`
+	Weight   float64  `json:"weight"`
+	Target   float64  `json:"target_usd"`
+	Held     float64  `json:"held_usd"`
+	// APY and Risk are the strategy's reported yield, under the MaxYield
+	// policy.
+	APY  float64 `json:"apy,omitempty"`
+	Risk float64 `json:"risk,omitempty"`
+	// Error explains why the strategy's holdings could not be valued. Such
+	// strategies are not rebalanced.
+	Error string `json:"error,omitempty"`
+}
+
+// Drift is how much more the strategy holds than its target, in USD.
+func (t Target) Drift() float64 {
+	return t.Held - t.Target
+}
+
+// Move is a rebalance instruction: withdraw USD worth of capital from one
+// strategy and deposit it into another on the same chain.
+type Move struct {
+	Chain chain.ID `json:"chain"`
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	USD   float64  `json:"usd"`
+}
+
+// Plan is an allocation of capital and the moves that would restore it.
+type Plan struct {
+	Time    time.Time `json:"time"`
+	Capital float64   `json:"capital_usd"`
+	Policy  Policy    `json:"policy"`
+	Targets []Target  `json:"targets"`
+	// Moves is empty unless some strategy drifted past the threshold.
+	Moves []Move `json:"moves,omitempty"`
+}
+
+// Allocator computes how much capital each strategy should hold and the
+// moves that bring them there. It only plans moves; strategies keep
+// sizing their own deposits. It is safe for concurrent use.
+type Allocator struct {
+	// Events, if set, receives a Rebalance event for each move planned by
+	// Run.
+	Events *events.Bus
+	// Interval is how often Run reallocates capital.
+	Interval time.Duration
+
+	config     Config
+	strategies *strategy.Manager
+	store      *store.Store
+	prices     Prices
+
+	mu   sync.Mutex
+	last *Plan
+}
+
+// New creates an Allocator for the strategies in m. Their holdings are the
+// positions recorded in st, valued at prices from p.
+func New(config Config, m *strategy.Manager, st *store.Store, p Prices) *Allocator {
+	return &Allocator{
+		Interval:   DefaultInterval,
+		config:     config,
+		strategies: m,
+		store:      st,
+		prices:     p,
+	}
+}
+
+// Run plans an allocation every Interval until ctx is cancelled, logging
+// and publishing the moves of each plan.
+func (a *Allocator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		plan, err := a.Plan(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to allocate capital")
+		}
+		for _, m := range plan.Moves {
+			log.Warn().Str("chain", string(m.Chain)).Str("from", m.From).Str("to", m.To).Float64("usd", m.USD).Msg("Strategy drifted from its allocation; rebalance needed")
+			if a.Events != nil {
+				a.Events.Publish(&events.Rebalance{Meta: events.Meta{Strategy: m.From}, Chain: m.Chain, To: m.To, USD: m.USD})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the latest plan made by Plan, if any.
+func (a *Allocator) Last() (Plan, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.last == nil {
+		return Plan{}, false
+	}
+	return *a.last, true
+}
+
+// Plan allocates capital to the strategies by the policy, values what each
+// holds and, if any has drifted past the threshold, plans the moves that
+// restore the allocation.
+func (a *Allocator) Plan(ctx context.Context) (Plan, error) {
+	plan := Plan{Time: time.Now(), Capital: a.config.Capital, Policy: a.config.Policy}
+
+	var err error
+	switch a.config.Policy {
+	case Fixed:
+		plan.Targets = a.fixed()
+	case MaxYield:
+		plan.Targets = a.maxYield(ctx)
+	default:
+		err = fmt.Errorf("unknown allocation policy %q", a.config.Policy)
+	}
+	if err != nil {
+		return plan, err
+	}
+
+	drifted := false
+	for i := range plan.Targets {
+		t := &plan.Targets[i]
+		t.Target = t.Weight * a.config.Capital
+		held, err := a.held(ctx, t.Strategy)
+		if err != nil {
+			t.Error = err.Error()
+			continue
+		}
+		t.Held = held
+		if math.Abs(t.Drift()) > a.config.Threshold*a.config.Capital {
+			drifted = true
+		}
+	}
+	if drifted {
+		plan.Moves = moves(plan.Targets)
+	}
+
+	a.mu.Lock()
+	a.last = &plan
+	a.mu.Unlock()
+	return plan, nil
+}
+
+// fixed targets the strategies that have a configured weight.
+func (a *Allocator) fixed() []Target {
+	var targets []Target
+	for _, s := range a.strategies.Strategies() {
+		for name, w := range a.config.Weights {
+			if strings.EqualFold(name, s.Name()) {
+				targets = append(targets, Target{Strategy: s.Name(), Chain: chainOf(s), Weight: w})
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// maxYield targets the strategies that report their yield, weighting each
+// by its APY discounted by its risk. Strategies whose yield cannot be read
+// are left out.
+func (a *Allocator) maxYield(ctx context.Context) []Target {
+	var (
+		targets []Target
+		scores  []float64
+	)
+	for _, s := range a.strategies.Strategies() {
+		y, ok := s.(strategy.Yielder)
+		if !ok {
+			continue
+		}
+		yield, err := y.Yield(ctx)
+		if err != nil {
+			log.Warn().Err(err).Str("strategy", s.Name()).Msg("Failed to read strategy yield; leaving it out of the allocation")
+			continue
+		}
+		targets = append(targets, Target{Strategy: s.Name(), Chain: chainOf(s), APY: yield.APY, Risk: yield.Risk})
+		scores = append(scores, max(yield.APY*(1-yield.Risk), 0))
+	}
+	for i, w := range capWeights(scores, a.config.MaxWeight) {
+		targets[i].Weight = w
+	}
+	return targets
+}
+
+// capWeights normalizes scores into weights summing to 1, none above
+// limit. The excess of capped weights is shared among the rest in
+// proportion to their scores; if every weight is capped, the remainder is
+// left unallocated.
+func capWeights(scores []float64, limit float64) []float64 {
+	weights := make([]float64, len(scores))
+	capped := make([]bool, len(scores))
+	remaining := 1.0
+	for {
+		total := 0.0
+		for i, s := range scores {
+			if !capped[i] {
+				total += s
+			}
+		}
+		if total <= 0 {
+			return weights
+		}
+		done := true
+		for i, s := range scores {
+			if capped[i] {
+				continue
+			}
+			weights[i] = remaining * s / total
+			if limit > 0 && weights[i] > limit {
+				done = false
+			}
+		}
+		if done {
+			return weights
+		}
+		for i := range scores {
+			if !capped[i] && weights[i] > limit {
+				weights[i] = limit
+				capped[i] = true
+				remaining -= limit
+			}
+		}
+	}
+}
+
+// held returns the value in USD of the open positions of the named
+// strategy.
+func (a *Allocator) held(ctx context.Context, name string) (float64, error) {
+	if a.store == nil {
+		return 0, nil
+	}
+	positions, err := a.store.Positions(name)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read open positions: %w", err)
+	}
+	var value float64
+	for _, p := range positions {
+		amount, ok := new(big.Int).SetString(p.Amount, 10)
+		if !ok {
+			return 0, fmt.Errorf("position %s has malformed amount %q", p.ID, p.Amount)
+		}
+		// An LP position keeps its second token in its details.
+		legs, err := action.Action{Chain: p.Chain, Asset: p.Asset, Amount: amount, Decimals: p.Decimals, Params: p.Details}.Legs()
+		if err != nil {
+			return 0, fmt.Errorf("cannot value position %s: %w", p.ID, err)
+		}
+		for _, leg := range legs {
+			price, err := a.prices.Price(ctx, leg.Chain, leg.Asset)
+			if err != nil {
+				return 0, fmt.Errorf("cannot value position %s: %w", p.ID, err)
+			}
+			value += leg.Units() * price
+		}
+	}
+	return value, nil
+}
+
+// moves pairs strategies holding more than their target with those
+// holding less on the same chain, largest drifts first. Strategies whose
+// holdings or chain are unknown are not moved.
+func moves(targets []Target) []Move {
+	byChain := make(map[chain.ID][]Target)
+	for _, t := range targets {
+		if t.Error == "" && t.Chain != "" {
+			byChain[t.Chain] = append(byChain[t.Chain], t)
+		}
+	}
+	chains := make([]chain.ID, 0, len(byChain))
+	for c := range byChain {
+		chains = append(chains, c)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i] < chains[j] })
+
+	var moves []Move
+	for _, c := range chains {
+		ts := byChain[c]
+		sort.SliceStable(ts, func(i, j int) bool { return ts[i].Drift() > ts[j].Drift() })
+		excess := make([]float64, len(ts))
+		for i, t := range ts {
+			excess[i] = t.Drift()
+		}
+		// Over-allocated strategies are at the front and under-allocated
+		// ones, most in need first, at the back.
+		from, to := 0, len(ts)-1
+		for from < to && excess[from] > 0 && excess[to] < 0 {
+			usd := min(excess[from], -excess[to])
+			moves = append(moves, Move{Chain: c, From: ts[from].Strategy, To: ts[to].Strategy, USD: usd})
+			excess[from] -= usd
+			excess[to] += usd
+			if excess[from] <= 0 {
+				from++
+			}
+			if excess[to] >= 0 {
+				to--
+			}
+		}
+	}
+	return moves
+}
+
+// chainOf returns the chain s holds its capital on, or "" if it does not
+// say.
+func chainOf(s strategy.Strategy) chain.ID {
+	if c, ok := s.(strategy.OnChain); ok {
+		return c.Chain()
+	}
+	return ""
+}
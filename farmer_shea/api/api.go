@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/executor"
+	"github.com/sheawinkler/farmer-shea/service"
+)
+
+// TokenEnv is the environment variable the API token is read from when no
+// token file is configured.
+const TokenEnv = "FARMER_SHEA_API_TOKEN"
+
+const (
+	defaultTxLimit = 50
+	maxTxLimit     = 1000
+	// maxBody bounds the size of request bodies.
+	maxBody = 1 << 16
+)
+
+// ReadToken returns the API token. It is read from file if one is given,
+// and otherwise from TokenEnv.
+func ReadToken(file string) (string, error) {
+	token := os.Getenv(TokenEnv)
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read API token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return "", fmt.Errorf("no API token: set %s or api.token_file", TokenEnv)
+	}
+	return token, nil
+}
+
+// Server serves the HTTP/JSON control API. Every request must carry the
+// token as "Authorization: Bearer TOKEN".
+//
+//	GET    /v1/portfolio                   latest portfolio snapshot
+//	GET    /v1/pnl                         PnL per strategy and asset
+//	GET    /v1/strategies                  status of every strategy
+//	GET    /v1/strategies/{name}           status and recent actions of one
+//	POST   /v1/strategies/{name}/{control} pause, resume, trigger or disable
+//	GET    /v1/transactions?limit=N        latest transactions, newest first
+//	GET    /v1/kill-switch                 state of the kill switch
+//	POST   /v1/kill-switch                 halt every strategy; {"reason", "unwind"}
+//	DELETE /v1/kill-switch                 lift the kill switch
+//	GET    /v1/allocation                  capital allocation and rebalance moves
+//	GET    /v1/yields                      yield opportunities, best net APY first
+type Server struct {
+	svc   *service.Service
+	token string
+}
+
+// NewServer creates a Server for svc that accepts token.
+func NewServer(svc *service.Service, token string) *Server {
+	return &Server{svc: svc, token: token}
+}
+
+// Handler returns the API's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/portfolio", s.portfolio)
+	mux.HandleFunc("GET /v1/pnl", s.pnl)
+	mux.HandleFunc("GET /v1/strategies", s.strategies)
+	mux.HandleFunc("GET /v1/strategies/{name}", s.strategy)
+	mux.HandleFunc("POST /v1/strategies/{name}/{control}", s.control)
+	mux.HandleFunc("GET /v1/transactions", s.transactions)
+	mux.HandleFunc("GET /v1/kill-switch", s.killSwitch)
+	mux.HandleFunc("POST /v1/kill-switch", s.halt)
+	mux.HandleFunc("DELETE /v1/kill-switch", s.lift)
+	mux.HandleFunc("GET /v1/allocation", s.allocation)
+	mux.HandleFunc("GET /v1/yields", s.yields)
+	return s.authenticate(mux)
+}
+
+// ListenAndServe serves the API on addr, a loopback host:port or
+// unix:PATH, until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+		// Remove the socket left by a previous run.
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	if network == "unix" {
+		if err := os.Chmod(address, 0o600); err != nil {
+			l.Close()
+			return fmt.Errorf("failed to restrict socket permissions: %w", err)
+		}
+	}
+
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("addr", addr).Msg("Serving API")
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// authenticate rejects requests without the token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) portfolio(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, newPortfolio(s.svc.Portfolio()))
+}
+
+func (s *Server) pnl(w http.ResponseWriter, r *http.Request) {
+	report, err := s.svc.PnL()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPnL(report))
+}
+
+func (s *Server) strategies(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.svc.Strategies())
+}
+
+func (s *Server) strategy(w http.ResponseWriter, r *http.Request) {
+	st, ok := s.svc.Strategy(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w %q", executor.ErrUnknownStrategy, r.PathValue("name")))
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) control(w http.ResponseWriter, r *http.Request) {
+	controls := map[string]func(string) error{
+		"pause":   s.svc.Pause,
+		"resume":  s.svc.Resume,
+		"trigger": s.svc.Trigger,
+		"disable": s.svc.Disable,
+	}
+	fn, ok := controls[r.PathValue("control")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown control %q: use pause, resume, trigger or disable", r.PathValue("control")))
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := fn(name); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, executor.ErrUnknownStrategy):
+			status = http.StatusNotFound
+		case errors.Is(err, executor.ErrHalted):
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+	log.Info().Str("strategy", name).Str("control", r.PathValue("control")).Msg("Strategy controlled through the API")
+
+	st, _ := s.svc.Strategy(name)
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) transactions(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTxLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxTxLimit))
+			return
+		}
+		limit = n
+	}
+	txs, err := s.svc.Transactions(limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, txs)
+}
+
+func (s *Server) killSwitch(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.svc.KillSwitch())
+}
+
+func (s *Server) halt(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+		Unwind bool   `json:"unwind"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	if err := s.svc.Halt(req.Reason, req.Unwind); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, executor.ErrHalted) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+	log.Warn().Str("reason", req.Reason).Bool("unwind", req.Unwind).Msg("Kill switch engaged through the API")
+	writeJSON(w, http.StatusOK, s.svc.KillSwitch())
+}
+
+func (s *Server) lift(w http.ResponseWriter, r *http.Request) {
+	if err := s.svc.Lift(); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	log.Warn().Msg("Kill switch lifted through the API")
+	writeJSON(w, http.StatusOK, s.svc.KillSwitch())
+}
+
+func (s *Server) allocation(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.svc.Allocation()
+	switch {
+	case errors.Is(err, service.ErrNoAllocation):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, plan)
+	}
+}
+
+func (s *Server) yields(w http.ResponseWriter, r *http.Request) {
+	ranked, err := s.svc.Yields(r.Context())
+	if len(ranked) == 0 && err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newYields(ranked, err))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug().Err(err).Msg("Failed to write API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/portfolio"
+	"github.com/sheawinkler/farmer-shea/yield"
+)
+
+// The JSON views of the service's results.
+
+type holding struct {
+	Wallet  string   `json:"wallet"`
+	Chain   chain.ID `json:"chain"`
+	Account string   `json:"account,omitempty"`
+	Asset   string   `json:"asset"`
+	Token   string   `json:"token,omitempty"`
+	Amount  float64  `json:"amount"`
+	// Price and Value are in USD and zero if unknown.
+	Price float64 `json:"price"`
+	Value float64 `json:"value"`
+}
+
+type portfolioView struct {
+	Time       time.Time `json:"time"`
+	TotalValue float64   `json:"total_value"`
+	// ByWallet is the total value of each wallet.
+	ByWallet map[string]float64 `json:"by_wallet"`
+	Holdings []holding          `json:"holdings"`
+	Errors   []string           `json:"errors,omitempty"`
+}
+
+func newPortfolio(s portfolio.Snapshot) portfolioView {
+	v := portfolioView{Time: s.Time, TotalValue: s.TotalValue(), ByWallet: s.ByWallet(), Holdings: []holding{}}
+	for _, h := range s.Holdings {
+		v.Holdings = append(v.Holdings, holding(h))
+	}
+	for _, err := range s.Errors {
+		v.Errors = append(v.Errors, err.Error())
+	}
+	return v
+}
+
+type summary struct {
+	Realized        float64 `json:"realized"`
+	Unrealized      float64 `json:"unrealized"`
+	Yield           float64 `json:"yield"`
+	Fees            float64 `json:"fees"`
+	ImpermanentLoss float64 `json:"impermanent_loss"`
+	Cost            float64 `json:"cost"`
+	Value           float64 `json:"value"`
+	Net             float64 `json:"net"`
+}
+
+func newSummary(s pnl.Summary) summary {
+	return summary{
+		Realized:        s.Realized,
+		Unrealized:      s.Unrealized,
+		Yield:           s.Yield,
+		Fees:            s.Fees,
+		ImpermanentLoss: s.ImpermanentLoss,
+		Cost:            s.Cost,
+		Value:           s.Value,
+		Net:             s.Net(),
+	}
+}
+
+type pnlLine struct {
+	Strategy string `json:"strategy"`
+	Wallet   string `json:"wallet"`
+	Asset    string `json:"asset"`
+	summary
+}
+
+type pnlView struct {
+	Method     pnl.Method         `json:"method"`
+	Lines      []pnlLine          `json:"lines"`
+	ByStrategy map[string]summary `json:"by_strategy"`
+	ByWallet   map[string]summary `json:"by_wallet"`
+	ByAsset    map[string]summary `json:"by_asset"`
+	Total      summary            `json:"total"`
+}
+
+func newPnL(r pnl.Report) pnlView {
+	v := pnlView{
+		Method:     r.Method,
+		Lines:      []pnlLine{},
+		ByStrategy: make(map[string]summary),
+		ByWallet:   make(map[string]summary),
+		ByAsset:    make(map[string]summary),
+		Total:      newSummary(r.Total),
+	}
+	for _, l := range r.Lines {
+		v.Lines = append(v.Lines, pnlLine{Strategy: l.Strategy, Wallet: l.Wallet, Asset: l.Asset, summary: newSummary(l.Summary)})
+	}
+	for name, s := range r.ByStrategy {
+		v.ByStrategy[name] = newSummary(s)
+	}
+	for wallet, s := range r.ByWallet {
+		v.ByWallet[wallet] = newSummary(s)
+	}
+	for asset, s := range r.ByAsset {
+		v.ByAsset[asset] = newSummary(s)
+	}
+	return v
+}
+
+type opportunity struct {
+	Source      string   `json:"source"`
+	Protocol    string   `json:"protocol"`
+	Chain       chain.ID `json:"chain"`
+	Asset       string   `json:"asset"`
+	ID          string   `json:"id"`
+	APY         float64  `json:"apy"`
+	NetAPY      float64  `json:"net_apy"`
+	TVL         float64  `json:"tvl,omitempty"`
+	Utilization float64  `json:"utilization,omitempty"`
+	Lockup      string   `json:"lockup,omitempty"`
+	Fee         float64  `json:"fee,omitempty"`
+	// Locked is set if the lockup exceeds the comparison horizon.
+	Locked bool `json:"locked,omitempty"`
+}
+
+type yieldsView struct {
+	Opportunities []opportunity `json:"opportunities"`
+	// Error reports the sources that could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+func newYields(ranked []yield.Ranked, err error) yieldsView {
+	v := yieldsView{Opportunities: []opportunity{}}
+	for _, r := range ranked {
+		o := opportunity{
+			Source:      r.Source,
+			Protocol:    r.Protocol,
+			Chain:       r.Chain,
+			Asset:       r.Asset,
+			ID:          r.ID,
+			APY:         r.APY,
+			NetAPY:      r.NetAPY,
+			TVL:         r.TVL,
+			Utilization: r.Utilization,
+			Fee:         r.Fee,
+			Locked:      r.Locked,
+		}
+		if r.Lockup > 0 {
+			o.Lockup = r.Lockup.String()
+		}
+		v.Opportunities = append(v.Opportunities, o)
+	}
+	if err != nil {
+		v.Error = err.Error()
+	}
+	return v
+}
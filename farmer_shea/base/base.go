@@ -2,14 +2,24 @@ package base
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/dryrun"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/metrics"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/store"
 	"github.com/sheawinkler/farmer-shea/base/erc20"
 	"github.com/sheawinkler/farmer-shea/base/nonfungiblepositionmanager"
 	"github.com/sheawinkler/farmer-shea/base/uniswapv3factory"
@@ -25,100 +35,232 @@ ype Client struct {
 	client *ethclient.Client
 }
 
-// NewClient creates a new Base client.
+// NewClient creates a new Base client. Its requests over HTTP are recorded
+// in the RPC metrics.
 func NewClient(rpcURL string) (*Client, error) {
-	c, err := ethclient.Dial(rpcURL)
+	c, err := rpc.DialOptions(context.Background(), rpcURL, rpc.WithHTTPClient(metrics.HTTPClient(chain.Base)))
 	if err != nil {
 		return nil, err
 	}
-	return &Client{client: c}, nil
+	return &Client{client: ethclient.NewClient(c)}, nil
 }
 
 // GetUniswapV3PoolAddress returns the address of a Uniswap V3 pool.
-func (c *Client) GetUniswapV3PoolAddress(tokenA, tokenB common.Address, fee *big.Int) (common.Address, error) {
+func (c *Client) GetUniswapV3PoolAddress(ctx context.Context, tokenA, tokenB common.Address, fee *big.Int) (common.Address, error) {
 	factoryAddress := common.HexToAddress(UniswapV3FactoryAddress)
 	factory, err := uniswapv3factory.NewUniswapv3factory(factoryAddress, c.client)
 	if err != nil {
 		return common.Address{}, err
 	}
 
-	poolAddress, err := factory.GetPool(nil, tokenA, tokenB, fee)
+	poolAddress, err := factory.GetPool(&bind.CallOpts{Context: ctx}, tokenA, tokenB, fee)
 	if err != nil {
-		return common.Address{}, err
+		return common.Address{}, classify(err)
 	}
 
 	return poolAddress, nil
 }
 
-// AddLiquidity adds liquidity to a Uniswap V3 pool.
-func (c *Client) AddLiquidity(privateKey *ecdsa.PrivateKey, params nonfungiblepositionmanager.INonfungiblePositionManagerMintParams) error {
-	npm, err := nonfungiblepositionmanager.NewNonfungiblepositionmanager(common.HexToAddress(NonfungiblePositionManagerAddress), c.client)
+// TokenDecimals returns the number of decimals of an ERC-20 token.
+func (c *Client) TokenDecimals(ctx context.Context, tokenAddress common.Address) (uint8, error) {
+	token, err := erc20.NewErc20(tokenAddress, c.client)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Create a new transactor
-	fromAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
-	nonce, err := c.client.PendingNonceAt(context.Background(), fromAddress)
+	decimals, err := token.Decimals(&bind.CallOpts{Context: ctx})
 	if err != nil {
-		return err
+		return 0, classify(err)
 	}
+	return decimals, nil
+}
 
-	gasPrice, err := c.client.SuggestGasPrice(context.Background())
+// ETHBalance returns the ETH balance of owner in wei.
+func (c *Client) ETHBalance(ctx context.Context, owner common.Address) (*big.Int, error) {
+	balance, err := c.client.BalanceAt(ctx, owner, nil)
 	if err != nil {
-		return err
+		return nil, classify(err)
 	}
+	return balance, nil
+}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(8453))
+// TokenBalance is the balance of an ERC-20 token held by an account.
+type TokenBalance struct {
+	Token    common.Address
+	Symbol   string
+	Amount   *big.Int
+	Decimals uint8
+}
+
+// TokenBalance returns the balance of an ERC-20 token held by owner.
+func (c *Client) TokenBalance(ctx context.Context, tokenAddress, owner common.Address) (TokenBalance, error) {
+	token, err := erc20.NewErc20(tokenAddress, c.client)
 	if err != nil {
-		return err
+		return TokenBalance{}, err
 	}
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)     // in wei
-	auth.GasLimit = uint64(300000) // in units
-	auth.GasPrice = gasPrice
 
-	// Add liquidity
-	_, err = npm.Mint(auth, params)
-	return err
+	opts := &bind.CallOpts{Context: ctx}
+	amount, err := token.BalanceOf(opts, owner)
+	if err != nil {
+		return TokenBalance{}, classify(err)
+	}
+	decimals, err := token.Decimals(opts)
+	if err != nil {
+		return TokenBalance{}, classify(err)
+	}
+	symbol, err := token.Symbol(opts)
+	if err != nil {
+		return TokenBalance{}, classify(err)
+	}
+	return TokenBalance{Token: tokenAddress, Symbol: symbol, Amount: amount, Decimals: decimals}, nil
+}
+
+// Minted describes a newly minted Uniswap V3 position.
+type Minted struct {
+	TokenID *big.Int
+	// Amount0 and Amount1 are the amounts of each token actually deposited.
+	Amount0 *big.Int
+	Amount1 *big.Int
+}
+
+// AddLiquidity adds liquidity to a Uniswap V3 pool and returns the minted
+// position NFT. In a dry run, the result is nil.
+func (c *Client) AddLiquidity(ctx context.Context, s *signer.EVM, params nonfungiblepositionmanager.INonfungiblePositionManagerMintParams) (*Minted, error) {
+	npm, err := nonfungiblepositionmanager.NewNonfungiblepositionmanager(common.HexToAddress(NonfungiblePositionManagerAddress), c.client)
+	if err != nil {
+		return nil, err
+	}
+
+	action := fmt.Sprintf("mint Uniswap V3 position %s/%s fee %s ticks [%s, %s] amounts %s/%s",
+		params.Token0.Hex(), params.Token1.Hex(), params.Fee, params.TickLower, params.TickUpper, params.Amount0Desired, params.Amount1Desired)
+	receipt, err := c.transact(ctx, s, action, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return npm.Mint(auth, params)
+	})
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+
+	for _, l := range receipt.Logs {
+		if ev, err := npm.ParseIncreaseLiquidity(*l); err == nil {
+			return &Minted{TokenID: ev.TokenId, Amount0: ev.Amount0, Amount1: ev.Amount1}, nil
+		}
+	}
+	return nil, errkind.Wrapf(errkind.Permanent, "mint transaction %s has no IncreaseLiquidity event", receipt.TxHash.Hex())
 }
 
 // Approve approves a token for spending by another address.
-func (c *Client) Approve(privateKey *ecdsa.PrivateKey, tokenAddress, spenderAddress common.Address, amount *big.Int) error {
+func (c *Client) Approve(ctx context.Context, s *signer.EVM, tokenAddress, spenderAddress common.Address, amount *big.Int) error {
 	token, err := erc20.NewErc20(tokenAddress, c.client)
 	if err != nil {
 		return err
 	}
 
+	action := fmt.Sprintf("approve %s of token %s for spender %s", amount, tokenAddress.Hex(), spenderAddress.Hex())
+	_, err = c.transact(ctx, s, action, func(auth *bind.TransactOpts) (*types.Transaction, error) {
+		return token.Approve(auth, spenderAddress, amount)
+	})
+	return err
+}
+
+// transact builds a transaction with build, sends it and waits for it to be
+// mined. The transaction and its fee are recorded to the run's store, if
+// any. In a dry run, the transaction is signed but not sent; it is simulated
+// with eth_call and eth_estimateGas and recorded under action instead, and
+// the receipt is nil.
+func (c *Client) transact(ctx context.Context, s *signer.EVM, action string, build func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Receipt, error) {
 	// Create a new transactor
-	fromAddress := crypto.PubkeyToAddress(privateKey.PublicKey)
-	nonce, err := c.client.PendingNonceAt(context.Background(), fromAddress)
+	fromAddress := s.CommonAddress()
+	nonce, err := c.client.PendingNonceAt(ctx, fromAddress)
 	if err != nil {
-		return err
+		return nil, classify(err)
 	}
 
-	gasPrice, err := c.client.SuggestGasPrice(context.Background())
+	gasPrice, err := c.client.SuggestGasPrice(ctx)
 	if err != nil {
-		return err
+		return nil, classify(err)
 	}
 
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(8453))
+	chainID, err := c.client.ChainID(ctx)
 	if err != nil {
-		return err
+		return nil, classify(err)
+	}
+
+	auth, err := s.TransactOpts(chainID)
+	if err != nil {
+		return nil, errkind.Wrap(errkind.Permanent, err)
 	}
 	auth.Nonce = big.NewInt(int64(nonce))
 	auth.Value = big.NewInt(0)     // in wei
 	auth.GasLimit = uint64(300000) // in units
 	auth.GasPrice = gasPrice
+	auth.Context = ctx
 
-	// Approve the token
-	_, err = token.Approve(auth, spenderAddress, amount)
-	return err
+	rec, dryRun := dryrun.FromContext(ctx)
+	auth.NoSend = dryRun
+
+	// build sends the transaction unless this is a dry run.
+	tx, err := build(auth)
+	if err != nil {
+		if dryRun {
+			return nil, classify(err)
+		}
+		return nil, classifySent(err)
+	}
+
+	if dryRun {
+		rec.Record(c.simulate(ctx, fromAddress, tx, action))
+		return nil, nil
+	}
+
+	id := store.RecordTx(ctx, chain.Base, tx.Hash().Hex(), action)
+	events.Publish(ctx, &events.TxSubmitted{Chain: chain.Base, Hash: tx.Hash().Hex(), Action: action})
+	receipt, err := bind.WaitMined(ctx, c.client, tx)
+	if err != nil {
+		return nil, classifySent(fmt.Errorf("transaction %s sent but not confirmed: %w", tx.Hash().Hex(), err))
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		err = errkind.Wrapf(errkind.Permanent, "transaction %s reverted", tx.Hash().Hex())
+	}
+	store.SettleTx(ctx, id, fee.String(), "wei", err)
+	events.Publish(ctx, &events.TxConfirmed{Chain: chain.Base, Hash: tx.Hash().Hex(), Fee: fee.String(), FeeAsset: "wei", Err: err})
+	eth, _ := new(big.Rat).SetFrac(fee, big.NewInt(1e18)).Float64()
+	pnl.Record(ctx, store.Entry{Kind: store.EntryFee, Chain: chain.Base, Asset: "ETH", Amount: eth})
+	return receipt, err
+}
+
+func (c *Client) simulate(ctx context.Context, from common.Address, tx *types.Transaction, action string) dryrun.Step {
+	step := dryrun.Step{Chain: "base", Action: action, Accounts: []string{from.Hex()}}
+	if tx.To() != nil {
+		step.Accounts = append(step.Accounts, tx.To().Hex())
+	}
+
+	msg := ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+
+	if _, err := c.client.CallContract(ctx, msg, nil); err != nil {
+		step.Err = classify(err)
+		return step
+	}
+
+	gas, err := c.client.EstimateGas(ctx, msg)
+	if err != nil {
+		step.Err = classify(err)
+		return step
+	}
+
+	step.Result = fmt.Sprintf("estimated gas %d (limit %d) at %s wei", gas, tx.Gas(), tx.GasPrice())
+	return step
 }
 
 // Swap simulates a swap on Uniswap V3.
-func (c *Client) Swap(poolAddress common.Address, amount *big.Int) error {
-	fmt.Printf("Simulating swap of %s tokens on pool %s\n", amount.String(), poolAddress.Hex())
+func (c *Client) Swap(ctx context.Context, poolAddress common.Address, amount *big.Int) error {
+	log.Debug().Str("amount", amount.String()).Str("pool", poolAddress.Hex()).Msg("Simulating swap")
 	// In a real implementation, this would involve creating and sending a transaction
 	// to the Uniswap V3 router contract.
 	return nil
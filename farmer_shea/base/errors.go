@@ -0,0 +1,69 @@
+package base
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sheawinkler/farmer-shea/errkind"
+)
+
+// codeExecutionReverted is the JSON-RPC error code geth-compatible nodes
+// return when a call or gas estimation reverts.
+const codeExecutionReverted = 3
+
+// classify annotates errors returned by the Base RPC node with an
+// errkind.Kind.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return errkind.FromHTTPStatus(httpErr.StatusCode, "", err)
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "execution reverted"),
+		strings.Contains(msg, "insufficient funds"),
+		strings.Contains(msg, "gas required exceeds allowance"):
+		return errkind.Wrap(errkind.Revert, err)
+	case strings.Contains(msg, "rate limit"),
+		strings.Contains(msg, "too many requests"):
+		return errkind.RateLimit(err, 0)
+	case strings.Contains(msg, "nonce too low"),
+		strings.Contains(msg, "replacement transaction underpriced"),
+		strings.Contains(msg, "already known"):
+		// The nonce belongs to a transaction the node already has, which
+		// may be ours. Sending again with a fresh nonce could send it
+		// twice.
+		return errkind.Wrap(errkind.Permanent, err)
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.ErrorCode() {
+		case codeExecutionReverted:
+			return errkind.Wrap(errkind.Revert, err)
+		case -32005: // limit exceeded
+			return errkind.RateLimit(err, 0)
+		case -32600, -32601, -32602: // invalid request, method not found, invalid params
+			return errkind.Wrap(errkind.Permanent, err)
+		}
+	}
+
+	return errkind.Annotate(err)
+}
+
+// classifySent annotates an error raised once a transaction may have
+// reached the node. Unless the node rejected it outright, its outcome is
+// unknown, so it must not be rebuilt and sent again.
+func classifySent(err error) error {
+	err = classify(err)
+	if k := errkind.Of(err); k == errkind.Revert || k == errkind.Permanent {
+		return err
+	}
+	return errkind.Wrap(errkind.Unconfirmed, err)
+}
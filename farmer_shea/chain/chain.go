@@ -0,0 +1,24 @@
+package chain
+
+// ID identifies a blockchain the bot operates on.
+type ID string
+
+const (
+	Solana      ID = "solana"
+	Base        ID = "base"
+	Hyperliquid ID = "hyperliquid"
+	Sui         ID = "sui"
+)
+
+// All lists every supported chain.
+var All = []ID{Solana, Base, Hyperliquid, Sui}
+
+// Valid reports whether id is a supported chain.
+func (id ID) Valid() bool {
+	for _, c := range All {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
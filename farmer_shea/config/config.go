@@ -1,57 +1,221 @@
 package config
 
 import (
-	"github.com/spf13/viper"
+	"time"
 )
 
-// HyperliquidConfig holds configuration for the Hyperliquid vault strategy.
-type HyperliquidConfig struct {
-	VaultAddress string  `mapstructure:"vault_address"`
-	Amount       string  `mapstructure:"amount"`
-	StopLoss     float64 `mapstructure:"stop_loss"`
+// StrategyConfig configures one strategy instance. Several instances of a
+// type may run side by side under different names.
+type StrategyConfig struct {
+	// Type selects the registered strategy type, e.g. "solend".
+	Type string `mapstructure:"type"`
+	// Name identifies the instance in logs and run records. It defaults to
+	// Type and must be unique.
+	Name string `mapstructure:"name"`
+	// Enabled defaults to true.
+	Enabled *bool `mapstructure:"enabled"`
+	// Wallet names the wallet whose keys the instance uses. It defaults to
+	// the default wallet.
+	Wallet   string         `mapstructure:"wallet"`
+	Schedule ScheduleConfig `mapstructure:"schedule"`
+	// Params are checked against the strategy type's schema.
+	Params map[string]any `mapstructure:"params"`
 }
 
-// BaseConfig holds configuration for the Base Uniswap V3 LP strategy.
-type BaseConfig struct {
-	TokenA  string `mapstructure:"token_a"`
-	TokenB  string `mapstructure:"token_b"`
-	Fee     int64  `mapstructure:"fee"`
-	AmountA string `mapstructure:"amount_a"`
-	AmountB string `mapstructure:"amount_b"`
+// IsEnabled reports whether the instance should run.
+func (c StrategyConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
 }
 
-// MACrossoverConfig holds configuration for the Moving Average Crossover strategy.
-type MACrossoverConfig struct {
-	Symbol      string `mapstructure:"symbol"`
-	ShortPeriod int    `mapstructure:"short_period"`
-	LongPeriod  int    `mapstructure:"long_period"`
+// ScheduleConfig overrides the schedule a strategy declares. See
+// schedule.Parse for the supported specs. A config with only a jitter adds
+// jitter to the declared schedule.
+type ScheduleConfig struct {
+	Spec   string        `mapstructure:"spec"`
+	Jitter time.Duration `mapstructure:"jitter"`
+}
+
+// PortfolioConfig controls how wallet balances are tracked.
+type PortfolioConfig struct {
+	// Interval is how often balances are collected.
+	Interval time.Duration `mapstructure:"interval"`
+	// BaseTokens lists the ERC-20 contracts whose balances are tracked on
+	// Base, in addition to ETH.
+	BaseTokens []string `mapstructure:"base_tokens"`
+}
+
+// PnLConfig controls PnL accounting.
+type PnLConfig struct {
+	// Method is the cost basis method: "fifo" or "average".
+	Method string `mapstructure:"method"`
+}
+
+// LogConfig controls logging.
+type LogConfig struct {
+	// Level is the minimum level logged: trace, debug, info, warn or error.
+	Level string `mapstructure:"level"`
+	// File receives every record as JSON and is rotated by size. Empty
+	// disables it.
+	File       string `mapstructure:"file"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"`
+	MaxBackups int    `mapstructure:"max_backups"`
+	MaxAgeDays int    `mapstructure:"max_age_days"`
+}
+
+// APIConfig controls the local HTTP API.
+type APIConfig struct {
+	// Listen is a loopback host:port, e.g. "127.0.0.1:8787", or a unix
+	// socket as "unix:PATH". Empty disables the API.
+	Listen string `mapstructure:"listen"`
+	// TokenFile holds the token requests must carry. Without it, the token
+	// is read from FARMER_SHEA_API_TOKEN.
+	TokenFile string `mapstructure:"token_file"`
+}
+
+// MetricsConfig controls the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	// Listen is a host:port, e.g. "127.0.0.1:9464". Empty disables the
+	// endpoint.
+	Listen string `mapstructure:"listen"`
+	Path   string `mapstructure:"path"`
+}
+
+// AlertChannelConfig configures one channel alerts are sent through.
+type AlertChannelConfig struct {
+	// Name identifies the channel in logs. It defaults to Type.
+	Name string `mapstructure:"name"`
+	// Type is webhook, slack, telegram or email.
+	Type string `mapstructure:"type"`
+	// MinSeverity is the least severe alert sent: info, warning or
+	// critical. It defaults to warning.
+	MinSeverity string `mapstructure:"min_severity"`
+	// RateLimit is the most alerts sent per RateWindow; 0 means no limit.
+	RateLimit  int           `mapstructure:"rate_limit"`
+	RateWindow time.Duration `mapstructure:"rate_window"`
+	// DedupWindow drops repeats of an alert sent less than this long ago.
+	DedupWindow time.Duration `mapstructure:"dedup_window"`
+	// Digest sends the periodic summary through the channel.
+	Digest bool `mapstructure:"digest"`
+
+	// URL is the endpoint of webhook and slack channels, and overrides the
+	// Bot API endpoint of telegram channels.
+	URL string `mapstructure:"url"`
+	// TokenFile holds the telegram bot token; ChatID is the chat to post to.
+	TokenFile string `mapstructure:"token_file"`
+	ChatID    string `mapstructure:"chat_id"`
+	// SMTPAddr is the email server's host:port.
+	SMTPAddr string   `mapstructure:"smtp_addr"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+	Username string   `mapstructure:"username"`
+	// PasswordFile holds the SMTP password.
+	PasswordFile string `mapstructure:"password_file"`
+}
+
+// AlertsConfig controls alerting.
+type AlertsConfig struct {
+	// FailureThreshold is the number of consecutive failed runs of a
+	// strategy that raises an alert.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// Digest is the schedule of summaries, e.g. "cron 0 8 * * *". Empty
+	// disables them.
+	Digest   string               `mapstructure:"digest"`
+	Channels []AlertChannelConfig `mapstructure:"channels"`
+}
+
+// AllocationConfig caps the share of the portfolio held in positions per
+// protocol, chain and asset, as fractions between 0 and 1 by name. The "*"
+// entry applies to names without their own.
+type AllocationConfig struct {
+	Protocol map[string]float64 `mapstructure:"protocol"`
+	Chain    map[string]float64 `mapstructure:"chain"`
+	Asset    map[string]float64 `mapstructure:"asset"`
+}
+
+// RiskConfig sets the limits every action must pass before it is
+// submitted. Zero disables a limit.
+type RiskConfig struct {
+	MaxNotionalUSD  float64          `mapstructure:"max_notional_usd"`
+	MaxAllocation   AllocationConfig `mapstructure:"max_allocation"`
+	MaxDailyLossUSD float64          `mapstructure:"max_daily_loss_usd"`
+	MaxDailyGasUSD  float64          `mapstructure:"max_daily_gas_usd"`
+	// MinReserve is the balance of its native asset, e.g. SOL, a wallet
+	// keeps on each chain for fees.
+	MinReserve map[string]float64 `mapstructure:"min_reserve"`
+	// HaltOnDailyLoss engages the kill switch once the daily loss limit is
+	// exceeded, and UnwindOnHalt then unwinds positions too.
+	HaltOnDailyLoss bool `mapstructure:"halt_on_daily_loss"`
+	UnwindOnHalt    bool `mapstructure:"unwind_on_halt"`
+}
+
+// AllocatorConfig controls how capital is allocated across strategies.
+type AllocatorConfig struct {
+	// CapitalUSD is the total capital to allocate. Zero disables the
+	// allocator.
+	CapitalUSD float64 `mapstructure:"capital_usd"`
+	// Policy is "fixed", to allocate by Weights, or "yield", to allocate
+	// by each strategy's reported, risk-adjusted APY.
+	Policy string `mapstructure:"policy"`
+	// Weights are target shares of the capital by strategy name.
+	Weights map[string]float64 `mapstructure:"weights"`
+	// MaxWeight caps any strategy's share under the yield policy.
+	MaxWeight float64 `mapstructure:"max_weight"`
+	// DriftThreshold is the drift from its target, as a share of the
+	// capital, past which a strategy is rebalanced.
+	DriftThreshold float64       `mapstructure:"drift_threshold"`
+	Interval       time.Duration `mapstructure:"interval"`
+}
+
+// YieldConfig controls how yield opportunities are compared.
+type YieldConfig struct {
+	// AmountUSD is the capital yields are compared for; gas and bridge
+	// costs are spread over it.
+	AmountUSD float64 `mapstructure:"amount_usd"`
+	// Horizon is how long capital is expected to stay in an opportunity.
+	Horizon time.Duration `mapstructure:"horizon"`
+	// GasUSD is the cost of a transaction by chain.
+	GasUSD map[string]float64 `mapstructure:"gas_usd"`
+	// HomeChain is where capital sits before it is deployed, and BridgeUSD
+	// the cost of bridging it to another chain.
+	HomeChain   string  `mapstructure:"home_chain"`
+	BridgeUSD   float64 `mapstructure:"bridge_usd"`
+	MarinadeAPI string  `mapstructure:"marinade_api"`
+	// UniswapSubgraph is a Uniswap V3 subgraph for Base, whose
+	// UniswapPools are compared. Empty leaves Uniswap out.
+	UniswapSubgraph string   `mapstructure:"uniswap_subgraph"`
+	UniswapPools    []string `mapstructure:"uniswap_pools"`
+	// HyperliquidVaults are the vaults compared. Empty leaves Hyperliquid
+	// out.
+	HyperliquidVaults []string `mapstructure:"hyperliquid_vaults"`
 }
 
 // Config is the configuration for the application.
 type Config struct {
-	WalletPath        string            `mapstructure:"wallet_path"`
-	SolanaRPC         string            `mapstructure:"solana_rpc"`
-	BaseRPC           string            `mapstructure:"base_rpc"`
-	Hyperliquid       HyperliquidConfig `mapstructure:"hyperliquid"`
-	Base              BaseConfig        `mapstructure:"base"`
-	MACrossover       MACrossoverConfig `mapstructure:"ma_crossover"`
-}
-
-// Load loads the configuration from a file.
-func Load() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AutomaticEnv()
-
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, err
-	}
-
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, err
-	}
-
-	return &config, nil
+	// Profile selects the network endpoints to default to. See Profiles.
+	Profile        string `mapstructure:"profile"`
+	WalletPath     string `mapstructure:"wallet_path"`
+	SolanaRPC      string `mapstructure:"solana_rpc"`
+	BaseRPC        string `mapstructure:"base_rpc"`
+	SuiRPC         string `mapstructure:"sui_rpc"`
+	HyperliquidAPI string `mapstructure:"hyperliquid_api"`
+	// Wallets maps a wallet name to its encrypted keystores by chain. The
+	// default wallet's Solana keystore defaults to WalletPath.
+	Wallets map[string]map[string]string `mapstructure:"wallets"`
+	// PassphraseFile holds the keystore passphrase. If empty, the
+	// passphrase is read from the environment or prompted for.
+	PassphraseFile    string           `mapstructure:"passphrase_file"`
+	Strategies        []StrategyConfig `mapstructure:"strategies"`
+	ScheduleStatePath string           `mapstructure:"schedule_state_path"`
+	// StatePath is the database that records runs, transactions, positions
+	// and balance snapshots.
+	StatePath string          `mapstructure:"state_path"`
+	Portfolio PortfolioConfig `mapstructure:"portfolio"`
+	PnL       PnLConfig       `mapstructure:"pnl"`
+	Log       LogConfig       `mapstructure:"log"`
+	API       APIConfig       `mapstructure:"api"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Alerts    AlertsConfig    `mapstructure:"alerts"`
+	Risk      RiskConfig      `mapstructure:"risk"`
+	Allocator AllocatorConfig `mapstructure:"allocator"`
+	Yield     YieldConfig     `mapstructure:"yield"`
 }
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variables that override config keys.
+// Nested keys are joined with underscores, so FARMER_SHEA_SOLANA_RPC sets
+// solana_rpc and FARMER_SHEA_LOG_LEVEL sets log.level. Only keys that are
+// set in the config file or have a default can be overridden, e.g.
+// FARMER_SHEA_WALLETS_DEFAULT_BASE sets wallets.default.base only if the
+// file sets it. Keys inside lists, such as strategy params, cannot be.
+const EnvPrefix = "FARMER_SHEA"
+
+// DefaultPath is the config file read when no path is given.
+const DefaultPath = "config.yaml"
+
+// Endpoints are the network endpoints a profile defaults to.
+type Endpoints struct {
+	SolanaRPC      string
+	BaseRPC        string
+	SuiRPC         string
+	HyperliquidAPI string
+}
+
+// DefaultProfile is used when no profile is selected.
+const DefaultProfile = "mainnet"
+
+// Profiles are the built-in network profiles. Endpoints set in the config
+// file or environment take precedence over the profile's.
+var Profiles = map[string]Endpoints{
+	"mainnet": {
+		SolanaRPC:      "https://api.mainnet-beta.solana.com",
+		BaseRPC:        "https://mainnet.base.org",
+		SuiRPC:         "https://fullnode.mainnet.sui.io:443",
+		HyperliquidAPI: "https://api.hyperliquid.xyz",
+	},
+	"testnet": {
+		SolanaRPC:      "https://api.testnet.solana.com",
+		BaseRPC:        "https://sepolia.base.org",
+		SuiRPC:         "https://fullnode.testnet.sui.io:443",
+		HyperliquidAPI: "https://api.hyperliquid-testnet.xyz",
+	},
+	"devnet": {
+		SolanaRPC:      "https://api.devnet.solana.com",
+		BaseRPC:        "https://sepolia.base.org",
+		SuiRPC:         "https://fullnode.devnet.sui.io:443",
+		HyperliquidAPI: "https://api.hyperliquid-testnet.xyz",
+	},
+	// local expects a solana-test-validator, an anvil fork of Base and a
+	// local Sui network. Hyperliquid has no local node, so it uses testnet.
+	"local": {
+		SolanaRPC:      "http://127.0.0.1:8899",
+		BaseRPC:        "http://127.0.0.1:8545",
+		SuiRPC:         "http://127.0.0.1:9000",
+		HyperliquidAPI: "https://api.hyperliquid-testnet.xyz",
+	},
+}
+
+// Options control how Load finds and checks the config.
+type Options struct {
+	// Path is the config file. It defaults to DefaultPath.
+	Path string
+	// Profile overrides the profile set in the file or environment.
+	Profile string
+	// ValidateStrategy, if set, checks each strategy instance's type and
+	// params, e.g. against the strategy registry.
+	ValidateStrategy func(StrategyConfig) error
+}
+
+// Load reads the config file, applies the selected profile and environment
+// overrides, fills in defaults and validates the result. Validation
+// problems are reported together in a *ValidationError.
+//
+// Values are resolved in increasing order of precedence: built-in defaults
+// and profile endpoints, the top level of the file, the file's section for
+// the profile under "profiles", and the environment.
+func Load(opts Options) (*Config, error) {
+	path := opts.Path
+	if path == "" {
+		path = DefaultPath
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// Env overrides only apply to keys viper knows about, so every
+	// top-level setting gets a default.
+	v.SetDefault("profile", DefaultProfile)
+	v.SetDefault("wallet_path", "farmer_shea_wallet.json")
+	v.SetDefault("passphrase_file", "")
+	v.SetDefault("schedule_state_path", "")
+	v.SetDefault("state_path", "farmer_shea.db")
+	v.SetDefault("portfolio.interval", "5m")
+	v.SetDefault("portfolio.base_tokens", []string{})
+	v.SetDefault("pnl.method", "fifo")
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.file", "farmer_shea.log")
+	v.SetDefault("log.max_size_mb", 10)
+	v.SetDefault("log.max_backups", 5)
+	v.SetDefault("log.max_age_days", 30)
+	v.SetDefault("api.listen", "")
+	v.SetDefault("api.token_file", "")
+	v.SetDefault("metrics.listen", "")
+	v.SetDefault("metrics.path", "/metrics")
+	v.SetDefault("alerts.failure_threshold", 3)
+	v.SetDefault("alerts.digest", "@daily")
+	v.SetDefault("risk.max_notional_usd", 0)
+	v.SetDefault("risk.max_daily_loss_usd", 0)
+	v.SetDefault("risk.max_daily_gas_usd", 0)
+	v.SetDefault("risk.halt_on_daily_loss", false)
+	v.SetDefault("risk.unwind_on_halt", false)
+	v.SetDefault("allocator.capital_usd", 0)
+	v.SetDefault("allocator.policy", "fixed")
+	v.SetDefault("allocator.max_weight", 1)
+	v.SetDefault("allocator.drift_threshold", 0.05)
+	v.SetDefault("allocator.interval", "1h")
+	v.SetDefault("yield.amount_usd", 0)
+	v.SetDefault("yield.horizon", "720h")
+	v.SetDefault("yield.home_chain", "")
+	v.SetDefault("yield.bridge_usd", 0)
+	v.SetDefault("yield.marinade_api", "https://api.marinade.finance")
+	v.SetDefault("yield.uniswap_subgraph", "")
+	v.SetDefault("yield.uniswap_pools", []string{})
+	v.SetDefault("yield.hyperliquid_vaults", []string{})
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = v.GetString("profile")
+	}
+	endpoints, ok := Profiles[profile]
+	if !ok {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("profile: unknown profile %q (known: mainnet, testnet, devnet, local)", profile)}}
+	}
+	v.Set("profile", profile)
+	v.SetDefault("solana_rpc", endpoints.SolanaRPC)
+	v.SetDefault("base_rpc", endpoints.BaseRPC)
+	v.SetDefault("sui_rpc", endpoints.SuiRPC)
+	v.SetDefault("hyperliquid_api", endpoints.HyperliquidAPI)
+
+	if overrides := v.GetStringMap("profiles." + profile); len(overrides) > 0 {
+		if err := v.MergeConfigMap(overrides); err != nil {
+			return nil, fmt.Errorf("failed to apply profile %s: %w", profile, err)
+		}
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode config %s: %w", path, err)
+	}
+	config.setDefaults()
+
+	if err := config.Validate(opts.ValidateStrategy); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// setDefaults fills in defaults that depend on other settings.
+func (c *Config) setDefaults() {
+	for i := range c.Strategies {
+		sc := &c.Strategies[i]
+		if sc.Name == "" {
+			sc.Name = sc.Type
+		}
+		// Viper lowercases map keys, so wallet names are matched in
+		// lower case.
+		sc.Wallet = strings.ToLower(sc.Wallet)
+	}
+	for i := range c.Alerts.Channels {
+		ac := &c.Alerts.Channels[i]
+		if ac.Name == "" {
+			ac.Name = ac.Type
+		}
+		if ac.MinSeverity == "" {
+			ac.MinSeverity = "warning"
+		}
+	}
+}
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems collects validation problems, each prefixed with the config key
+// it concerns.
+type problems []string
+
+func (p *problems) add(key string, err error) {
+	if err == nil {
+		return
+	}
+	// Joined errors are reported one per line.
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		for _, e := range joined.Unwrap() {
+			p.add(key, e)
+		}
+		return
+	}
+	*p = append(*p, key+": "+err.Error())
+}
+
+func (p *problems) addf(key, format string, args ...any) {
+	p.add(key, fmt.Errorf(format, args...))
+}
+
+// fileExists reports whether path names an existing file.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
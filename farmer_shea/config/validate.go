@@ -0,0 +1,352 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/notify"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/schedule"
+)
+
+// defaultWallet is the wallet strategies use unless they name another. It
+// matches wallet.Default.
+const defaultWallet = "default"
+
+// Validate checks the config and returns a *ValidationError listing every
+// problem found. validateStrategy, if not nil, checks each strategy
+// instance's type and params.
+func (c *Config) Validate(validateStrategy func(StrategyConfig) error) error {
+	var p problems
+
+	p.add("solana_rpc", checkURL(c.SolanaRPC, "http", "https"))
+	p.add("base_rpc", checkURL(c.BaseRPC, "http", "https", "ws", "wss"))
+	p.add("sui_rpc", checkURL(c.SuiRPC, "http", "https"))
+	p.add("hyperliquid_api", checkURL(c.HyperliquidAPI, "http", "https"))
+
+	if c.StatePath == "" {
+		p.addf("state_path", "missing state store path")
+	}
+	if c.Portfolio.Interval <= 0 {
+		p.addf("portfolio.interval", "must be positive")
+	}
+	for i, token := range c.Portfolio.BaseTokens {
+		if !common.IsHexAddress(token) {
+			p.addf(fmt.Sprintf("portfolio.base_tokens[%d]", i), "invalid address %q", token)
+		}
+	}
+	if _, err := pnl.ParseMethod(c.PnL.Method); err != nil {
+		p.add("pnl.method", err)
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil || c.Log.Level == "" {
+		p.addf("log.level", "unknown level %q (known: trace, debug, info, warn, error)", c.Log.Level)
+	}
+	for _, f := range []struct {
+		key string
+		n   int
+	}{{"log.max_size_mb", c.Log.MaxSizeMB}, {"log.max_backups", c.Log.MaxBackups}, {"log.max_age_days", c.Log.MaxAgeDays}} {
+		if f.n < 0 {
+			p.addf(f.key, "must not be negative")
+		}
+	}
+	if c.API.Listen != "" {
+		p.add("api.listen", checkListen(c.API.Listen))
+	}
+	if c.API.TokenFile != "" && !fileExists(c.API.TokenFile) {
+		p.addf("api.token_file", "%s does not exist", c.API.TokenFile)
+	}
+	if c.Metrics.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Listen); err != nil {
+			p.addf("metrics.listen", "want host:port: %v", err)
+		}
+	}
+	if !strings.HasPrefix(c.Metrics.Path, "/") {
+		p.addf("metrics.path", "must start with /")
+	}
+	if c.Alerts.FailureThreshold < 1 {
+		p.addf("alerts.failure_threshold", "must be at least 1")
+	}
+	if c.Alerts.Digest != "" {
+		if _, err := schedule.Parse(c.Alerts.Digest); err != nil {
+			p.add("alerts.digest", err)
+		}
+	}
+	for i, ac := range c.Alerts.Channels {
+		validateAlertChannel(&p, fmt.Sprintf("alerts.channels[%d]", i), ac)
+	}
+	validateRisk(&p, c.Risk)
+	if c.PassphraseFile != "" && !fileExists(c.PassphraseFile) {
+		p.addf("passphrase_file", "%s does not exist", c.PassphraseFile)
+	}
+
+	for name, keys := range c.Wallets {
+		for id, path := range keys {
+			key := fmt.Sprintf("wallets.%s.%s", name, id)
+			if !chain.ID(id).Valid() {
+				p.addf(key, "unknown chain %q (known: %v)", id, chain.All)
+			}
+			if path == "" {
+				p.addf(key, "missing keystore path")
+			}
+		}
+	}
+
+	names := make(map[string]int)
+	for i, sc := range c.Strategies {
+		key := fmt.Sprintf("strategies[%d]", i)
+		if sc.Name != "" {
+			key = fmt.Sprintf("strategies[%d] (%s)", i, sc.Name)
+		}
+
+		if sc.Type == "" {
+			p.addf(key+".type", "missing strategy type")
+			continue
+		}
+		if j, ok := names[sc.Name]; ok {
+			p.addf(key+".name", "duplicate name, also used by strategies[%d]; give each instance a unique name", j)
+		}
+		names[sc.Name] = i
+
+		if sc.Wallet != "" && sc.Wallet != defaultWallet {
+			if _, ok := c.Wallets[sc.Wallet]; !ok {
+				p.addf(key+".wallet", "unknown wallet %q", sc.Wallet)
+			}
+		}
+		if sc.Schedule.Spec != "" {
+			if _, err := schedule.Parse(sc.Schedule.Spec); err != nil {
+				p.add(key+".schedule.spec", err)
+			}
+		}
+		if sc.Schedule.Jitter < 0 {
+			p.addf(key+".schedule.jitter", "must not be negative")
+		}
+		if validateStrategy != nil {
+			p.add(key, validateStrategy(sc))
+		}
+	}
+
+	validateAllocator(&p, c.Allocator, names)
+	validateYield(&p, c.Yield)
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: []string(p)}
+	}
+	return nil
+}
+
+// validateAlertChannel checks an alert channel's limits and the settings its
+// type requires.
+func validateAlertChannel(p *problems, key string, ac AlertChannelConfig) {
+	if _, err := notify.ParseSeverity(ac.MinSeverity); err != nil {
+		p.add(key+".min_severity", err)
+	}
+	if ac.RateLimit < 0 {
+		p.addf(key+".rate_limit", "must not be negative")
+	}
+	if ac.RateLimit > 0 && ac.RateWindow <= 0 {
+		p.addf(key+".rate_window", "must be positive when rate_limit is set")
+	}
+	if ac.DedupWindow < 0 {
+		p.addf(key+".dedup_window", "must not be negative")
+	}
+
+	switch ac.Type {
+	case "webhook", "slack":
+		p.add(key+".url", checkURL(ac.URL, "http", "https"))
+	case "telegram":
+		if ac.URL != "" {
+			p.add(key+".url", checkURL(ac.URL, "http", "https"))
+		}
+		if ac.TokenFile == "" {
+			p.addf(key+".token_file", "missing bot token file")
+		} else if !fileExists(ac.TokenFile) {
+			p.addf(key+".token_file", "%s does not exist", ac.TokenFile)
+		}
+		if ac.ChatID == "" {
+			p.addf(key+".chat_id", "missing chat ID")
+		}
+	case "email":
+		if _, _, err := net.SplitHostPort(ac.SMTPAddr); err != nil {
+			p.addf(key+".smtp_addr", "want host:port: %v", err)
+		}
+		if ac.From == "" {
+			p.addf(key+".from", "missing sender address")
+		}
+		if len(ac.To) == 0 {
+			p.addf(key+".to", "missing recipients")
+		}
+		if ac.PasswordFile != "" && !fileExists(ac.PasswordFile) {
+			p.addf(key+".password_file", "%s does not exist", ac.PasswordFile)
+		}
+	case "":
+		p.addf(key+".type", "missing channel type")
+	default:
+		p.addf(key+".type", "unknown channel type %q (known: webhook, slack, telegram, email)", ac.Type)
+	}
+}
+
+// validateRisk checks that risk limits are in range.
+func validateRisk(p *problems, r RiskConfig) {
+	for _, f := range []struct {
+		key string
+		v   float64
+	}{{"risk.max_notional_usd", r.MaxNotionalUSD}, {"risk.max_daily_loss_usd", r.MaxDailyLossUSD}, {"risk.max_daily_gas_usd", r.MaxDailyGasUSD}} {
+		if f.v < 0 {
+			p.addf(f.key, "must not be negative")
+		}
+	}
+	for _, a := range []struct {
+		key    string
+		shares map[string]float64
+	}{{"protocol", r.MaxAllocation.Protocol}, {"chain", r.MaxAllocation.Chain}, {"asset", r.MaxAllocation.Asset}} {
+		for name, share := range a.shares {
+			if share < 0 || share > 1 {
+				p.addf(fmt.Sprintf("risk.max_allocation.%s.%s", a.key, name), "must be a fraction between 0 and 1, got %g", share)
+			}
+		}
+	}
+	for id, reserve := range r.MinReserve {
+		key := "risk.min_reserve." + id
+		// Hyperliquid charges fees in the traded asset, so it has no reserve.
+		if c := chain.ID(id); !c.Valid() || c == chain.Hyperliquid {
+			p.addf(key, "chain %q has no native fee asset (known: solana, base, sui)", id)
+		}
+		if reserve < 0 {
+			p.addf(key, "must not be negative")
+		}
+	}
+	if r.HaltOnDailyLoss && r.MaxDailyLossUSD == 0 {
+		p.addf("risk.halt_on_daily_loss", "requires risk.max_daily_loss_usd")
+	}
+}
+
+// validateAllocator checks the allocator's policy and weights. names maps
+// the configured strategy names to their index.
+func validateAllocator(p *problems, a AllocatorConfig, names map[string]int) {
+	if a.CapitalUSD < 0 {
+		p.addf("allocator.capital_usd", "must not be negative")
+	}
+	switch a.Policy {
+	case "fixed":
+		if a.CapitalUSD > 0 && len(a.Weights) == 0 {
+			p.addf("allocator.weights", "the fixed policy needs a weight for at least one strategy")
+		}
+	case "yield":
+	default:
+		p.addf("allocator.policy", "unknown policy %q (known: fixed, yield)", a.Policy)
+	}
+	total := 0.0
+	for name, w := range a.Weights {
+		key := "allocator.weights." + name
+		// Viper lowercases map keys, so strategy names are matched in any
+		// case.
+		found := false
+		for n := range names {
+			found = found || strings.EqualFold(n, name)
+		}
+		if !found {
+			p.addf(key, "unknown strategy %q", name)
+		}
+		if w < 0 || w > 1 {
+			p.addf(key, "must be a fraction between 0 and 1, got %g", w)
+		}
+		total += w
+	}
+	if total > 1+1e-9 {
+		p.addf("allocator.weights", "add up to %g, more than all of the capital", total)
+	}
+	if a.MaxWeight <= 0 || a.MaxWeight > 1 {
+		p.addf("allocator.max_weight", "must be a fraction above 0 and at most 1, got %g", a.MaxWeight)
+	}
+	if a.DriftThreshold <= 0 || a.DriftThreshold >= 1 {
+		p.addf("allocator.drift_threshold", "must be a fraction between 0 and 1, got %g", a.DriftThreshold)
+	}
+	if a.Interval <= 0 {
+		p.addf("allocator.interval", "must be positive")
+	}
+}
+
+// validateYield checks the costs and sources yields are compared with.
+func validateYield(p *problems, y YieldConfig) {
+	for _, f := range []struct {
+		key string
+		v   float64
+	}{{"yield.amount_usd", y.AmountUSD}, {"yield.bridge_usd", y.BridgeUSD}} {
+		if f.v < 0 {
+			p.addf(f.key, "must not be negative")
+		}
+	}
+	if y.Horizon <= 0 {
+		p.addf("yield.horizon", "must be positive")
+	}
+	for id, gas := range y.GasUSD {
+		key := "yield.gas_usd." + id
+		if !chain.ID(id).Valid() {
+			p.addf(key, "unknown chain %q (known: %v)", id, chain.All)
+		}
+		if gas < 0 {
+			p.addf(key, "must not be negative")
+		}
+	}
+	if y.HomeChain != "" && !chain.ID(y.HomeChain).Valid() {
+		p.addf("yield.home_chain", "unknown chain %q (known: %v)", y.HomeChain, chain.All)
+	}
+	p.add("yield.marinade_api", checkURL(y.MarinadeAPI, "http", "https"))
+	if y.UniswapSubgraph != "" {
+		p.add("yield.uniswap_subgraph", checkURL(y.UniswapSubgraph, "http", "https"))
+	}
+	for i, pool := range y.UniswapPools {
+		if !common.IsHexAddress(pool) {
+			p.addf(fmt.Sprintf("yield.uniswap_pools[%d]", i), "invalid address %q", pool)
+		}
+	}
+	for i, vault := range y.HyperliquidVaults {
+		if !common.IsHexAddress(vault) {
+			p.addf(fmt.Sprintf("yield.hyperliquid_vaults[%d]", i), "invalid address %q", vault)
+		}
+	}
+}
+
+// checkURL checks that s is an absolute URL with one of the given schemes.
+func checkURL(s string, schemes ...string) error {
+	if s == "" {
+		return fmt.Errorf("missing URL")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", s, err)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme && u.Host != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid URL %q: expected a %v URL with a host", s, schemes)
+}
+
+// checkListen checks that addr is a unix:PATH socket or a host:port on the
+// loopback interface, so that the API is not exposed to the network.
+func checkListen(addr string) error {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return fmt.Errorf("missing socket path")
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("want host:port or unix:PATH: %w", err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("host %q is not a loopback address", host)
+	}
+	return nil
+}
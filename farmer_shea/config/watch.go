@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Diff describes how a config differs from the one it replaces. Strategy
+// instances are matched by name.
+type Diff struct {
+	// Settings lists the top-level keys that changed, other than
+	// strategies. They only take effect after a restart.
+	Settings []string
+	Added    []string
+	Removed  []string
+	Changed  []string
+	// Details describes each changed value, e.g.
+	// "ma_crossover_eth: params.short_period: 10 -> 12".
+	Details []string
+}
+
+// Compare returns the differences between old and new.
+func Compare(old, new *Config) Diff {
+	var d Diff
+
+	ov, nv := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "strategies" {
+			continue
+		}
+		if o, n := ov.Field(i).Interface(), nv.Field(i).Interface(); !reflect.DeepEqual(o, n) {
+			d.Settings = append(d.Settings, key)
+			d.Details = append(d.Details, fmt.Sprintf("%s: %v -> %v", key, o, n))
+		}
+	}
+
+	before := make(map[string]StrategyConfig, len(old.Strategies))
+	for _, sc := range old.Strategies {
+		before[sc.Name] = sc
+	}
+	after := make(map[string]bool, len(new.Strategies))
+	for _, sc := range new.Strategies {
+		after[sc.Name] = true
+		prev, ok := before[sc.Name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, sc.Name)
+		case !reflect.DeepEqual(prev, sc):
+			d.Changed = append(d.Changed, sc.Name)
+			for _, detail := range compareStrategy(prev, sc) {
+				d.Details = append(d.Details, sc.Name+": "+detail)
+			}
+		}
+	}
+	for _, sc := range old.Strategies {
+		if !after[sc.Name] {
+			d.Removed = append(d.Removed, sc.Name)
+		}
+	}
+	return d
+}
+
+// compareStrategy describes the values that differ between two versions of
+// a strategy instance.
+func compareStrategy(old, new StrategyConfig) []string {
+	o, n := old.values(), new.values()
+	keys := make([]string, 0, len(o)+len(n))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	for k := range n {
+		if _, ok := o[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var details []string
+	for _, k := range keys {
+		ov, inOld := o[k]
+		nv, inNew := n[k]
+		switch {
+		case !inOld:
+			details = append(details, fmt.Sprintf("%s: set to %v", k, nv))
+		case !inNew:
+			details = append(details, fmt.Sprintf("%s: unset (was %v)", k, ov))
+		case !reflect.DeepEqual(ov, nv):
+			details = append(details, fmt.Sprintf("%s: %v -> %v", k, ov, nv))
+		}
+	}
+	return details
+}
+
+// values flattens c into its config keys.
+func (c StrategyConfig) values() map[string]any {
+	v := map[string]any{
+		"type":    c.Type,
+		"wallet":  c.Wallet,
+		"enabled": c.IsEnabled(),
+	}
+	if c.Schedule.Spec != "" {
+		v["schedule.spec"] = c.Schedule.Spec
+	}
+	if c.Schedule.Jitter != 0 {
+		v["schedule.jitter"] = c.Schedule.Jitter
+	}
+	for k, p := range c.Params {
+		v["params."+k] = p
+	}
+	return v
+}
+
+// Empty reports whether nothing changed.
+func (d Diff) Empty() bool {
+	return len(d.Settings)+len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+func (d Diff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	var parts []string
+	for _, part := range []struct {
+		label string
+		names []string
+	}{
+		{"added", d.Added},
+		{"removed", d.Removed},
+		{"changed", d.Changed},
+		{"settings (restart required)", d.Settings},
+	} {
+		if len(part.names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", part.label, strings.Join(part.names, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Change is a reload of the config file that was swapped in.
+type Change struct {
+	Old  *Config
+	New  *Config
+	Diff Diff
+}
+
+// reloadDelay is how long the file must stay unchanged before it is
+// reloaded, so that a half-written file is not picked up.
+const reloadDelay = 500 * time.Millisecond
+
+// Watcher reloads the config file when it changes. Each new version is
+// loaded with the same Options, including validation, and replaces the
+// current config only if it is valid.
+type Watcher struct {
+	opts Options
+	// reloading serializes reloads and their handlers.
+	reloading sync.Mutex
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	current  *Config
+	onChange []func(Change)
+	onError  []func(error)
+}
+
+// Watch starts watching the file cfg was loaded from with opts.
+func Watch(cfg *Config, opts Options) *Watcher {
+	w := &Watcher{opts: opts, current: cfg}
+
+	path := opts.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.OnConfigChange(func(fsnotify.Event) { w.schedule() })
+	v.WatchConfig()
+	return w
+}
+
+// Config returns the current config.
+func (w *Watcher) Config() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// OnChange registers fn to be called after a changed config is swapped in.
+// Reloads that change nothing are not reported.
+func (w *Watcher) OnChange(fn func(Change)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// OnError registers fn to be called when a reload fails. The current config
+// is kept.
+func (w *Watcher) OnError(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = append(w.onError, fn)
+}
+
+// schedule reloads the file once it has stopped changing.
+func (w *Watcher) schedule() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(reloadDelay, w.reload)
+}
+
+// reload loads the file again and, if it is valid and differs from the
+// current config, swaps it in. Handlers are called in order, one reload at
+// a time.
+func (w *Watcher) reload() {
+	w.reloading.Lock()
+	defer w.reloading.Unlock()
+
+	cfg, err := Load(w.opts)
+
+	w.mu.Lock()
+	onChange, onError := w.onChange, w.onError
+	old := w.current
+	if err == nil {
+		w.current = cfg
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		for _, fn := range onError {
+			fn(err)
+		}
+		return
+	}
+
+	change := Change{Old: old, New: cfg, Diff: Compare(old, cfg)}
+	if change.Diff.Empty() {
+		return
+	}
+	for _, fn := range onChange {
+		fn(change)
+	}
+}
@@ -0,0 +1,65 @@
+package dryrun
+
+import (
+	"context"
+	"sync"
+)
+
+type recorderKey struct{}
+
+// Step is a single action a strategy would have taken, together with the
+// result of simulating it.
+type Step struct {
+	Chain    string
+	Action   string
+	Accounts []string
+	// Result summarizes the simulation, e.g. compute units or estimated gas.
+	Result string
+	// Err is the error the simulation reported, if any. A failed simulation
+	// does not fail the strategy run in dry-run mode.
+	Err error
+}
+
+// Recorder collects the steps of a dry run.
+type Recorder struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// NewRecorder creates a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record appends a step to the plan.
+func (r *Recorder) Record(s Step) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, s)
+}
+
+// Steps returns the recorded steps in order.
+func (r *Recorder) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Step(nil), r.steps...)
+}
+
+// WithRecorder returns a copy of ctx in which chain clients simulate
+// transactions and record them to r instead of submitting them.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext returns the Recorder carried by ctx, if any. Chain clients must
+// not submit transactions when it returns true.
+func FromContext(ctx context.Context) (*Recorder, bool) {
+	r, ok := ctx.Value(recorderKey{}).(*Recorder)
+	return r, ok
+}
+
+// Enabled reports whether ctx is a dry run.
+func Enabled(ctx context.Context) bool {
+	_, ok := FromContext(ctx)
+	return ok
+}
@@ -0,0 +1,173 @@
+package errkind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Kind classifies an error by how the caller should react to it.
+type Kind int
+
+const (
+	// Unknown errors have not been classified. They are not retried, since
+	// they may have come after funds moved.
+	Unknown Kind = iota
+	// Transient errors, such as timeouts or dropped connections, are
+	// expected to succeed on retry.
+	Transient
+	// RateLimited errors mean the remote asked us to slow down.
+	RateLimited
+	// Revert errors mean the transaction reached the chain, or its
+	// simulation, and was rejected, e.g. for insufficient balance.
+	Revert
+	// Permanent errors, such as invalid configuration or malformed
+	// requests, will fail again no matter how often they are retried.
+	Permanent
+	// Unconfirmed errors mean a transaction may have been sent but its
+	// outcome is unknown, e.g. waiting for it to be mined timed out.
+	// Retrying could send it twice.
+	Unconfirmed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Transient:
+		return "transient"
+	case RateLimited:
+		return "rate_limited"
+	case Revert:
+		return "revert"
+	case Permanent:
+		return "permanent"
+	case Unconfirmed:
+		return "unconfirmed"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is an error annotated with its Kind.
+type Error struct {
+	Kind Kind
+	Err  error
+	// RetryAfter is the delay requested by the remote for RateLimited errors.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap annotates err with kind. It returns nil if err is nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Wrapf annotates a formatted error with kind.
+func Wrapf(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// RateLimit annotates err as RateLimited with the delay the remote asked for.
+func RateLimit(err error, retryAfter time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: RateLimited, Err: err, RetryAfter: retryAfter}
+}
+
+// Of returns the Kind of err. Errors that were not annotated are classified
+// from their type where possible.
+func Of(err error) Kind {
+	if err == nil {
+		return Unknown
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Transient
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return Transient
+	}
+
+	return Unknown
+}
+
+// Annotate wraps err with the Kind inferred by Of. Errors that are already
+// annotated, or that cannot be classified, are returned unchanged.
+func Annotate(err error) error {
+	var e *Error
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	if kind := Of(err); kind != Unknown {
+		return &Error{Kind: kind, Err: err}
+	}
+	return err
+}
+
+// RetryAfter returns the delay requested by a RateLimited error, or zero.
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+// Retryable reports whether an error of kind k may succeed on retry. Only
+// transient and rate-limited errors are, so errors that may follow a
+// transaction being sent must be classified to be retried.
+func (k Kind) Retryable() bool {
+	return k == Transient || k == RateLimited
+}
+
+// FromHTTPStatus classifies err by the HTTP status of the response that
+// caused it. retryAfter is the value of the Retry-After header, if any.
+func FromHTTPStatus(status int, retryAfter string, err error) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return RateLimit(err, ParseRetryAfter(retryAfter))
+	case status >= 500, status == http.StatusRequestTimeout:
+		return Wrap(Transient, err)
+	case status >= 400:
+		return Wrap(Permanent, err)
+	}
+	return err
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date. It returns zero if the header is empty or invalid.
+func ParseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
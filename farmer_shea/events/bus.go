@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultBuffer is the number of events a subscription queues before it
+// starts dropping them.
+const DefaultBuffer = 256
+
+// Bus delivers published events to subscribers. Publishing never blocks:
+// each subscription has its own queue and handler goroutine, and a
+// subscriber that falls behind misses events rather than stalling the
+// publisher. It is safe for concurrent use.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []*Subscription
+}
+
+// NewBus creates a Bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscription is a handler registered with Subscribe.
+type Subscription struct {
+	bus     *Bus
+	topics  map[Topic]bool
+	queue   chan Event
+	done    chan struct{}
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Subscribe calls fn with each published event of the given topics, or of
+// every topic if none are given. Events are delivered in the order they
+// were published, one at a time, on a goroutine owned by the subscription.
+// Up to buffer events are queued; further events are dropped until fn
+// catches up.
+func (b *Bus) Subscribe(buffer int, fn func(Event), topics ...Topic) *Subscription {
+	s := &Subscription{
+		bus:   b,
+		queue: make(chan Event, buffer),
+		done:  make(chan struct{}),
+	}
+	if len(topics) > 0 {
+		s.topics = make(map[Topic]bool, len(topics))
+		for _, t := range topics {
+			s.topics[t] = true
+		}
+	}
+	go func() {
+		defer close(s.done)
+		for e := range s.queue {
+			fn(e)
+		}
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, s)
+	return s
+}
+
+// Publish stamps e with the current time, if unset, and queues it for every
+// matching subscriber.
+func (b *Bus) Publish(e Event) {
+	if m := e.meta(); m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if s.topics != nil && !s.topics[e.Topic()] {
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			// Log the first drop and then exponentially less often.
+			if n := s.dropped.Add(1); n&(n-1) == 0 {
+				log.Warn().Uint64("dropped", n).Str("topic", string(e.Topic())).Msg("Event subscriber is falling behind; dropping events")
+			}
+		}
+	}
+}
+
+// Close unsubscribes every subscriber and waits for them to handle the
+// events already queued.
+func (b *Bus) Close() {
+	b.mu.RLock()
+	subs := append([]*Subscription(nil), b.subs...)
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// Dropped returns the number of events dropped because the subscription's
+// queue was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes s and waits for it to handle the events already
+// queued.
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		for i, sub := range b.subs {
+			if sub == s {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				break
+			}
+		}
+		close(s.queue)
+	}
+	b.mu.Unlock()
+	<-s.done
+}
+
+type scopeKey struct{}
+
+// scope ties the events published under a context to a bus and a strategy.
+type scope struct {
+	bus      *Bus
+	strategy string
+}
+
+// WithBus returns a copy of ctx in which Publish sends events to b on
+// behalf of the named strategy, which may be empty.
+func WithBus(ctx context.Context, b *Bus, strategy string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{bus: b, strategy: strategy})
+}
+
+// Publish publishes e to the bus carried by ctx, attributing it to the
+// context's strategy unless e names one. It is a no-op if ctx carries no
+// bus.
+func Publish(ctx context.Context, e Event) {
+	sc, ok := ctx.Value(scopeKey{}).(scope)
+	if !ok {
+		return
+	}
+	if m := e.meta(); m.Strategy == "" {
+		m.Strategy = sc.strategy
+	}
+	sc.bus.Publish(e)
+}
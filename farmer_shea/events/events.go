@@ -0,0 +1,279 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/store"
+)
+
+// Topic names a type of event.
+type Topic string
+
+const (
+	TopicStrategyStarted  Topic = "strategy_started"
+	TopicStrategyFinished Topic = "strategy_finished"
+	TopicStrategyFailed   Topic = "strategy_failed"
+	TopicTxSubmitted      Topic = "tx_submitted"
+	TopicTxConfirmed      Topic = "tx_confirmed"
+	TopicSignalGenerated  Topic = "signal_generated"
+	TopicPositionChanged  Topic = "position_changed"
+	TopicPriceUpdated     Topic = "price_updated"
+	TopicRiskBreached     Topic = "risk_breached"
+	TopicStopLoss         Topic = "stop_loss"
+	TopicKillSwitch       Topic = "kill_switch"
+	TopicRebalance        Topic = "rebalance"
+)
+
+// Event is published on a Bus. Events are published as pointers, which
+// subscribers share and must not modify.
+type Event interface {
+	Topic() Topic
+	// String describes the event in a single line, without its strategy.
+	String() string
+	meta() *Meta
+}
+
+// Meta holds the fields common to every event.
+type Meta struct {
+	Time time.Time
+	// Strategy is the strategy the event concerns, if any.
+	Strategy string
+}
+
+func (m *Meta) meta() *Meta { return m }
+
+// StrategyOf returns the strategy e concerns, or "" if none.
+func StrategyOf(e Event) string {
+	return e.meta().Strategy
+}
+
+// StrategyStarted is published when a strategy run starts.
+type StrategyStarted struct {
+	Meta
+	Wallet string
+	// RunID is the run's ID in the state store, or 0 if it is not
+	// recorded.
+	RunID  uint64
+	DryRun bool
+}
+
+func (*StrategyStarted) Topic() Topic { return TopicStrategyStarted }
+
+func (e *StrategyStarted) String() string {
+	mode := ""
+	if e.DryRun {
+		mode = " (dry run)"
+	}
+	return fmt.Sprintf("started with wallet %s%s", e.Wallet, mode)
+}
+
+// StrategyFinished is published when a strategy run succeeds or is
+// cancelled.
+type StrategyFinished struct {
+	Meta
+	Duration  time.Duration
+	Cancelled bool
+}
+
+func (*StrategyFinished) Topic() Topic { return TopicStrategyFinished }
+
+func (e *StrategyFinished) String() string {
+	if e.Cancelled {
+		return fmt.Sprintf("cancelled after %s", e.Duration.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("finished in %s", e.Duration.Round(time.Millisecond))
+}
+
+// StrategyFailed is published when a strategy run fails.
+type StrategyFailed struct {
+	Meta
+	Duration time.Duration
+	Err      error
+	// Kind is the errkind of Err.
+	Kind string
+}
+
+func (*StrategyFailed) Topic() Topic { return TopicStrategyFailed }
+
+func (e *StrategyFailed) String() string {
+	return fmt.Sprintf("failed after %s (%s): %v", e.Duration.Round(time.Millisecond), e.Kind, e.Err)
+}
+
+// TxSubmitted is published when a transaction is sent.
+type TxSubmitted struct {
+	Meta
+	Chain chain.ID
+	Hash  string
+	// Action describes what the transaction does.
+	Action string
+}
+
+func (*TxSubmitted) Topic() Topic { return TopicTxSubmitted }
+
+func (e *TxSubmitted) String() string {
+	return fmt.Sprintf("submitted %s tx %s: %s", e.Chain, e.Hash, e.Action)
+}
+
+// TxConfirmed is published when a submitted transaction settles, whether it
+// succeeded or not.
+type TxConfirmed struct {
+	Meta
+	Chain chain.ID
+	Hash  string
+	// Fee is in the smallest unit of FeeAsset and empty if unknown.
+	Fee      string
+	FeeAsset string
+	// Err is set if the transaction failed.
+	Err error
+}
+
+func (*TxConfirmed) Topic() Topic { return TopicTxConfirmed }
+
+func (e *TxConfirmed) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s tx %s failed: %v", e.Chain, e.Hash, e.Err)
+	}
+	if e.Fee == "" {
+		return fmt.Sprintf("%s tx %s confirmed", e.Chain, e.Hash)
+	}
+	return fmt.Sprintf("%s tx %s confirmed, fee %s %s", e.Chain, e.Hash, e.Fee, e.FeeAsset)
+}
+
+// SignalGenerated is published when a strategy derives a trading signal.
+type SignalGenerated struct {
+	Meta
+	Symbol string
+	// Signal is e.g. "buy" or "sell".
+	Signal string
+	// Values holds the indicator values behind the signal.
+	Values map[string]float64
+}
+
+func (*SignalGenerated) Topic() Topic { return TopicSignalGenerated }
+
+func (e *SignalGenerated) String() string {
+	return fmt.Sprintf("%s signal for %s%s", e.Signal, e.Symbol, formatValues(e.Values))
+}
+
+// PositionChanged is published when a strategy opens, adjusts or closes a
+// position.
+type PositionChanged struct {
+	Meta
+	Position store.Position
+	Closed   bool
+}
+
+func (*PositionChanged) Topic() Topic { return TopicPositionChanged }
+
+func (e *PositionChanged) String() string {
+	p := e.Position
+	if e.Closed {
+		return fmt.Sprintf("closed %s position %s", p.Protocol, p.ID)
+	}
+	return fmt.Sprintf("%s position %s holds %s of %s", p.Protocol, p.ID, p.Amount, p.Asset)
+}
+
+// PriceUpdated is published when a new price is observed for an asset.
+type PriceUpdated struct {
+	Meta
+	Chain chain.ID
+	Asset string
+	// Price is in USD.
+	Price float64
+}
+
+func (*PriceUpdated) Topic() Topic { return TopicPriceUpdated }
+
+func (e *PriceUpdated) String() string {
+	return fmt.Sprintf("%s on %s is %.4f USD", e.Asset, e.Chain, e.Price)
+}
+
+// RiskBreached is published when an action is rejected by a risk check.
+type RiskBreached struct {
+	Meta
+	// Rule names the check that rejected the action.
+	Rule string
+	// Action describes the rejected action.
+	Action string
+	Reason string
+}
+
+func (*RiskBreached) Topic() Topic { return TopicRiskBreached }
+
+func (e *RiskBreached) String() string {
+	return fmt.Sprintf("risk check %s rejected %s: %s", e.Rule, e.Action, e.Reason)
+}
+
+// StopLoss is published when a stop-loss withdraws funds from a position.
+type StopLoss struct {
+	Meta
+	// Action describes the withdrawal.
+	Action string
+	Reason string
+}
+
+func (*StopLoss) Topic() Topic { return TopicStopLoss }
+
+func (e *StopLoss) String() string {
+	return fmt.Sprintf("stop-loss: %s: %s", e.Action, e.Reason)
+}
+
+// KillSwitch is published when the kill switch that halts every strategy
+// is engaged or lifted.
+type KillSwitch struct {
+	Meta
+	Engaged bool
+	Reason  string
+	// Unwind is set if positions are to be unwound.
+	Unwind bool
+}
+
+func (*KillSwitch) Topic() Topic { return TopicKillSwitch }
+
+func (e *KillSwitch) String() string {
+	switch {
+	case !e.Engaged:
+		return fmt.Sprintf("kill switch lifted (was: %s)", e.Reason)
+	case e.Unwind:
+		return fmt.Sprintf("kill switch engaged, unwinding positions: %s", e.Reason)
+	}
+	return fmt.Sprintf("kill switch engaged: %s", e.Reason)
+}
+
+// Rebalance is published when the capital allocator finds that a strategy
+// has drifted from its target, for each move of funds that would restore
+// it. Its Strategy is the strategy funds move from.
+type Rebalance struct {
+	Meta
+	Chain chain.ID
+	// To is the strategy funds move to.
+	To  string
+	USD float64
+}
+
+func (*Rebalance) Topic() Topic { return TopicRebalance }
+
+func (e *Rebalance) String() string {
+	return fmt.Sprintf("rebalance: move $%.2f to %s on %s", e.USD, e.To, e.Chain)
+}
+
+// formatValues formats indicator values in sorted order.
+func formatValues(values map[string]float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%.4f", k, values[k])
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
@@ -1,59 +1,498 @@
 package executor
 
 import (
-	"crypto/ecdsa"
+	"context"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/dryrun"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/schedule"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/store"
 	"github.com/sheawinkler/farmer-shea/strategy"
 	"github.com/sheawinkler/farmer-shea/wallet"
 )
 
 const (
-	defaultRetryAttempts = 3
-	defaultRetryDelay    = 5 * time.Second
+	defaultRetryAttempts   = 3
+	defaultRetryDelay      = 5 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
 )
 
+// Interruption describes a strategy that was still executing when the
+// executor was stopped.
+type Interruption struct {
+	Strategy string
+	Stage    string
+	Since    time.Time
+	// Abandoned is true if the strategy did not return before the shutdown
+	// deadline.
+	Abandoned bool
+}
+
 // Executor manages the execution of strategies.
 type Executor struct {
-	strategies []strategy.Strategy
-	privateKey *ecdsa.PrivateKey
-	wallet     wallet.Wallet
+	manager *strategy.Manager
+	wallets *wallet.Set
+
+	// ShutdownTimeout bounds how long Stop waits for running strategies.
+	ShutdownTimeout time.Duration
+	// Schedules overrides the schedule of strategies by name. Use
+	// SetSchedule to change it once the executor has started.
+	Schedules map[string]schedule.Schedule
+	// LastRuns records when each strategy last ran.
+	LastRuns schedule.Store
+	// Retry controls how failed runs are retried.
+	Retry RetryPolicy
+	// DryRun makes strategies simulate their transactions instead of
+	// sending them. The executor logs the resulting plan after each run.
+	DryRun bool
+	// Checkers vet every action before it is submitted.
+	Checkers []Checker
+	// Approver, if set, must approve every live action before it is
+	// submitted.
+	Approver Approver
+	// ManualApprover, if set, must also approve every live action of a run
+	// triggered with Trigger.
+	ManualApprover Approver
+	// Store, if set, records each run along with the transactions and
+	// positions strategies report during it.
+	Store *store.Store
+	// Events, if set, receives the lifecycle events of each run. Strategies
+	// and chain clients publish theirs to it too.
+	Events *events.Bus
+	// Prices, if set, values the ledger entries strategies record for PnL
+	// accounting.
+	Prices pnl.Pricer
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	// runners wakes the runner of each strategy when it is reloaded.
+	runners     map[string]chan struct{}
+	triggered   map[string]bool
+	unwinding   map[string]bool
+	halted      bool
+	status      map[string]*Status
+	wg          sync.WaitGroup
+	inFlight    map[string]*strategy.Progress
+	interrupted []Interruption
 }
 
-// New creates a new Executor.
-func New(strategies []strategy.Strategy, w wallet.Wallet, pk *ecdsa.PrivateKey) *Executor {
+// New creates a new Executor. Each strategy runs with the keys of the wallet
+// the manager assigns it.
+func New(manager *strategy.Manager, wallets *wallet.Set) *Executor {
 	return &Executor{
-		strategies: strategies,
-		wallet:     w,
-		privateKey: pk,
+		manager:         manager,
+		wallets:         wallets,
+		ShutdownTimeout: defaultShutdownTimeout,
+		Schedules:       make(map[string]schedule.Schedule),
+		LastRuns:        schedule.NewMemoryStore(),
+		Retry:           DefaultRetryPolicy(),
+		runners:         make(map[string]chan struct{}),
+		triggered:       make(map[string]bool),
+		unwinding:       make(map[string]bool),
+		status:          make(map[string]*Status),
+		inFlight:        make(map[string]*strategy.Progress),
+	}
+}
+
+// Start starts the execution of all strategies. Strategies run until ctx is
+// cancelled or Stop is called.
+func (e *Executor) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ctx = ctx
+	e.cancel = cancel
+
+	for _, s := range e.manager.Strategies() {
+		e.startRunner(s.Name())
 	}
 }
 
-// Run starts the execution of all strategies.
-func (e *Executor) Run() {
-	for _, s := range e.strategies {
-		go e.runStrategy(s)
+// Reload makes the executor pick up a change to the named strategy in the
+// manager or to its schedule. A run in progress finishes with the old
+// instance and the next run uses the new one. Strategies added to the
+// manager are started; strategies removed from it stop once their current
+// run, if any, returns.
+func (e *Executor) Reload(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ctx == nil || e.ctx.Err() != nil {
+		return
+	}
+	if _, ok := e.runners[name]; ok {
+		e.wake(name)
+		return
+	}
+	if _, ok := e.manager.Get(name); ok {
+		e.startRunner(name)
 	}
 }
 
-func (e *Executor) runStrategy(s strategy.Strategy) {
+// SetSchedule overrides the schedule of the named strategy. A nil schedule
+// restores the one the strategy declares. Call Reload for a running
+// strategy to pick it up.
+func (e *Executor) SetSchedule(name string, sched schedule.Schedule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if sched == nil {
+		delete(e.Schedules, name)
+		return
+	}
+	e.Schedules[name] = sched
+}
+
+// startRunner starts running the named strategy. e.mu must be held.
+func (e *Executor) startRunner(name string) {
+	if st, ok := e.status[name]; ok {
+		st.Disabled = false
+	}
+	wake := make(chan struct{}, 1)
+	e.runners[name] = wake
+	e.wg.Add(1)
+	go e.runStrategy(e.ctx, name, wake)
+}
+
+// Stop cancels all running strategies and waits for them to return, up to
+// ShutdownTimeout. It returns the strategies that were interrupted while
+// executing, along with the last stage each of them reported.
+func (e *Executor) Stop() []Interruption {
+	e.mu.Lock()
+	cancel := e.cancel
+	e.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(e.ShutdownTimeout):
+		log.Warn().Dur("timeout", e.ShutdownTimeout).Msg("Timed out waiting for strategies to stop")
+	}
+
+	e.mu.Lock()
+	interrupted := append([]Interruption(nil), e.interrupted...)
+	for name, p := range e.inFlight {
+		stage, since := p.Stage()
+		interrupted = append(interrupted, Interruption{Strategy: name, Stage: stage, Since: since, Abandoned: true})
+	}
+	e.mu.Unlock()
+
+	for _, in := range interrupted {
+		log.Warn().
+			Str("strategy", in.Strategy).
+			Str("stage", in.Stage).
+			Time("since", in.Since).
+			Bool("abandoned", in.Abandoned).
+			Msg("Strategy interrupted during shutdown")
+	}
+
+	return interrupted
+}
+
+// scheduleFor returns the schedule configured for s, falling back to the one
+// s declares and then to the default interval. e.mu must be held.
+func (e *Executor) scheduleFor(s strategy.Strategy) schedule.Schedule {
+	if sched, ok := e.Schedules[s.Name()]; ok {
+		return sched
+	}
+	if sc, ok := s.(strategy.Scheduled); ok {
+		return sc.Schedule()
+	}
+	return schedule.Interval(schedule.DefaultInterval)
+}
+
+// current returns the named strategy and its schedule, and whether it is
+// paused or halted. If the strategy has been removed, it unregisters the runner and
+// returns false.
+func (e *Executor) current(name string) (strategy.Strategy, schedule.Schedule, bool, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.manager.Get(name)
+	if !ok {
+		delete(e.runners, name)
+		delete(e.triggered, name)
+		delete(e.unwinding, name)
+		if st, ok := e.status[name]; ok && !st.Disabled {
+			delete(e.status, name)
+		}
+		return nil, nil, false, false
+	}
+	st, ok := e.status[name]
+	return s, e.scheduleFor(s), e.halted || ok && st.Paused, true
+}
+
+// complete unregisters the runner of the named strategy once its schedule
+// is complete, unless it has been triggered or asked to unwind meanwhile.
+func (e *Executor) complete(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.triggered[name] || e.unwinding[name] {
+		return false
+	}
+	delete(e.runners, name)
+	return true
+}
+
+// runStrategy runs the named strategy on its schedule until ctx is
+// cancelled, the schedule completes or the strategy is removed. Each run
+// uses the strategy's current instance and schedule.
+func (e *Executor) runStrategy(ctx context.Context, name string, wake <-chan struct{}) {
+	defer e.wg.Done()
+
+	// failedRuns counts the runs in a row that failed with an error worth
+	// retrying.
+	var failedRuns int
 	for {
-		log.Info().Str("strategy", s.Name()).Msg("Executing strategy")
-		var err error
-		for i := 0; i < defaultRetryAttempts; i++ {
-			err = s.Execute(e.wallet, e.privateKey)
-			if err == nil {
-				break
+		s, sched, paused, ok := e.current(name)
+		if !ok {
+			log.Info().Str("strategy", name).Msg("Strategy removed")
+			return
+		}
+
+		runCtx, plan := ctx, s.Plan
+		unwinding := false
+		if u, ok := s.(strategy.Unwinder); ok && e.takeUnwind(name) {
+			log.Warn().Str("strategy", name).Msg("Unwinding strategy positions")
+			runCtx, plan, unwinding = strategy.WithUnwind(ctx), u.Unwind, true
+		} else if e.takeTrigger(name) {
+			log.Info().Str("strategy", name).Msg("Running strategy by hand")
+			runCtx = withManual(ctx)
+		} else {
+			next, ok := sched.Next(e.LastRuns.LastRun(name), time.Now())
+			if paused || !ok {
+				next = time.Time{}
+			}
+			e.update(name, func(st *Status) { st.NextRun = next })
+
+			if !paused && !ok {
+				if e.complete(name) {
+					log.Info().Str("strategy", name).Str("schedule", sched.String()).Msg("Strategy schedule complete")
+					return
+				}
+				continue
+			}
+
+			var woken bool
+			var err error
+			if paused {
+				log.Debug().Str("strategy", name).Msg("Strategy paused")
+				woken, err = true, waitForWake(ctx, wake)
+			} else {
+				log.Debug().Str("strategy", name).Time("next", next).Msg("Scheduled strategy")
+				woken, err = sleepOrWake(ctx, time.Until(next), wake)
+			}
+			if err != nil {
+				return
+			}
+			if woken {
+				log.Debug().Str("strategy", name).Msg("Strategy woken")
+				continue
+			}
+		}
+
+		started := time.Now()
+		err := e.execute(runCtx, s, plan)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A run that failed with an error worth retrying is not recorded,
+		// so that a once schedule runs again and an interval schedule
+		// keeps its cadence from the last good run. Such runs are retried
+		// with backoff up to the retry policy's attempts; after that the
+		// run is recorded and the strategy waits for its schedule. A
+		// halted strategy never runs on its schedule, so a failed unwind
+		// is asked for again to be retried.
+		if err != nil && errkind.Of(err).Retryable() {
+			failedRuns++
+			if delay, retry := e.Retry.Backoff(failedRuns, err); retry {
+				if unwinding {
+					e.requestUnwind(name)
+				}
+				log.Debug().Str("strategy", name).Int("failed_runs", failedRuns).Dur("delay", delay).Msg("Strategy run failed; retrying after delay")
+				if _, err := sleepOrWake(ctx, delay, wake); err != nil {
+					return
+				}
+				continue
+			}
+			if unwinding {
+				log.Error().Err(err).Str("strategy", name).Int("failed_runs", failedRuns).Msg("Unwind keeps failing; halt with unwind again to retry")
+			} else {
+				log.Warn().Str("strategy", name).Int("failed_runs", failedRuns).Msg("Strategy keeps failing; waiting for its next scheduled run")
 			}
-			log.Error().Err(err).Str("strategy", s.Name()).Int("attempt", i+1).Msg("Error executing strategy, retrying...")
-			time.Sleep(defaultRetryDelay)
 		}
+		failedRuns = 0
+		if err := e.LastRuns.SetLastRun(name, started); err != nil {
+			log.Error().Err(err).Str("strategy", name).Msg("Failed to record last run")
+		}
+	}
+}
+
+// planFunc decides the actions of a run: a strategy's Plan, or Unwind.
+type planFunc func(ctx context.Context, keys *signer.Keyring) ([]action.Action, error)
+
+// execute runs s once with plan, recording the run and reporting its
+// outcome, which it returns.
+func (e *Executor) execute(ctx context.Context, s strategy.Strategy, plan planFunc) error {
+	p := strategy.NewProgress()
+	e.mu.Lock()
+	e.inFlight[s.Name()] = p
+	e.mu.Unlock()
+
+	// cancelled is set if the run returned early because ctx was
+	// cancelled, rather than after finishing its actions.
+	var cancelled bool
+	defer func() {
+		e.mu.Lock()
+		delete(e.inFlight, s.Name())
+		if cancelled {
+			stage, since := p.Stage()
+			e.interrupted = append(e.interrupted, Interruption{Strategy: s.Name(), Stage: stage, Since: since})
+		}
+		e.mu.Unlock()
+	}()
+
+	ctx = strategy.WithProgress(ctx, p)
+	if e.Prices != nil {
+		ctx = pnl.WithPricer(ctx, e.Prices)
+	}
+	if e.Events != nil {
+		ctx = events.WithBus(ctx, e.Events, s.Name())
+	}
+
+	if e.DryRun {
+		rec := dryrun.NewRecorder()
+		ctx = dryrun.WithRecorder(ctx, rec)
+		defer logPlan(s.Name(), rec)
+	}
 
+	var run store.Run
+	if e.Store != nil {
+		var err error
+		run, err = e.Store.StartRun(store.Run{Strategy: s.Name(), Wallet: e.manager.Wallet(s.Name()), DryRun: e.DryRun})
 		if err != nil {
-			log.Error().Err(err).Str("strategy", s.Name()).Msg("Strategy execution failed after multiple attempts")
+			log.Error().Err(err).Str("strategy", s.Name()).Msg("Failed to record run")
+		} else {
+			ctx = store.WithRun(ctx, e.Store, run)
+		}
+	}
+
+	log.Info().Str("strategy", s.Name()).Bool("dryRun", e.DryRun).Msg("Executing strategy")
+	wallet := e.manager.Wallet(s.Name())
+	events.Publish(ctx, &events.StrategyStarted{Wallet: wallet, RunID: run.ID, DryRun: e.DryRun})
+	started := time.Now()
+	e.update(s.Name(), func(st *Status) {
+		st.Running = true
+		st.LastRun = started
+	})
+	err := e.run(ctx, s, plan)
+	cancelled = err != nil && ctx.Err() != nil
+	e.update(s.Name(), func(st *Status) {
+		st.Running = false
+		switch {
+		case cancelled:
+		case err != nil:
+			st.LastError = err.Error()
+			st.Failures++
+		default:
+			st.Failures = 0
+		}
+	})
+
+	status := store.RunSucceeded
+	switch {
+	case cancelled:
+		status = store.RunCancelled
+		log.Info().Str("strategy", s.Name()).Msg("Strategy execution cancelled")
+		events.Publish(ctx, &events.StrategyFinished{Duration: time.Since(started), Cancelled: true})
+	case err != nil:
+		status = store.RunFailed
+		kind := errkind.Of(err).String()
+		log.Error().Err(err).Str("strategy", s.Name()).Str("kind", kind).Msg("Strategy execution failed")
+		events.Publish(ctx, &events.StrategyFailed{Duration: time.Since(started), Err: err, Kind: kind})
+	default:
+		events.Publish(ctx, &events.StrategyFinished{Duration: time.Since(started)})
+	}
+
+	if run.ID != 0 {
+		if err := e.Store.FinishRun(run.ID, status, err); err != nil {
+			log.Error().Err(err).Str("strategy", s.Name()).Msg("Failed to record run outcome")
+		}
+	}
+	return err
+}
+
+// logPlan logs the steps a strategy would have taken in a dry run.
+func logPlan(name string, rec *dryrun.Recorder) {
+	steps := rec.Steps()
+	log.Info().Str("strategy", name).Int("steps", len(steps)).Msg("Dry-run plan")
+	for i, step := range steps {
+		ev := log.Info()
+		if step.Err != nil {
+			ev = log.Warn().Err(step.Err)
 		}
+		ev.Str("strategy", name).
+			Int("step", i+1).
+			Str("chain", step.Chain).
+			Str("action", step.Action).
+			Strs("accounts", step.Accounts).
+			Str("result", step.Result).
+			Msg("Dry-run step")
+	}
+}
+
+// sleepOrWake is like sleep, but also returns early, reporting true, when
+// wake receives.
+func sleepOrWake(ctx context.Context, d time.Duration, wake <-chan struct{}) (bool, error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-wake:
+		return true, nil
+	case <-t.C:
+		return false, nil
+	}
+}
+
+// waitForWake waits until wake receives or ctx is cancelled.
+func waitForWake(ctx context.Context, wake <-chan struct{}) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wake:
+		return nil
+	}
+}
+
+// sleep waits for d or until ctx is cancelled, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
 
-		time.Sleep(5 * time.Minute) // Execute every 5 minutes
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
 }
@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/strategy"
+)
+
+// ErrHalted is returned when triggering a strategy while the executor is
+// halted.
+var ErrHalted = errors.New("strategies are halted")
+
+// Halt stops every strategy from starting new runs until Lift is called. A
+// run in progress is not interrupted; a Checker should reject its remaining
+// actions. If unwind is set, each strategy that implements
+// strategy.Unwinder unwinds its positions once its current run, if any,
+// returns. Halt returns the strategies that cannot unwind.
+func (e *Executor) Halt(unwind bool) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.halted = true
+
+	var cannot []string
+	for _, s := range e.manager.Strategies() {
+		name := s.Name()
+		if unwind {
+			if _, ok := s.(strategy.Unwinder); ok {
+				e.unwinding[name] = true
+				if _, ok := e.runners[name]; !ok && e.ctx != nil && e.ctx.Err() == nil {
+					e.startRunner(name)
+				}
+			} else {
+				cannot = append(cannot, name)
+			}
+		}
+		e.wake(name)
+	}
+	sort.Strings(cannot)
+	log.Warn().Bool("unwind", unwind).Msg("Strategies halted")
+	return cannot
+}
+
+// Lift undoes Halt. Strategies paused before the halt stay paused.
+func (e *Executor) Lift() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.halted = false
+	for _, s := range e.manager.Strategies() {
+		e.wake(s.Name())
+	}
+	log.Info().Msg("Strategies resumed after halt")
+}
+
+// Halted reports whether Halt is in effect.
+func (e *Executor) Halted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.halted
+}
+
+// takeUnwind reports whether the named strategy should unwind its
+// positions, and clears the request.
+func (e *Executor) takeUnwind(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	unwind := e.unwinding[name]
+	delete(e.unwinding, name)
+	return unwind
+}
+
+// requestUnwind asks the named strategy to unwind on its next run, e.g.
+// again after an unwind failed.
+func (e *Executor) requestUnwind(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.unwinding[name] = true
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/strategy"
+	"github.com/sheawinkler/farmer-shea/wallet"
+)
+
+// ErrNotApproved is returned when an Approver declines an action.
+var ErrNotApproved = errors.New("action not approved")
+
+// Checker vets an action before it is submitted, e.g. against risk limits.
+// Returning an error rejects the action; the error should explain why.
+type Checker interface {
+	Check(ctx context.Context, a action.Action) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context, a action.Action) error
+
+// Check calls f(ctx, a).
+func (f CheckerFunc) Check(ctx context.Context, a action.Action) error {
+	return f(ctx, a)
+}
+
+// checkerName names c for reporting: its Name method if it has one, or else
+// its type.
+func checkerName(c Checker) string {
+	if n, ok := c.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+// Approver decides whether a checked action may be submitted, e.g. by asking
+// an operator.
+type Approver interface {
+	Approve(ctx context.Context, a action.Action) (bool, error)
+}
+
+// run plans a strategy with plan and submits its actions in order. It stops
+// at the first action that fails, since later actions may depend on it.
+func (e *Executor) run(ctx context.Context, s strategy.Strategy, plan planFunc) error {
+	w, err := e.wallets.Get(e.manager.Wallet(s.Name()))
+	if err != nil {
+		return err
+	}
+
+	var actions []action.Action
+	err = e.withRetry(ctx, s.Name(), "plan", func() error {
+		var err error
+		actions, err = plan(ctx, w.Keys)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+
+	log.Info().Str("strategy", s.Name()).Str("wallet", w.Name).Int("actions", len(actions)).Msg("Strategy planned")
+	for _, a := range actions {
+		a.Strategy = s.Name()
+		a.Wallet = w.Name
+		err := e.submit(ctx, s, w, a)
+		e.recordAction(ctx, a, err)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Kind, err)
+		}
+	}
+	return nil
+}
+
+// submit validates, checks and, once approved, applies a single action.
+func (e *Executor) submit(ctx context.Context, s strategy.Strategy, w *wallet.Wallet, a action.Action) error {
+	logger := log.With().Str("strategy", s.Name()).Str("wallet", w.Name).Str("action", a.String()).Str("rationale", a.Rationale).Logger()
+
+	if err := a.Validate(); err != nil {
+		logger.Warn().Err(err).Msg("Rejected invalid action")
+		return err
+	}
+
+	for _, c := range e.Checkers {
+		if err := c.Check(ctx, a); err != nil {
+			logger.Warn().Err(err).Msg("Action rejected by check")
+			events.Publish(ctx, &events.RiskBreached{Rule: checkerName(c), Action: a.String(), Reason: err.Error()})
+			return errkind.Wrap(errkind.Permanent, err)
+		}
+	}
+
+	approvers := []Approver{e.Approver}
+	if isManual(ctx) {
+		approvers = append(approvers, e.ManualApprover)
+	}
+	for _, approver := range approvers {
+		if approver == nil || e.DryRun {
+			continue
+		}
+		ok, err := approver.Approve(ctx, a)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logger.Warn().Msg("Action not approved")
+			return errkind.Wrap(errkind.Permanent, ErrNotApproved)
+		}
+	}
+
+	logger.Info().Msg("Submitting action")
+	return e.withRetry(ctx, s.Name(), "apply", func() error {
+		return s.Apply(ctx, w.Keys, a)
+	})
+}
+
+// withRetry calls fn until it succeeds, ctx is cancelled or the retry policy
+// gives up.
+func (e *Executor) withRetry(ctx context.Context, name, stage string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		delay, retry := e.Retry.Backoff(attempt, err)
+		if !retry {
+			return err
+		}
+		log.Error().Err(err).
+			Str("strategy", name).
+			Str("stage", stage).
+			Str("kind", errkind.Of(err).String()).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("Error executing strategy, retrying...")
+		if sleep(ctx, delay) != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/errkind"
+)
+
+// RetryPolicy controls how the executor retries a failed strategy run.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+	// Multiplier grows the delay after each attempt.
+	Multiplier float64
+	// Jitter is the fraction of each delay that is randomized.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: defaultRetryAttempts,
+		BaseDelay:   defaultRetryDelay,
+		MaxDelay:    2 * time.Minute,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+// Backoff returns how long to wait after the given failed attempt (starting
+// at 1) and whether the run should be retried at all. Only transient and
+// rate-limited errors are retried; rate-limited errors wait at least as
+// long as the remote asked.
+func (p RetryPolicy) Backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	kind := errkind.Of(err)
+	if !kind.Retryable() {
+		return 0, false
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	d := time.Duration(delay)
+	if kind == errkind.RateLimited {
+		if after := errkind.RetryAfter(err); after > d {
+			d = after
+		}
+	}
+
+	return d, true
+}
@@ -0,0 +1,228 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+)
+
+// ErrUnknownStrategy is returned when controlling a strategy the manager
+// does not have.
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+// maxRecentActions bounds the actions kept in each strategy's Status.
+const maxRecentActions = 20
+
+// Status describes a strategy's runner.
+type Status struct {
+	Strategy string `json:"strategy"`
+	Wallet   string `json:"wallet"`
+	Running  bool   `json:"running"`
+	// Paused strategies skip their scheduled runs until resumed. They can
+	// still be triggered by hand.
+	Paused bool `json:"paused"`
+	// Halted strategies skip their runs until the executor's halt is
+	// lifted.
+	Halted bool `json:"halted"`
+	// Disabled strategies have been removed from the manager with Disable.
+	Disabled bool      `json:"disabled"`
+	LastRun  time.Time `json:"last_run"`
+	// NextRun is zero if no run is scheduled, e.g. while paused or once
+	// the schedule is complete.
+	NextRun   time.Time `json:"next_run"`
+	LastError string    `json:"last_error,omitempty"`
+	// Failures counts the runs that have failed in a row.
+	Failures int `json:"failures"`
+	// Recent lists the latest actions submitted, oldest first.
+	Recent []ActionResult `json:"recent"`
+}
+
+// State summarizes the status in a word.
+func (s Status) State() string {
+	switch {
+	case s.Disabled:
+		return "disabled"
+	case s.Running:
+		return "running"
+	case s.Halted:
+		return "halted"
+	case s.Paused:
+		return "paused"
+	case s.NextRun.IsZero():
+		return "idle"
+	default:
+		return "scheduled"
+	}
+}
+
+// ActionResult is the outcome of submitting an action.
+type ActionResult struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Rationale string    `json:"rationale,omitempty"`
+	// Manual is true for actions of a run triggered by hand.
+	Manual bool `json:"manual"`
+	// Err is empty if the action was applied.
+	Err string `json:"error,omitempty"`
+}
+
+// Statuses returns the status of every strategy in the manager, in order,
+// followed by the disabled ones by name.
+func (e *Executor) Statuses() []Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var statuses []Status
+	for _, s := range e.manager.Strategies() {
+		statuses = append(statuses, e.statusOf(s.Name()))
+	}
+	var disabled []Status
+	for name, st := range e.status {
+		if st.Disabled {
+			disabled = append(disabled, e.statusOf(name))
+		}
+	}
+	sort.Slice(disabled, func(i, j int) bool { return disabled[i].Strategy < disabled[j].Strategy })
+	return append(statuses, disabled...)
+}
+
+// statusOf returns a copy of the named strategy's status. e.mu must be held.
+func (e *Executor) statusOf(name string) Status {
+	st := Status{Strategy: name}
+	if tracked, ok := e.status[name]; ok {
+		st = *tracked
+		st.Recent = append([]ActionResult(nil), tracked.Recent...)
+	}
+	st.Wallet = e.manager.Wallet(name)
+	st.Halted = e.halted && !st.Disabled
+	return st
+}
+
+// update changes the named strategy's status with fn.
+func (e *Executor) update(name string, fn func(*Status)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st, ok := e.status[name]
+	if !ok {
+		st = &Status{Strategy: name}
+		e.status[name] = st
+	}
+	fn(st)
+}
+
+// recordAction adds the outcome of submitting a to its strategy's status.
+func (e *Executor) recordAction(ctx context.Context, a action.Action, err error) {
+	result := ActionResult{Time: time.Now(), Action: a.String(), Rationale: a.Rationale, Manual: isManual(ctx)}
+	if err != nil {
+		result.Err = err.Error()
+	}
+	e.update(a.Strategy, func(st *Status) {
+		st.Recent = append(st.Recent, result)
+		if len(st.Recent) > maxRecentActions {
+			st.Recent = st.Recent[len(st.Recent)-maxRecentActions:]
+		}
+	})
+}
+
+// Pause makes the named strategy skip its scheduled runs until Resume is
+// called. A run in progress is not interrupted.
+func (e *Executor) Pause(name string) error {
+	return e.control(name, "paused", func(st *Status) {
+		st.Paused = true
+	})
+}
+
+// Resume undoes Pause.
+func (e *Executor) Resume(name string) error {
+	return e.control(name, "resumed", func(st *Status) {
+		st.Paused = false
+	})
+}
+
+// Trigger runs the named strategy now, even if it is paused or its schedule
+// is complete. If it is running, it runs again once the current run
+// returns. ManualApprover vets the actions of the run. Strategies cannot be
+// triggered while halted.
+func (e *Executor) Trigger(name string) error {
+	if e.Halted() {
+		return fmt.Errorf("cannot trigger %s: %w", name, ErrHalted)
+	}
+	if err := e.control(name, "triggered", func(*Status) {}); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.triggered[name] = true
+	if _, ok := e.runners[name]; !ok && e.ctx != nil && e.ctx.Err() == nil {
+		e.startRunner(name)
+	}
+	e.wake(name)
+	return nil
+}
+
+// Disable removes the named strategy from the manager, so that it stops
+// once its current run, if any, returns. It stays disabled until it is
+// added back, e.g. by a config reload.
+func (e *Executor) Disable(name string) error {
+	if err := e.control(name, "disabled", func(st *Status) {
+		st.Disabled = true
+		st.NextRun = time.Time{}
+	}); err != nil {
+		return err
+	}
+	e.manager.Remove(name)
+	e.Reload(name)
+	return nil
+}
+
+// control applies fn to the status of the named strategy, if the manager
+// has it, and wakes its runner to pick up the change.
+func (e *Executor) control(name, verb string, fn func(*Status)) error {
+	if _, ok := e.manager.Get(name); !ok {
+		return fmt.Errorf("%w %q", ErrUnknownStrategy, name)
+	}
+	e.update(name, fn)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.wake(name)
+	log.Info().Str("strategy", name).Msg("Strategy " + verb)
+	return nil
+}
+
+// wake wakes the named strategy's runner, if any. e.mu must be held.
+func (e *Executor) wake(name string) {
+	if wake, ok := e.runners[name]; ok {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// takeTrigger reports whether the named strategy was triggered by hand
+// since it last ran, and clears the trigger.
+func (e *Executor) takeTrigger(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	triggered := e.triggered[name]
+	delete(e.triggered, name)
+	return triggered
+}
+
+type manualKey struct{}
+
+// withManual marks ctx as belonging to a run triggered by hand.
+func withManual(ctx context.Context) context.Context {
+	return context.WithValue(ctx, manualKey{}, true)
+}
+
+// isManual reports whether ctx belongs to a run triggered by hand.
+func isManual(ctx context.Context) bool {
+	manual, _ := ctx.Value(manualKey{}).(bool)
+	return manual
+}
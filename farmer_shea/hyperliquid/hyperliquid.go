@@ -2,43 +2,104 @@ package hyperliquid
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/dryrun"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/metrics"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/store"
 	"github.com/sonirico/go-hyperliquid"
 )
 
 // Client is a client for interacting with the Hyperliquid API.	ype Client struct {
 	client *hyperliquid.Client
+	apiURL string
 }
 
-// NewClient creates a new Hyperliquid client.
-func NewClient() (*Client, error) {
+// NewClient creates a new Hyperliquid client for the API at apiURL, e.g.
+// hyperliquid.MainnetAPIURL.
+func NewClient(apiURL string) (*Client, error) {
 	c, err := hyperliquid.New(nil)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{client: c}, nil
+	return &Client{client: c, apiURL: strings.TrimSuffix(apiURL, "/")}, nil
 }
 
-// DepositToVault deposits the given amount to the given vault.
-func (c *Client) DepositToVault(amount string, vaultAddress string) error {
-	_, err := c.client.Exchange.VaultDeposit(amount, vaultAddress)
-	return err
+// DepositToVault deposits usd, in raw 6-decimal USDC units, to the given vault.
+func (c *Client) DepositToVault(ctx context.Context, s *signer.Hyperliquid, usd *big.Int, vaultAddress string) error {
+	return c.vaultTransfer(ctx, s, usd, vaultAddress, true)
+}
+
+// WithdrawFromVault withdraws usd, in raw 6-decimal USDC units, from the given vault.
+func (c *Client) WithdrawFromVault(ctx context.Context, s *signer.Hyperliquid, usd *big.Int, vaultAddress string) error {
+	return c.vaultTransfer(ctx, s, usd, vaultAddress, false)
+}
+
+// vaultTransfer signs and sends a vault transfer on behalf of s.
+func (c *Client) vaultTransfer(ctx context.Context, s *signer.Hyperliquid, usd *big.Int, vaultAddress string, isDeposit bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !usd.IsInt64() {
+		return errkind.Wrapf(errkind.Permanent, "vault transfer amount %s is out of range", usd)
+	}
+
+	verb := "withdraw %s from vault"
+	if isDeposit {
+		verb = "deposit %s to vault"
+	}
+	if rec, ok := dryrun.FromContext(ctx); ok {
+		rec.Record(noopStep(fmt.Sprintf(verb, usd), vaultAddress, s.Address()))
+		return nil
+	}
+
+	// Vault transfers don't reference assets, so empty metadata avoids
+	// fetching it on every call.
+	exchange := hyperliquid.NewExchange(s.PrivateKey(), c.apiURL,
+		&hyperliquid.Meta{}, "", s.Address(), &hyperliquid.SpotMeta{})
+	resp, err := exchange.VaultUsdTransfer(vaultAddress, isDeposit, int(usd.Int64()))
+	if err != nil {
+		return errkind.Annotate(err)
+	}
+	if resp.Status != "ok" {
+		return fmt.Errorf("vault transfer rejected: %s", resp.Error)
+	}
+	// Accepted exchange actions are final, so the transfer is settled at
+	// once. The response does not report a fee.
+	action := fmt.Sprintf(verb, usd) + " " + vaultAddress
+	id := store.RecordTx(ctx, chain.Hyperliquid, resp.TxHash, action)
+	store.SettleTx(ctx, id, "", "", nil)
+	events.Publish(ctx, &events.TxSubmitted{Chain: chain.Hyperliquid, Hash: resp.TxHash, Action: action})
+	events.Publish(ctx, &events.TxConfirmed{Chain: chain.Hyperliquid, Hash: resp.TxHash})
+	return nil
+}
+
+// noopStep describes an exchange action skipped in a dry run. Hyperliquid has
+// no simulation endpoint, so the action is recorded without a result.
+func noopStep(action string, accounts ...string) dryrun.Step {
+	return dryrun.Step{Chain: "hyperliquid", Action: action, Accounts: accounts, Result: "not sent (no-op exchange)"}
 }
 
 // GetKlines fetches historical klines for a given symbol and interval.
-func (c *Client) GetKlines(symbol string, interval string, limit int) ([]hyperliquid.Kline, error) {
-	url := fmt.Sprintf("https://api.hyperliquid.xyz/info/klines?coin=%s&interval=%s&limit=%d", symbol, interval, limit)
-	resp, err := http.Get(url)
+func (c *Client) GetKlines(ctx context.Context, symbol string, interval string, limit int) ([]hyperliquid.Kline, error) {
+	url := fmt.Sprintf("%s/info/klines?coin=%s&interval=%s&limit=%d", c.apiURL, symbol, interval, limit)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
@@ -51,39 +112,173 @@ func (c *Client) GetKlines(symbol string, interval string, limit int) ([]hyperli
 	return klines, nil
 }
 
-// VaultDetails represents the details of a Hyperliquid vault.
+// VaultDetails are the details of a Hyperliquid vault used to rank it.
 type VaultDetails struct {
-	Asset  string  `json:"asset"`
-	Apy    float64 `json:"apy"`
-	Tvl    float64 `json:"tvl"`
+	Name string
+	// APR is the vault's annualized return over the last month, as a
+	// fraction.
+	APR float64
+	// TVL is the latest account value of the vault in USDC, or 0 if the
+	// response has none.
+	TVL float64
+}
+
+// vaultDetailsResponse is the part of a vaultDetails info response that is
+// read.
+type vaultDetailsResponse struct {
+	Name string   `json:"name"`
+	APR  *float64 `json:"apr"`
+	// Portfolio holds [period, history] pairs, e.g. ["day", {...}].
+	Portfolio [][2]json.RawMessage `json:"portfolio"`
 }
 
-// GetVaultDetails fetches the details for a given vault address.
-func (c *Client) GetVaultDetails(vaultAddress string) (*VaultDetails, error) {
-	url := "https://api.hyperliquid.xyz/info"
+// GetVaultDetails fetches the details for a given vault address. It fails
+// if the response reports no APR, e.g. because there is no such vault.
+func (c *Client) GetVaultDetails(ctx context.Context, vaultAddress string) (*VaultDetails, error) {
+	url := c.apiURL + "/info"
 	data := []byte(fmt.Sprintf(`{"type": "vaultDetails", "vaultAddress": "%s"}`, vaultAddress))
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	body, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
+	return parseVaultDetails(vaultAddress, body)
+}
+
+func parseVaultDetails(vaultAddress string, body []byte) (*VaultDetails, error) {
+	var resp *vaultDetailsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode details of vault %s: %w", vaultAddress, err)
+	}
+	if resp == nil || resp.APR == nil {
+		return nil, errkind.Wrapf(errkind.Permanent, "details of vault %s have no APR", vaultAddress)
+	}
+
+	details := &VaultDetails{Name: resp.Name, APR: *resp.APR}
+	for _, entry := range resp.Portfolio {
+		var period string
+		if err := json.Unmarshal(entry[0], &period); err != nil || period != "day" {
+			continue
+		}
+		var history struct {
+			// AccountValueHistory holds [time in ms, value] pairs, oldest
+			// first.
+			AccountValueHistory [][2]json.RawMessage `json:"accountValueHistory"`
+		}
+		if err := json.Unmarshal(entry[1], &history); err != nil {
+			return nil, fmt.Errorf("failed to decode portfolio of vault %s: %w", vaultAddress, err)
+		}
+		if n := len(history.AccountValueHistory); n > 0 {
+			var value string
+			if err := json.Unmarshal(history.AccountValueHistory[n-1][1], &value); err != nil {
+				return nil, fmt.Errorf("failed to decode account value of vault %s: %w", vaultAddress, err)
+			}
+			tvl, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("vault %s has malformed account value %q: %w", vaultAddress, value, err)
+			}
+			details.TVL = tvl
+		}
+	}
+	return details, nil
+}
+
+// SpotBalance is a token balance in a Hyperliquid spot account.
+type SpotBalance struct {
+	Coin  string  `json:"coin"`
+	Total float64 `json:"total,string"`
+}
+
+// GetSpotBalances returns the spot balances of user.
+func (c *Client) GetSpotBalances(ctx context.Context, user string) ([]SpotBalance, error) {
+	var state struct {
+		Balances []SpotBalance `json:"balances"`
+	}
+	if err := c.info(ctx, "spotClearinghouseState", user, &state); err != nil {
+		return nil, err
+	}
+	return state.Balances, nil
+}
+
+// GetPerpAccountValue returns the value in USDC of user's perps account,
+// including unrealized PnL.
+func (c *Client) GetPerpAccountValue(ctx context.Context, user string) (float64, error) {
+	var state struct {
+		MarginSummary struct {
+			AccountValue float64 `json:"accountValue,string"`
+		} `json:"marginSummary"`
+	}
+	if err := c.info(ctx, "clearinghouseState", user, &state); err != nil {
+		return 0, err
+	}
+	return state.MarginSummary.AccountValue, nil
+}
+
+// VaultEquity is the value in USDC of a user's deposit in a vault.
+type VaultEquity struct {
+	VaultAddress string  `json:"vaultAddress"`
+	Equity       float64 `json:"equity,string"`
+}
+
+// GetVaultEquities returns the vault deposits of user.
+func (c *Client) GetVaultEquities(ctx context.Context, user string) ([]VaultEquity, error) {
+	var equities []VaultEquity
+	if err := c.info(ctx, "userVaultEquities", user, &equities); err != nil {
+		return nil, err
+	}
+	return equities, nil
+}
+
+// info sends an info request of the given type about user and decodes the
+// response into out.
+func (c *Client) info(ctx context.Context, requestType, user string, out any) error {
+	data, err := json.Marshal(map[string]string{"type": requestType, "user": user})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+"/info", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode hyperliquid %s response: %w", requestType, err)
+	}
+	return nil
+}
+
+// httpClient sends the API requests made directly, recording them in the RPC
+// metrics.
+var httpClient = metrics.HTTPClient(chain.Hyperliquid)
+
+// doRequest sends req and returns the response body. Failed requests are
+// annotated with an errkind.Kind based on the transport error or HTTP status.
+func doRequest(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errkind.Annotate(err)
+	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, errkind.Wrap(errkind.Transient, err)
 	}
 
-	var vaultDetails VaultDetails
-	if err := json.Unmarshal(body, &vaultDetails); err != nil {
-		return nil, err
+	if resp.StatusCode >= 400 {
+		err := fmt.Errorf("hyperliquid %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(body))
+		return nil, errkind.FromHTTPStatus(resp.StatusCode, resp.Header.Get("Retry-After"), err)
 	}
 
-	return &vaultDetails, nil
+	return body, nil
 }
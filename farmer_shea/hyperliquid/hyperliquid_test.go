@@ -0,0 +1,36 @@
+package hyperliquid
+
+import (
+	"testing"
+
+	"github.com/sheawinkler/farmer-shea/errkind"
+)
+
+func TestParseVaultDetails(t *testing.T) {
+	// An abridged vaultDetails response.
+	body := `{
+		"name": "Hyperliquidity Provider (HLP)",
+		"vaultAddress": "0xdfc24b077bc1425ad1dea75bcb6f8158e10df303",
+		"apr": 0.0734,
+		"portfolio": [
+			["day", {"accountValueHistory": [[1718000000000, "389000000.5"], [1718003600000, "390123456.25"]], "pnlHistory": []}],
+			["week", {"accountValueHistory": [[1717400000000, "380000000.0"]], "pnlHistory": []}]
+		],
+		"followers": [],
+		"isClosed": false
+	}`
+	d, err := parseVaultDetails("0xdfc24b077bc1425ad1dea75bcb6f8158e10df303", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.APR != 0.0734 || d.TVL != 390123456.25 || d.Name != "Hyperliquidity Provider (HLP)" {
+		t.Errorf("parseVaultDetails = %+v, want the APR and latest daily account value", d)
+	}
+
+	for _, body := range []string{`null`, `{"name": "x", "portfolio": []}`} {
+		_, err := parseVaultDetails("0x0", []byte(body))
+		if errkind.Of(err) != errkind.Permanent {
+			t.Errorf("parseVaultDetails(%s) = %v, want a permanent error", body, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/config"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/wallet"
+)
+
+// walletPaths returns the keystore paths of each configured wallet by chain.
+// The default wallet's Solana keystore defaults to the wallet path.
+func walletPaths(cfg *config.Config) map[string]map[string]string {
+	paths := make(map[string]map[string]string, len(cfg.Wallets)+1)
+	for name, keys := range cfg.Wallets {
+		paths[name] = make(map[string]string, len(keys))
+		for id, path := range keys {
+			paths[name][strings.ToLower(id)] = path
+		}
+	}
+	if paths[wallet.Default] == nil {
+		paths[wallet.Default] = make(map[string]string)
+	}
+	if _, ok := paths[wallet.Default][string(chain.Solana)]; !ok && cfg.WalletPath != "" {
+		paths[wallet.Default][string(chain.Solana)] = cfg.WalletPath
+	}
+	return paths
+}
+
+// keyPath resolves a key reference of the form [WALLET/]CHAIN to its
+// configured keystore path.
+func keyPath(cfg *config.Config, ref string) (string, chain.ID, error) {
+	name, id := wallet.Default, ref
+	if i := strings.LastIndex(ref, "/"); i >= 0 {
+		name, id = ref[:i], ref[i+1:]
+	}
+	c, err := signer.ParseChain(id)
+	if err != nil {
+		return "", "", err
+	}
+	path := walletPaths(cfg)[name][string(c)]
+	if path == "" {
+		return "", "", fmt.Errorf("no keystore path configured for %s in wallet %s; add it under wallets", c, name)
+	}
+	return path, c, nil
+}
+
+// loadWallets unlocks the configured wallets. Missing keystores are an error
+// unless generate is set, in which case new keys are created for them.
+func loadWallets(cfg *config.Config, generate bool) (*wallet.Set, error) {
+	paths := walletPaths(cfg)
+
+	var missing []string
+	for name, keys := range paths {
+		for id, path := range keys {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				missing = append(missing, name+"/"+id)
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 && !generate {
+		return nil, fmt.Errorf("no keystore for %s; import keys with -import-key or pass -new-keys to generate them", strings.Join(missing, ", "))
+	}
+
+	passphrase, err := signer.ReadPassphrase(cfg.PassphraseFile, len(missing) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ref := range missing {
+		path, id, err := keyPath(cfg, ref)
+		if err != nil {
+			return nil, err
+		}
+		s, err := signer.Generate(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s key: %w", ref, err)
+		}
+		if err := signer.Save(s, path, passphrase); err != nil {
+			return nil, fmt.Errorf("failed to save %s key: %w", ref, err)
+		}
+		log.Info().Str("key", ref).Str("address", s.Address()).Str("path", path).Msg("Generated new key")
+	}
+
+	return wallet.Load(paths, passphrase)
+}
+
+// importKey imports the plaintext key named by spec, in the form
+// [WALLET/]CHAIN=FILE, into the configured keystore.
+func importKey(cfg *config.Config, spec string) error {
+	ref, file, ok := strings.Cut(spec, "=")
+	if !ok {
+		return fmt.Errorf("invalid -import-key %q: expected [WALLET/]CHAIN=FILE", spec)
+	}
+	path, id, err := keyPath(cfg, ref)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	s, err := signer.Import(id, data)
+	if err != nil {
+		return fmt.Errorf("failed to import %s key: %w", ref, err)
+	}
+
+	passphrase, err := signer.ReadPassphrase(cfg.PassphraseFile, true)
+	if err != nil {
+		return err
+	}
+
+	// Importing in place replaces the plaintext file with the keystore.
+	if path == file {
+		if err := os.Rename(file, file+".bak"); err != nil {
+			return err
+		}
+		log.Warn().Str("path", file+".bak").Msg("Moved plaintext key aside; delete it once the keystore is verified")
+	}
+	if err := signer.Save(s, path, passphrase); err != nil {
+		return err
+	}
+	log.Info().Str("key", ref).Str("address", s.Address()).Str("path", path).Msg("Imported key")
+	return nil
+}
+
+// exportKey prints the plaintext key named by ref, in the form
+// [WALLET/]CHAIN, in format.
+func exportKey(cfg *config.Config, ref, format string) error {
+	path, id, err := keyPath(cfg, ref)
+	if err != nil {
+		return err
+	}
+	passphrase, err := signer.ReadPassphrase(cfg.PassphraseFile, false)
+	if err != nil {
+		return err
+	}
+	s, err := signer.Load(id, path, passphrase)
+	if err != nil {
+		return err
+	}
+	out, err := signer.Export(s, format)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
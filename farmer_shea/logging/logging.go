@@ -1,13 +1,99 @@
 package logging
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
+// Until Setup is called, logs go to stderr in a human-readable format.
 func init() {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	zerolog.TimeFieldFormat = time.RFC3339
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 }
+
+// Options configure where logs are written.
+type Options struct {
+	// Level is the minimum level logged, e.g. "info".
+	Level string
+	// File, if set, receives every record as a JSON line. It is rotated
+	// once it reaches MaxSizeMB megabytes; MaxBackups rotated files are
+	// kept for up to MaxAgeDays days. Zero keeps them all.
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+}
+
+// Output is the destination of the global logger set up by Setup. Records
+// go to the file, if any, and to a console: stderr until Redirect replaces
+// it, e.g. with the UI's log pane.
+type Output struct {
+	file *lumberjack.Logger
+
+	mu      sync.Mutex
+	console io.Writer
+}
+
+// Setup points the global logger at a new Output.
+func Setup(opts Options) (*Output, error) {
+	level, err := zerolog.ParseLevel(opts.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+	}
+
+	o := &Output{console: stderr}
+	writers := []io.Writer{consoleWriter{o}}
+	if opts.File != "" {
+		o.file = &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAgeDays,
+		}
+		writers = append(writers, o.file)
+	}
+
+	log.Logger = zerolog.New(zerolog.MultiLevelWriter(writers...)).Level(level).With().Timestamp().Logger()
+	return o, nil
+}
+
+// stderr formats JSON records for a terminal.
+var stderr io.Writer = zerolog.ConsoleWriter{Out: os.Stderr}
+
+// Redirect sends console records, as JSON lines, to w instead of stderr. A
+// nil w restores stderr. w must not block, since it is written to by every
+// goroutine that logs.
+func (o *Output) Redirect(w io.Writer) {
+	if w == nil {
+		w = stderr
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.console = w
+}
+
+// Close closes the log file, if any.
+func (o *Output) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Close()
+}
+
+// consoleWriter writes to the current console of an Output.
+type consoleWriter struct {
+	o *Output
+}
+
+func (w consoleWriter) Write(p []byte) (int, error) {
+	w.o.mu.Lock()
+	defer w.o.mu.Unlock()
+	return w.o.console.Write(p)
+}
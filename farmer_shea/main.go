@@ -1,70 +1,157 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/allocator"
+	"github.com/sheawinkler/farmer-shea/api"
 	"github.com/sheawinkler/farmer-shea/base"
 	"github.com/sheawinkler/farmer-shea/config"
+	"github.com/sheawinkler/farmer-shea/events"
 	"github.com/sheawinkler/farmer-shea/executor"
 	"github.com/sheawinkler/farmer-shea/hyperliquid"
+	"github.com/sheawinkler/farmer-shea/logging"
+	"github.com/sheawinkler/farmer-shea/metrics"
 	"github.com/sheawinkler/farmer-shea/oracle"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/portfolio"
+	"github.com/sheawinkler/farmer-shea/risk"
+	"github.com/sheawinkler/farmer-shea/schedule"
+	"github.com/sheawinkler/farmer-shea/service"
 	"github.com/sheawinkler/farmer-shea/solana"
+	"github.com/sheawinkler/farmer-shea/store"
 	"github.com/sheawinkler/farmer-shea/strategy"
 	"github.com/sheawinkler/farmer-shea/sui"
 	"github.com/sheawinkler/farmer-shea/ui"
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/sheawinkler/farmer-shea/yield"
 )
 
-const walletPath = "farmer_shea_wallet.json"
-
 func main() {
-	appUI := ui.New()
+	configPath := flag.String("config", config.DefaultPath, "path to the config file")
+	profile := flag.String("profile", "", "network profile: mainnet, testnet, devnet or local (overrides the config)")
+	dryRun := flag.Bool("dry-run", false, "simulate transactions and log the plan instead of sending them")
+	newKeys := flag.Bool("new-keys", false, "generate keys for configured chains that have no keystore")
+	importSpec := flag.String("import-key", "", "import a plaintext key as [WALLET/]CHAIN=FILE into its keystore and exit")
+	exportChain := flag.String("export-key", "", "print the plaintext key for [WALLET/]CHAIN and exit")
+	exportFormat := flag.String("export-format", "", "format for -export-key: id.json, base58 or hex")
+	headless := flag.Bool("headless", false, "run without the terminal UI, e.g. as a service; use the API to control it")
+	flag.Parse()
 
-	go func() {
-		log.Info().Msg("Starting Farmer Shea Bot...")
+	// Load and validate config, including every strategy's params
+	configOpts := config.Options{
+		Path:    *configPath,
+		Profile: *profile,
+		ValidateStrategy: func(sc config.StrategyConfig) error {
+			return strategy.Validate(sc.Type, sc.Params)
+		},
+	}
+	cfg, err := config.Load(configOpts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load config")
+	}
+	logOutput, err := logging.Setup(logging.Options{
+		Level:      cfg.Log.Level,
+		File:       cfg.Log.File,
+		MaxSizeMB:  cfg.Log.MaxSizeMB,
+		MaxBackups: cfg.Log.MaxBackups,
+		MaxAgeDays: cfg.Log.MaxAgeDays,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up logging")
+	}
+	defer logOutput.Close()
+	log.Info().Str("config", *configPath).Str("profile", cfg.Profile).Msg("Loaded config")
 
-		// Load config
-		cfg, err := config.Load()
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to load config")
+	switch {
+	case *importSpec != "":
+		if err := importKey(cfg, *importSpec); err != nil {
+			log.Fatal().Err(err).Msg("Failed to import key")
 		}
+		return
+	case *exportChain != "":
+		if err := exportKey(cfg, *exportChain, *exportFormat); err != nil {
+			log.Fatal().Err(err).Msg("Failed to export key")
+		}
+		return
+	}
 
-		// Dummy data for portfolio and PnL
-		portfolio := map[string]float64{
-			"SOL": 10.5,
-			"ETH": 2.3,
-			"BTC": 0.5,
+	// Keys are unlocked before the UI starts so that the passphrase prompt
+	// can use the terminal.
+	wallets, err := loadWallets(cfg, *newKeys)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load wallets")
+	}
+	for _, name := range wallets.Names() {
+		w, _ := wallets.Get(name)
+		for _, id := range w.Keys.Chains() {
+			s, _ := w.Keys.Signer(id)
+			log.Info().Str("wallet", name).Str("chain", string(id)).Str("address", s.Address()).Msg("Loaded signer")
 		}
-		pnl := map[string]float64{
-			"SOL": 123.45,
-			"ETH": 67.89,
-			"BTC": -12.34,
+	}
+
+	var apiToken string
+	if cfg.API.Listen != "" {
+		if apiToken, err = api.ReadToken(cfg.API.TokenFile); err != nil {
+			log.Fatal().Err(err).Msg("Failed to read API token")
 		}
+	}
 
-		appUI.UpdatePortfolio(portfolio)
-		appUI.UpdatePnL(pnl)
+	state, err := store.Open(cfg.StatePath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open state store")
+	}
+	defer state.Close()
 
-		// Load or create a wallet
-		w, err := wallet.Load(cfg.WalletPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				log.Info().Msg("No wallet found, creating a new one...")
-				w, err = wallet.NewWallet()
-				if err != nil {
-					log.Fatal().Err(err).Msg("Failed to create a new wallet")
-				}
-				if err := w.Save(cfg.WalletPath); err != nil {
-					log.Fatal().Err(err).Msg("Failed to save the new wallet")
-				}
-				log.Info().Msg("New wallet created and saved.")
-			} else {
-				log.Fatal().Err(err).Msg("Failed to load wallet")
-			}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var appUI *ui.UI
+	if !*headless {
+		appUI = ui.New()
+		// Logs go to the log pane while the UI runs, and back to stderr
+		// after.
+		logOutput.Redirect(appUI.LogWriter())
+
+		// Stop the UI when a signal arrives so that main can shut down
+		// cleanly.
+		go func() {
+			<-ctx.Done()
+			appUI.Stop()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		log.Info().Msg("Starting Farmer Shea Bot...")
+
+		// Log strategy and transaction events. Prices are shown in the
+		// portfolio view instead.
+		bus := events.NewBus()
+		logTopics := []events.Topic{
+			events.TopicStrategyStarted, events.TopicStrategyFinished, events.TopicStrategyFailed,
+			events.TopicTxSubmitted, events.TopicTxConfirmed, events.TopicSignalGenerated,
+			events.TopicPositionChanged, events.TopicRiskBreached, events.TopicStopLoss,
+			events.TopicKillSwitch, events.TopicRebalance,
 		}
-		log.Info().Str("publicKey", w.PublicKey.String()).Msg("Loaded wallet")
+		bus.Subscribe(events.DefaultBuffer, func(e events.Event) {
+			ev := log.Info()
+			switch e.(type) {
+			case *events.StrategyFailed, *events.RiskBreached, *events.StopLoss, *events.KillSwitch:
+				ev = log.Warn()
+			}
+			ev.Str("strategy", events.StrategyOf(e)).Str("topic", string(e.Topic())).Msg(e.String())
+		}, logTopics...)
 
 		// Initialize Solana client
 		solanaClient, err := solana.NewClient(cfg.SolanaRPC)
@@ -73,7 +160,7 @@ func main() {
 		}
 
 		// Initialize Hyperliquid client
-		hyperliquidClient, err := hyperliquid.NewClient()
+		hyperliquidClient, err := hyperliquid.NewClient(cfg.HyperliquidAPI)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Failed to create Hyperliquid client")
 		}
@@ -85,7 +172,7 @@ func main() {
 		}
 
 		// Initialize Sui client
-		suiClient, err := sui.NewClient("https://fullnode.mainnet.sui.io:443")
+		suiClient, err := sui.NewClient(cfg.SuiRPC)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Failed to create Sui client")
 		}
@@ -96,25 +183,193 @@ func main() {
 			log.Fatal().Err(err).Msg("Failed to create oracle")
 		}
 
-		// Initialize Strategy Manager
-		strategyManager := strategy.NewManager()
+		// Track wallet balances on every chain
+		baseTokens := make([]common.Address, len(cfg.Portfolio.BaseTokens))
+		for i, token := range cfg.Portfolio.BaseTokens {
+			baseTokens[i] = common.HexToAddress(token)
+		}
+		tracker := portfolio.NewTracker(wallets, oracle,
+			portfolio.NewSolana(solanaClient),
+			portfolio.NewBase(baseClient, baseTokens...),
+			portfolio.NewHyperliquid(hyperliquidClient),
+			portfolio.NewSui(suiClient),
+		)
+		tracker.Interval = cfg.Portfolio.Interval
+		tracker.Events = bus
+		tracker.OnSnapshot(func(s portfolio.Snapshot) {
+			if err := state.AddBalances(s.Balances()); err != nil {
+				log.Error().Err(err).Msg("Failed to record balance snapshot")
+			}
+			metrics.SetBalances(s.Balances())
+		})
+		go tracker.Run(ctx)
 
-		// Add Strategies
-		strategyManager.Add(strategy.NewSimpleVaultDepositStrategy(hyperliquidClient, cfg.Hyperliquid.Amount, cfg.Hyperliquid.StopLoss))
-		strategyManager.Add(strategy.NewUniswapV3LPStrategy(baseClient, hyperliquidClient, cfg.Base.TokenA, cfg.Base.TokenB, cfg.Base.AmountA, cfg.Base.AmountB, cfg.Base.Fee))
-		strategyManager.Add(strategy.NewMarinadeStakingStrategy(solanaClient, 1000000000)) // 1 SOL
-		strategyManager.Add(strategy.NewSolend(solanaClient, oracle, 1000000000))
-		strategyManager.Add(strategy.NewSuiPlaceholderStrategy(suiClient))
-		strategyManager.Add(strategy.NewMACrossoverStrategy(hyperliquidClient, cfg.MACrossover.Symbol, cfg.MACrossover.ShortPeriod, cfg.MACrossover.LongPeriod))
+		// Build the configured strategies
+		deps := strategy.Deps{
+			Solana:      solanaClient,
+			Base:        baseClient,
+			Hyperliquid: hyperliquidClient,
+			Sui:         suiClient,
+			Oracle:      oracle,
+			Marinade:    &yield.Marinade{APIURL: cfg.Yield.MarinadeAPI},
+			Costs:       yieldCosts(cfg.Yield),
+		}
+		strategyManager, schedules, err := buildStrategies(cfg, deps)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to build strategies")
+		}
 
 		// Initialize and run the executor
-		exe := executor.New(strategyManager.Strategies, *w, w.PrivateKey)
-		exe.Run()
+		exe := executor.New(strategyManager, wallets)
+		exe.Schedules = schedules
+		// Dry runs keep their run history in memory so that they do not
+		// suppress the next live run of a strategy.
+		if cfg.ScheduleStatePath != "" && !*dryRun {
+			exe.LastRuns, err = schedule.NewFileStore(cfg.ScheduleStatePath)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load schedule state")
+			}
+		}
+		exe.DryRun = *dryRun
+		exe.Store = state
+		exe.Events = bus
+		exe.Prices = tracker
+
+		// Every action passes the risk limits before it is submitted, and
+		// each balance snapshot is checked against the daily loss limit.
+		riskManager := risk.NewManager(riskLimits(cfg.Risk), state, tracker)
+		riskManager.Events = bus
+		exe.Checkers = riskManager.Checkers()
+		tracker.OnSnapshot(riskManager.Observe)
+		if appUI != nil {
+			// Runs triggered by hand are confirmed in the UI before any
+			// live transaction is sent.
+			exe.ManualApprover = appUI
+		}
+		if *dryRun {
+			log.Warn().Msg("Dry-run mode: transactions will be simulated, not sent")
+		}
+		exe.Start(ctx)
+
+		// The UI and the API show and control the bot through the service
+		// layer. The config has been validated, so the PnL method is known.
+		method, _ := pnl.ParseMethod(cfg.PnL.Method)
+		svc := service.New(state, exe, tracker, riskManager, method)
+		svc.SetYields(buildYields(cfg.Yield, deps))
+		if cfg.Allocator.CapitalUSD > 0 {
+			alloc := allocator.New(allocator.Config{
+				Capital:   cfg.Allocator.CapitalUSD,
+				Policy:    allocator.Policy(cfg.Allocator.Policy),
+				Weights:   cfg.Allocator.Weights,
+				MaxWeight: cfg.Allocator.MaxWeight,
+				Threshold: cfg.Allocator.DriftThreshold,
+			}, strategyManager, state, tracker)
+			alloc.Interval = cfg.Allocator.Interval
+			alloc.Events = bus
+			svc.SetAllocator(alloc)
+			go alloc.Run(ctx)
+		}
+		if appUI != nil {
+			appUI.SetStrategyControls(svc)
+			go refreshUI(ctx, appUI, svc)
+		}
+		if len(cfg.Alerts.Channels) > 0 {
+			notifier, err := buildNotifier(cfg.Alerts)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to set up alerts")
+			}
+			notifier.Summary = func() (string, error) {
+				r, err := svc.PnL()
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Net PnL: %.2f USD (realized %.2f, unrealized %.2f, yield %.2f, fees %.2f)",
+					r.Total.Net(), r.Total.Realized, r.Total.Unrealized, r.Total.Yield, r.Total.Fees), nil
+			}
+			notifier.Subscribe(bus)
+			go notifier.Run(ctx)
+		}
+		if cfg.Metrics.Listen != "" {
+			metrics.Subscribe(bus)
+			tracker.OnSnapshot(func(portfolio.Snapshot) {
+				if r, err := svc.PnL(); err == nil {
+					metrics.SetPnL(r)
+				}
+			})
+			go func() {
+				if err := metrics.ListenAndServe(ctx, cfg.Metrics.Listen, cfg.Metrics.Path); err != nil {
+					log.Error().Err(err).Msg("Metrics server failed")
+				}
+			}()
+		}
+		if cfg.API.Listen != "" {
+			go func() {
+				if err := api.NewServer(svc, apiToken).ListenAndServe(ctx, cfg.API.Listen); err != nil {
+					log.Error().Err(err).Msg("API server failed")
+				}
+			}()
+		}
+
+		// Reload strategies when the config file changes
+		watcher := config.Watch(cfg, configOpts)
+		watcher.OnChange(func(c config.Change) {
+			log.Info().Str("diff", c.Diff.String()).Msg("Config reloaded")
+			for _, detail := range c.Diff.Details {
+				log.Info().Msg(detail)
+			}
+			if len(c.Diff.Settings) > 0 {
+				log.Warn().Strs("settings", c.Diff.Settings).Msg("Restart to apply changed settings")
+			}
+			applyConfigChange(c, strategyManager, exe, deps, wallets)
+		})
+		watcher.OnError(func(err error) {
+			log.Error().Err(err).Msg("Config reload rejected; keeping the current config")
+		})
 
-		log.Info().Msg("Farmer Shea Bot cycle complete.")
+		<-ctx.Done()
+		log.Info().Msg("Shutting down Farmer Shea Bot...")
+		if interrupted := exe.Stop(); len(interrupted) > 0 {
+			log.Warn().Int("count", len(interrupted)).Msg("Some strategies were interrupted; check their last stage before restarting")
+		}
+		log.Info().Msg("Farmer Shea Bot stopped.")
 	}()
 
-	if err := appUI.Run(); err != nil {
-		log.Fatal().Err(err).Msg("UI error")
+	if appUI != nil {
+		if err := appUI.Run(); err != nil {
+			log.Error().Err(err).Msg("UI error")
+		}
+		logOutput.Redirect(nil)
+
+		// The UI has exited, either because the user quit or because a
+		// signal arrived. Either way, stop the strategies before exiting.
+		cancel()
+	}
+	<-done
+}
+
+// refreshUI shows the service's state in the UI until ctx is cancelled:
+// strategies every second, and the portfolio and PnL whenever a new
+// portfolio snapshot is collected.
+func refreshUI(ctx context.Context, appUI *ui.UI, svc *service.Service) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var shown time.Time
+	for {
+		appUI.UpdateStrategies(svc.Strategies())
+		if s := svc.Portfolio(); !s.Time.Equal(shown) {
+			shown = s.Time
+			appUI.UpdatePortfolio(s)
+			if r, err := svc.PnL(); err != nil {
+				log.Error().Err(err).Msg("Failed to compute PnL")
+			} else {
+				appUI.UpdatePnL(r)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/store"
+)
+
+const namespace = "farmer_shea"
+
+// Registry holds every metric the bot exports.
+var Registry = prometheus.NewRegistry()
+
+var (
+	strategyRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "strategy_runs_total",
+		Help:      "Strategy runs by outcome: succeeded, failed or cancelled.",
+	}, []string{"strategy", "outcome"})
+	strategyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "strategy_run_duration_seconds",
+		Help:      "Duration of strategy runs.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"strategy"})
+	strategyFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "strategy_consecutive_failures",
+		Help:      "Strategy runs that have failed in a row.",
+	}, []string{"strategy"})
+	riskBreaches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "risk_breaches_total",
+		Help:      "Actions rejected by a risk check.",
+	}, []string{"strategy", "rule"})
+	transactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_total",
+		Help:      "Transactions by status: submitted, confirmed or failed.",
+	}, []string{"chain", "status"})
+
+	rpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_requests_total",
+		Help:      "Requests to chain RPC endpoints by outcome: ok or error.",
+	}, []string{"chain", "method", "outcome"})
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_request_duration_seconds",
+		Help:      "Latency of requests to chain RPC endpoints.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"chain", "method"})
+
+	balance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "wallet_balance",
+		Help:      "Wallet balances in whole units of the asset, as of the latest portfolio snapshot.",
+	}, []string{"wallet", "chain", "account", "asset"})
+	balanceValue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "wallet_balance_usd",
+		Help:      "Value of wallet balances in USD, as of the latest portfolio snapshot.",
+	}, []string{"wallet", "chain", "account", "asset"})
+	portfolioValue = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "portfolio_value_usd",
+		Help:      "Total value of every wallet in USD, as of the latest portfolio snapshot.",
+	})
+	pnlUSD = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pnl_usd",
+		Help:      "PnL of each strategy in USD by component: realized, unrealized, yield, fees, impermanent_loss or net.",
+	}, []string{"strategy", "component"})
+
+	prices = &priceCollector{
+		price: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "price_usd"),
+			"Latest price of each asset in USD.", []string{"chain", "asset"}, nil),
+		age: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "price_age_seconds"),
+			"Time since the price of each asset was last updated.", []string{"chain", "asset"}, nil),
+		updates: make(map[priceKey]*events.PriceUpdated),
+	}
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		strategyRuns, strategyDuration, strategyFailures, riskBreaches, transactions,
+		rpcRequests, rpcDuration,
+		balance, balanceValue, portfolioValue, pnlUSD,
+		prices,
+	)
+}
+
+// Subscribe records strategy, transaction, risk and price events from bus.
+func Subscribe(bus *events.Bus) *events.Subscription {
+	return bus.Subscribe(events.DefaultBuffer, observe,
+		events.TopicStrategyFinished, events.TopicStrategyFailed, events.TopicRiskBreached,
+		events.TopicTxSubmitted, events.TopicTxConfirmed, events.TopicPriceUpdated)
+}
+
+func observe(e events.Event) {
+	strategy := events.StrategyOf(e)
+	switch e := e.(type) {
+	case *events.StrategyFinished:
+		outcome := "succeeded"
+		if e.Cancelled {
+			outcome = "cancelled"
+		} else {
+			strategyFailures.WithLabelValues(strategy).Set(0)
+		}
+		strategyRuns.WithLabelValues(strategy, outcome).Inc()
+		strategyDuration.WithLabelValues(strategy).Observe(e.Duration.Seconds())
+	case *events.StrategyFailed:
+		strategyRuns.WithLabelValues(strategy, "failed").Inc()
+		strategyDuration.WithLabelValues(strategy).Observe(e.Duration.Seconds())
+		strategyFailures.WithLabelValues(strategy).Inc()
+	case *events.RiskBreached:
+		riskBreaches.WithLabelValues(strategy, e.Rule).Inc()
+	case *events.TxSubmitted:
+		transactions.WithLabelValues(string(e.Chain), "submitted").Inc()
+	case *events.TxConfirmed:
+		status := "confirmed"
+		if e.Err != nil {
+			status = "failed"
+		}
+		transactions.WithLabelValues(string(e.Chain), status).Inc()
+	case *events.PriceUpdated:
+		prices.update(e)
+	}
+}
+
+// SetBalances replaces the exported wallet balances and portfolio value
+// with those of a portfolio snapshot.
+func SetBalances(balances []store.Balance) {
+	balance.Reset()
+	balanceValue.Reset()
+	var total float64
+	for _, b := range balances {
+		labels := []string{b.Wallet, string(b.Chain), b.Account, b.Asset}
+		balance.WithLabelValues(labels...).Set(b.Amount)
+		balanceValue.WithLabelValues(labels...).Set(b.Value)
+		total += b.Value
+	}
+	portfolioValue.Set(total)
+}
+
+// SetPnL replaces the exported PnL with that of r.
+func SetPnL(r pnl.Report) {
+	pnlUSD.Reset()
+	for strategy, s := range r.ByStrategy {
+		for component, v := range map[string]float64{
+			"realized":         s.Realized,
+			"unrealized":       s.Unrealized,
+			"yield":            s.Yield,
+			"fees":             s.Fees,
+			"impermanent_loss": s.ImpermanentLoss,
+			"net":              s.Net(),
+		} {
+			pnlUSD.WithLabelValues(strategy, component).Set(v)
+		}
+	}
+}
+
+type priceKey struct {
+	chain chain.ID
+	asset string
+}
+
+// priceCollector exports the latest price of each asset and its age, which
+// grows until the next update.
+type priceCollector struct {
+	price, age *prometheus.Desc
+
+	mu      sync.Mutex
+	updates map[priceKey]*events.PriceUpdated
+}
+
+func (c *priceCollector) update(e *events.PriceUpdated) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updates[priceKey{e.Chain, e.Asset}] = e
+}
+
+// Describe implements prometheus.Collector.
+func (c *priceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.price
+	ch <- c.age
+}
+
+// Collect implements prometheus.Collector.
+func (c *priceCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.updates {
+		ch <- prometheus.MustNewConstMetric(c.price, prometheus.GaugeValue, e.Price, string(k.chain), k.asset)
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, time.Since(e.Time).Seconds(), string(k.chain), k.asset)
+	}
+}
+
+// ListenAndServe serves the metrics on addr at path until ctx is cancelled.
+func ListenAndServe(ctx context.Context, addr, path string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("addr", addr).Str("path", path).Msg("Serving metrics")
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+// maxMethodBody bounds how much of a request body is read to find its
+// method.
+const maxMethodBody = 64 << 10
+
+// HTTPClient returns an HTTP client whose requests are recorded as RPC
+// requests to chain c.
+func HTTPClient(c chain.ID) *http.Client {
+	return &http.Client{Transport: Transport(c, nil)}
+}
+
+// Transport wraps base, or http.DefaultTransport if nil, to record the
+// latency and outcome of every request as an RPC request to chain c.
+// Requests fail on transport errors and HTTP error statuses; errors a
+// JSON-RPC server reports with a 200 status count as successes.
+func Transport(c chain.ID, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{chain: string(c), base: base}
+}
+
+type transport struct {
+	chain string
+	base  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := requestMethod(req)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	rpcDuration.WithLabelValues(t.chain, method).Observe(time.Since(start).Seconds())
+
+	outcome := "ok"
+	if err != nil || resp.StatusCode >= 400 {
+		outcome = "error"
+	}
+	rpcRequests.WithLabelValues(t.chain, method, outcome).Inc()
+	return resp, err
+}
+
+// requestMethod names the RPC a request makes: its JSON-RPC method,
+// "batch" for a JSON-RPC batch, the type of a Hyperliquid API request or
+// else the URL path.
+func requestMethod(req *http.Request) string {
+	if req.GetBody == nil {
+		return req.URL.Path
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return req.URL.Path
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxMethodBody))
+	if err != nil {
+		return req.URL.Path
+	}
+
+	var call struct {
+		Method string `json:"method"`
+		Type   string `json:"type"`
+	}
+	switch {
+	case len(data) > 0 && data[0] == '[':
+		return "batch"
+	case json.Unmarshal(data, &call) != nil:
+		return req.URL.Path
+	case call.Method != "":
+		return call.Method
+	case call.Type != "":
+		return call.Type
+	default:
+		return req.URL.Path
+	}
+}
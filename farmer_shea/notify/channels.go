@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTelegramAPI is the Telegram Bot API endpoint.
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// maxErrorBody bounds how much of an error response is reported.
+const maxErrorBody = 512
+
+// Webhook posts each alert as a JSON object to a URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// webhookPayload is the JSON body a Webhook posts.
+type webhookPayload struct {
+	Time     time.Time `json:"time"`
+	Severity string    `json:"severity"`
+	Title    string    `json:"title"`
+	Body     string    `json:"body,omitempty"`
+	Strategy string    `json:"strategy,omitempty"`
+}
+
+// Send implements Channel.
+func (w *Webhook) Send(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.Client, w.URL, webhookPayload{
+		Time:     a.Time,
+		Severity: a.Severity.String(),
+		Title:    a.Title,
+		Body:     a.Body,
+		Strategy: a.Strategy,
+	}, nil)
+}
+
+// Slack posts each alert as a message to a Slack incoming webhook, or to
+// any chat service that accepts Slack's format, e.g. Mattermost or
+// Discord's /slack endpoint.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send implements Channel.
+func (s *Slack) Send(ctx context.Context, a Alert) error {
+	text := "*" + a.Subject() + "*"
+	if a.Body != "" {
+		text += "\n" + a.Body
+	}
+	return postJSON(ctx, s.Client, s.URL, map[string]string{"text": text}, nil)
+}
+
+// Telegram sends each alert as a message from a Telegram bot to a chat.
+type Telegram struct {
+	// APIURL is the Bot API endpoint. It defaults to DefaultTelegramAPI.
+	APIURL string
+	Token  string
+	ChatID string
+	Client *http.Client
+}
+
+// Send implements Channel.
+func (t *Telegram) Send(ctx context.Context, a Alert) error {
+	api := t.APIURL
+	if api == "" {
+		api = DefaultTelegramAPI
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(api, "/"), t.Token)
+
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err := postJSON(ctx, t.Client, url, map[string]string{"chat_id": t.ChatID, "text": a.Text()}, &resp)
+	if err != nil {
+		// The URL holds the bot token, so it is kept out of errors.
+		var ue *neturl.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return fmt.Errorf("telegram sendMessage failed: %w", err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("telegram sendMessage failed: %s", resp.Description)
+	}
+	return nil
+}
+
+// Email sends each alert as a plain-text email through an SMTP server.
+// The connection is upgraded with STARTTLS when the server offers it.
+type Email struct {
+	// Addr is the server's host:port.
+	Addr string
+	From string
+	To   []string
+	// Username and Password, if set, authenticate with PLAIN auth, which
+	// requires TLS unless the server is on localhost.
+	Username string
+	Password string
+}
+
+// Send implements Channel. net/smtp takes no context, so a send that
+// outlives ctx is abandoned rather than cancelled.
+func (e *Email) Send(ctx context.Context, a Alert) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address %q: %w", e.Addr, err)
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.Addr, auth, e.From, e.To, e.message(a))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send email: %w", ctx.Err())
+	}
+}
+
+// message formats a as an RFC 5322 message.
+func (e *Email) message(a Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: farmer_shea %s\r\n", a.Subject())
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body := a.Body
+	if body == "" {
+		body = a.Title
+	}
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// postJSON posts v as JSON to url and, if out is not nil, decodes the
+// response into it. Responses with an error status fail.
+func postJSON(ctx context.Context, client *http.Client, url string, v, out any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		if len(data) > maxErrorBody {
+			data = data[:maxErrorBody]
+		}
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	Time:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	Severity: Critical,
+	Title:    "Stop-loss triggered for solend",
+	Body:     "Reason: price fell",
+	Strategy: "solend",
+}
+
+// request is what a test server received.
+type request struct {
+	method, path, contentType string
+	body                      map[string]any
+}
+
+// newServer starts a server that records each request and replies with
+// status and body.
+func newServer(t *testing.T, status int, body string) (*httptest.Server, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := request{method: r.Method, path: r.URL.Path, contentType: r.Header.Get("Content-Type")}
+		if err := json.Unmarshal(data, &req.body); err != nil {
+			t.Errorf("request body %q is not a JSON object: %v", data, err)
+		}
+		requests <- req
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestWebhookSend(t *testing.T) {
+	srv, requests := newServer(t, http.StatusNoContent, "")
+	w := &Webhook{URL: srv.URL + "/hook"}
+	if err := w.Send(context.Background(), testAlert); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/hook" || req.contentType != "application/json" {
+		t.Errorf("request = %s %s (%s), want a JSON POST to /hook", req.method, req.path, req.contentType)
+	}
+	want := map[string]any{
+		"time":     "2024-01-01T12:00:00Z",
+		"severity": "critical",
+		"title":    testAlert.Title,
+		"body":     testAlert.Body,
+		"strategy": "solend",
+	}
+	if len(req.body) != len(want) {
+		t.Errorf("payload = %v, want %v", req.body, want)
+	}
+	for k, v := range want {
+		if req.body[k] != v {
+			t.Errorf("payload[%q] = %v, want %v", k, req.body[k], v)
+		}
+	}
+}
+
+func TestSlackSend(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK, "ok")
+	s := &Slack{URL: srv.URL}
+	if err := s.Send(context.Background(), testAlert); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	want := "*[CRITICAL] Stop-loss triggered for solend*\nReason: price fell"
+	if len(req.body) != 1 || req.body["text"] != want {
+		t.Errorf("payload = %v, want text %q", req.body, want)
+	}
+}
+
+func TestTelegramSend(t *testing.T) {
+	srv, requests := newServer(t, http.StatusOK, `{"ok": true}`)
+	tg := &Telegram{APIURL: srv.URL + "/", Token: "123:secret", ChatID: "-42"}
+	if err := tg.Send(context.Background(), testAlert); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.path != "/bot123:secret/sendMessage" {
+		t.Errorf("path = %q, want /bot123:secret/sendMessage", req.path)
+	}
+	if req.body["chat_id"] != "-42" || req.body["text"] != testAlert.Text() {
+		t.Errorf("payload = %v, want chat_id -42 and the alert text", req.body)
+	}
+}
+
+func TestTelegramNotOK(t *testing.T) {
+	srv, _ := newServer(t, http.StatusOK, `{"ok": false, "description": "chat not found"}`)
+	tg := &Telegram{APIURL: srv.URL, Token: "123:secret", ChatID: "-42"}
+	err := tg.Send(context.Background(), testAlert)
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("Send = %v, want the API's description", err)
+	}
+}
+
+func TestSendErrorStatus(t *testing.T) {
+	for _, status := range []int{http.StatusMovedPermanently, http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError} {
+		srv, _ := newServer(t, status, `{"ok": false, "description": "nope"}`)
+		channels := map[string]Channel{
+			"webhook":  &Webhook{URL: srv.URL},
+			"slack":    &Slack{URL: srv.URL},
+			"telegram": &Telegram{APIURL: srv.URL, Token: "123:secret", ChatID: "-42"},
+		}
+		for name, c := range channels {
+			err := c.Send(context.Background(), testAlert)
+			if err == nil {
+				t.Errorf("%s: Send with status %d succeeded, want an error", name, status)
+				continue
+			}
+			if name == "telegram" && strings.Contains(err.Error(), "secret") {
+				t.Errorf("%s: error %q leaks the bot token", name, err)
+			}
+		}
+	}
+}
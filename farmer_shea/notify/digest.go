@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/events"
+)
+
+// runStats counts the outcomes of a strategy's runs.
+type runStats struct {
+	succeeded, failed int
+}
+
+// stats counts what happened since the last summary.
+type stats struct {
+	since       time.Time
+	runs        map[string]*runStats
+	breaches    int
+	stopLosses  int
+	txConfirmed int
+	txFailed    int
+	alerts      map[Severity]int
+}
+
+func newStats(since time.Time) *stats {
+	return &stats{since: since, runs: make(map[string]*runStats), alerts: make(map[Severity]int)}
+}
+
+func (s *stats) observe(e events.Event) {
+	strategy := events.StrategyOf(e)
+	run := func() *runStats {
+		r, ok := s.runs[strategy]
+		if !ok {
+			r = &runStats{}
+			s.runs[strategy] = r
+		}
+		return r
+	}
+	switch e := e.(type) {
+	case *events.StrategyFinished:
+		if !e.Cancelled {
+			run().succeeded++
+		}
+	case *events.StrategyFailed:
+		run().failed++
+	case *events.RiskBreached:
+		s.breaches++
+	case *events.StopLoss:
+		s.stopLosses++
+	case *events.TxConfirmed:
+		if e.Err != nil {
+			s.txFailed++
+		} else {
+			s.txConfirmed++
+		}
+	}
+}
+
+// body formats the counts as the text of a summary.
+func (s *stats) body() string {
+	var b strings.Builder
+	names := make([]string, 0, len(s.runs))
+	for name := range s.runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		b.WriteString("No strategy runs.\n")
+	}
+	for _, name := range names {
+		r := s.runs[name]
+		fmt.Fprintf(&b, "%s: %d runs succeeded, %d failed\n", name, r.succeeded, r.failed)
+	}
+	fmt.Fprintf(&b, "Transactions: %d confirmed, %d failed\n", s.txConfirmed, s.txFailed)
+	fmt.Fprintf(&b, "Risk breaches: %d\nStop-losses: %d\n", s.breaches, s.stopLosses)
+	fmt.Fprintf(&b, "Alerts: %d critical, %d warning, %d info", s.alerts[Critical], s.alerts[Warning], s.alerts[Info])
+	return b.String()
+}
+
+// runDigest sends the summary on the Digest schedule until ctx is
+// cancelled.
+func (n *Notifier) runDigest(ctx context.Context) {
+	// Start from now, so that an interval schedule does not send a summary
+	// of nothing at startup.
+	last := time.Now()
+	for {
+		next, ok := n.Digest.Next(last, time.Now())
+		if !ok {
+			return
+		}
+		if sleep(ctx, time.Until(next)) != nil {
+			return
+		}
+		last = next
+		n.sendDigest(next)
+	}
+}
+
+// sendDigest sends the summary of what happened since the previous one
+// through the routes that take digests.
+func (n *Notifier) sendDigest(now time.Time) {
+	n.mu.Lock()
+	s := n.stats
+	n.stats = newStats(now)
+	n.mu.Unlock()
+
+	body := s.body()
+	if n.Summary != nil {
+		summary, err := n.Summary()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to summarize for the digest")
+		} else if summary != "" {
+			body += "\n\n" + summary
+		}
+	}
+
+	a := Alert{
+		Time:     now,
+		Severity: Info,
+		Title:    fmt.Sprintf("Summary since %s", s.since.Format(time.RFC3339)),
+		Body:     body,
+	}
+	for _, r := range n.routes {
+		if r.Digest {
+			r.enqueue(a)
+		}
+	}
+}
+
+// sleep waits for d or until ctx is cancelled, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
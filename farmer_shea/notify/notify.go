@@ -0,0 +1,367 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/schedule"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failed runs of a
+	// strategy that raises an alert.
+	DefaultFailureThreshold = 3
+	// DefaultSendTimeout bounds how long a channel may take to send an
+	// alert.
+	DefaultSendTimeout = 30 * time.Second
+	// queueSize is the number of alerts a channel queues before it starts
+	// dropping them.
+	queueSize = 64
+)
+
+// Severity ranks alerts.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Critical:
+		return "critical"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// ParseSeverity parses "info", "warning" or "critical".
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "info":
+		return Info, nil
+	case "warning", "warn":
+		return Warning, nil
+	case "critical":
+		return Critical, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q (known: info, warning, critical)", s)
+}
+
+// Alert is a notification sent through channels.
+type Alert struct {
+	Time     time.Time
+	Severity Severity
+	// Key identifies the condition the alert reports, so that repeats can
+	// be dropped. It defaults to Title.
+	Key   string
+	Title string
+	Body  string
+	// Strategy is the strategy the alert concerns, if any.
+	Strategy string
+}
+
+// Subject is a one-line summary of the alert, e.g. for an email subject.
+func (a Alert) Subject() string {
+	return fmt.Sprintf("[%s] %s", strings.ToUpper(a.Severity.String()), a.Title)
+}
+
+// Text formats the alert as plain text.
+func (a Alert) Text() string {
+	if a.Body == "" {
+		return a.Subject()
+	}
+	return a.Subject() + "\n\n" + a.Body
+}
+
+func (a Alert) key() string {
+	if a.Key != "" {
+		return a.Key
+	}
+	return a.Title
+}
+
+// Channel delivers alerts, e.g. to a webhook or a mailbox.
+type Channel interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+// Route sends alerts through a channel, subject to its limits.
+type Route struct {
+	// Name identifies the route in logs.
+	Name        string
+	Channel     Channel
+	MinSeverity Severity
+	// RateLimit is the most alerts sent per RateWindow. Zero means no
+	// limit.
+	RateLimit  int
+	RateWindow time.Duration
+	// DedupWindow drops alerts whose key was sent less than this long ago.
+	// Zero sends every alert.
+	DedupWindow time.Duration
+	// Digest sends the daily summary through the channel, whatever its
+	// MinSeverity.
+	Digest bool
+}
+
+// route is a Route with its queue and the state of its limits.
+type route struct {
+	Route
+	queue chan Alert
+
+	mu   sync.Mutex
+	sent []time.Time
+	last map[string]time.Time
+	// suppressed counts alerts dropped by the rate limit since the last
+	// one sent.
+	suppressed int
+}
+
+// admit reports whether a may be sent now, and records it if so.
+func (r *route) admit(a Alert) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := a.key()
+	if r.DedupWindow > 0 {
+		for k, t := range r.last {
+			if a.Time.Sub(t) >= r.DedupWindow {
+				delete(r.last, k)
+			}
+		}
+		if _, ok := r.last[key]; ok {
+			return false
+		}
+	}
+	if r.RateLimit > 0 {
+		i := 0
+		for i < len(r.sent) && a.Time.Sub(r.sent[i]) >= r.RateWindow {
+			i++
+		}
+		r.sent = r.sent[i:]
+		if len(r.sent) >= r.RateLimit {
+			r.suppressed++
+			return false
+		}
+		r.sent = append(r.sent, a.Time)
+	}
+	if r.DedupWindow > 0 {
+		r.last[key] = a.Time
+	}
+	return true
+}
+
+// takeSuppressed returns and resets the number of alerts dropped by the
+// rate limit.
+func (r *route) takeSuppressed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.suppressed
+	r.suppressed = 0
+	return n
+}
+
+// enqueue queues a for sending without blocking.
+func (r *route) enqueue(a Alert) {
+	select {
+	case r.queue <- a:
+	default:
+		log.Warn().Str("channel", r.Name).Str("alert", a.Title).Msg("Alert queue full; dropping alert")
+	}
+}
+
+// Notifier turns strategy and risk events into alerts and sends them
+// through its routes. It is safe for concurrent use.
+type Notifier struct {
+	// FailureThreshold is the number of consecutive failed runs of a
+	// strategy that raises an alert. Twice as many raise a critical one.
+	FailureThreshold int
+	// SendTimeout bounds how long a channel may take to send an alert.
+	SendTimeout time.Duration
+	// Digest, if set, schedules the daily summary.
+	Digest schedule.Schedule
+	// Summary, if set, adds a section, e.g. the PnL, to each summary.
+	Summary func() (string, error)
+
+	routes []*route
+
+	mu       sync.Mutex
+	failures map[string]int
+	stats    *stats
+}
+
+// New creates a Notifier sending through routes.
+func New(routes ...Route) *Notifier {
+	n := &Notifier{
+		FailureThreshold: DefaultFailureThreshold,
+		SendTimeout:      DefaultSendTimeout,
+		failures:         make(map[string]int),
+		stats:            newStats(time.Now()),
+	}
+	for _, r := range routes {
+		n.routes = append(n.routes, &route{Route: r, queue: make(chan Alert, queueSize), last: make(map[string]time.Time)})
+	}
+	return n
+}
+
+// Subscribe raises alerts for the strategy, risk, stop-loss, kill switch
+// and rebalance events on bus, and counts them for the summary.
+func (n *Notifier) Subscribe(bus *events.Bus) *events.Subscription {
+	return bus.Subscribe(events.DefaultBuffer, n.observe,
+		events.TopicStrategyFinished, events.TopicStrategyFailed, events.TopicRiskBreached,
+		events.TopicStopLoss, events.TopicKillSwitch, events.TopicRebalance, events.TopicTxConfirmed)
+}
+
+// Notify sends a through every route that admits it. Sending happens in
+// the background while Run runs.
+func (n *Notifier) Notify(a Alert) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	n.mu.Lock()
+	n.stats.alerts[a.Severity]++
+	n.mu.Unlock()
+
+	for _, r := range n.routes {
+		if a.Severity < r.MinSeverity {
+			continue
+		}
+		if !r.admit(a) {
+			log.Debug().Str("channel", r.Name).Str("alert", a.Title).Msg("Alert suppressed")
+			continue
+		}
+		r.enqueue(a)
+	}
+}
+
+// Run sends queued alerts, and the summary on the Digest schedule, until
+// ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range n.routes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.send(ctx, r)
+		}()
+	}
+	if n.Digest != nil {
+		n.runDigest(ctx)
+	}
+	wg.Wait()
+}
+
+// send delivers the alerts queued on r until ctx is cancelled.
+func (n *Notifier) send(ctx context.Context, r *route) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-r.queue:
+			if dropped := r.takeSuppressed(); dropped > 0 {
+				a.Body += fmt.Sprintf("\n\n(%d earlier alerts were suppressed by the rate limit)", dropped)
+			}
+			sendCtx, cancel := context.WithTimeout(ctx, n.SendTimeout)
+			err := r.Channel.Send(sendCtx, a)
+			cancel()
+			if err != nil {
+				log.Error().Err(err).Str("channel", r.Name).Str("alert", a.Title).Msg("Failed to send alert")
+			}
+		}
+	}
+}
+
+func (n *Notifier) observe(e events.Event) {
+	strategy := events.StrategyOf(e)
+	n.mu.Lock()
+	n.stats.observe(e)
+	n.mu.Unlock()
+
+	switch e := e.(type) {
+	case *events.StrategyFailed:
+		n.mu.Lock()
+		n.failures[strategy]++
+		failures := n.failures[strategy]
+		n.mu.Unlock()
+		if failures < n.FailureThreshold {
+			return
+		}
+		severity := Warning
+		if failures >= 2*n.FailureThreshold {
+			severity = Critical
+		}
+		n.Notify(Alert{
+			Time:     e.Time,
+			Severity: severity,
+			Key:      fmt.Sprintf("failing/%s/%s", strategy, severity),
+			Title:    fmt.Sprintf("Strategy %s has failed %d times in a row", strategy, failures),
+			Body:     fmt.Sprintf("Last error (%s): %v", e.Kind, e.Err),
+			Strategy: strategy,
+		})
+	case *events.StrategyFinished:
+		if e.Cancelled {
+			return
+		}
+		n.mu.Lock()
+		failures := n.failures[strategy]
+		delete(n.failures, strategy)
+		n.mu.Unlock()
+		if failures >= n.FailureThreshold {
+			n.Notify(Alert{
+				Time:     e.Time,
+				Severity: Info,
+				Key:      "recovered/" + strategy,
+				Title:    fmt.Sprintf("Strategy %s recovered after %d failed runs", strategy, failures),
+				Strategy: strategy,
+			})
+		}
+	case *events.RiskBreached:
+		n.Notify(Alert{
+			Time:     e.Time,
+			Severity: Warning,
+			Key:      fmt.Sprintf("risk/%s/%s", strategy, e.Rule),
+			Title:    fmt.Sprintf("Risk check %s rejected an action of %s", e.Rule, strategy),
+			Body:     fmt.Sprintf("Action: %s\nReason: %s", e.Action, e.Reason),
+			Strategy: strategy,
+		})
+	case *events.StopLoss:
+		n.Notify(Alert{
+			Time:     e.Time,
+			Severity: Critical,
+			Key:      fmt.Sprintf("stop_loss/%s/%s", strategy, e.Action),
+			Title:    fmt.Sprintf("Stop-loss triggered for %s", strategy),
+			Body:     fmt.Sprintf("Action: %s\nReason: %s", e.Action, e.Reason),
+			Strategy: strategy,
+		})
+	case *events.KillSwitch:
+		// Every change of the kill switch is worth an alert, so each has
+		// its own key.
+		a := Alert{Time: e.Time, Severity: Critical, Key: "kill_switch/engaged/" + e.Time.String(), Title: "Kill switch engaged: every strategy is halted", Body: "Reason: " + e.Reason}
+		if e.Unwind {
+			a.Body += "\nPositions are being unwound."
+		}
+		if !e.Engaged {
+			a = Alert{Time: e.Time, Severity: Warning, Key: "kill_switch/lifted/" + e.Time.String(), Title: "Kill switch lifted: strategies resume", Body: "It was engaged because: " + e.Reason}
+		}
+		n.Notify(a)
+	case *events.Rebalance:
+		n.Notify(Alert{
+			Time:     e.Time,
+			Severity: Info,
+			Key:      fmt.Sprintf("rebalance/%s/%s", strategy, e.To),
+			Title:    fmt.Sprintf("Rebalance needed: move $%.2f from %s to %s on %s", e.USD, strategy, e.To, e.Chain),
+			Body:     "The strategies have drifted from their capital allocation.",
+			Strategy: strategy,
+		})
+	}
+}
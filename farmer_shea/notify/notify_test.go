@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/events"
+)
+
+// recorder is a Channel that hands the alerts it is sent to the test.
+type recorder chan Alert
+
+func (r recorder) Send(ctx context.Context, a Alert) error {
+	r <- a
+	return nil
+}
+
+func newRoute(r Route) *route {
+	return &route{Route: r, queue: make(chan Alert, queueSize), last: make(map[string]time.Time)}
+}
+
+// queued drains the alerts queued on r.
+func queued(r *route) []Alert {
+	var alerts []Alert
+	for {
+		select {
+		case a := <-r.queue:
+			alerts = append(alerts, a)
+		default:
+			return alerts
+		}
+	}
+}
+
+func TestAdmitDedupWindow(t *testing.T) {
+	r := newRoute(Route{DedupWindow: time.Minute})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at    time.Duration
+		title string
+		want  bool
+	}{
+		{0, "a", true},
+		{30 * time.Second, "a", false},
+		{30 * time.Second, "b", true},
+		{59 * time.Second, "a", false},
+		{time.Minute, "a", true},
+		{time.Minute + 29*time.Second, "b", false},
+		{time.Minute + 30*time.Second, "b", true},
+	}
+	for _, s := range steps {
+		if got := r.admit(Alert{Time: t0.Add(s.at), Title: s.title}); got != s.want {
+			t.Errorf("admit(%q at +%s) = %v, want %v", s.title, s.at, got, s.want)
+		}
+	}
+}
+
+func TestAdmitRateLimit(t *testing.T) {
+	r := newRoute(Route{RateLimit: 2, RateWindow: time.Minute})
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{10 * time.Second, true},
+		{20 * time.Second, false},
+		{30 * time.Second, false},
+		// The first alert has left the window.
+		{time.Minute, true},
+		{time.Minute + 5*time.Second, false},
+		{time.Minute + 10*time.Second, true},
+	}
+	for i, s := range steps {
+		// Distinct titles, so only the rate limit applies.
+		a := Alert{Time: t0.Add(s.at), Title: string(rune('a' + i))}
+		if got := r.admit(a); got != s.want {
+			t.Errorf("admit at +%s = %v, want %v", s.at, got, s.want)
+		}
+	}
+	if got := r.takeSuppressed(); got != 3 {
+		t.Errorf("takeSuppressed() = %d, want 3", got)
+	}
+	if got := r.takeSuppressed(); got != 0 {
+		t.Errorf("takeSuppressed() after taking = %d, want 0", got)
+	}
+}
+
+func TestSendAppendsSuppressed(t *testing.T) {
+	sent := make(recorder, 1)
+	n := New(Route{Name: "test", Channel: sent, RateLimit: 1, RateWindow: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.send(ctx, n.routes[0])
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	n.Notify(Alert{Time: t0, Title: "first", Body: "body"})
+	if a := receive(t, sent); a.Body != "body" {
+		t.Errorf("first alert body = %q, want it unchanged", a.Body)
+	}
+
+	n.Notify(Alert{Time: t0.Add(time.Second), Title: "second"})
+	n.Notify(Alert{Time: t0.Add(2 * time.Second), Title: "third"})
+	n.Notify(Alert{Time: t0.Add(time.Minute), Title: "fourth", Body: "body"})
+	a := receive(t, sent)
+	if a.Title != "fourth" {
+		t.Fatalf("sent %q, want fourth", a.Title)
+	}
+	if want := "body\n\n(2 earlier alerts were suppressed by the rate limit)"; a.Body != want {
+		t.Errorf("body = %q, want %q", a.Body, want)
+	}
+}
+
+func receive(t *testing.T, r recorder) Alert {
+	t.Helper()
+	select {
+	case a := <-r:
+		return a
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert sent")
+		return Alert{}
+	}
+}
+
+func TestObserveFailures(t *testing.T) {
+	n := New(Route{Name: "test", Channel: make(recorder)})
+	r := n.routes[0]
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fail := func(i int) {
+		n.observe(&events.StrategyFailed{Meta: events.Meta{Time: t0.Add(time.Duration(i) * time.Minute), Strategy: "solend"}, Err: errors.New("boom"), Kind: "transient"})
+	}
+
+	for i := 1; i < DefaultFailureThreshold; i++ {
+		fail(i)
+	}
+	if alerts := queued(r); len(alerts) != 0 {
+		t.Fatalf("alerts below the threshold: %v", alerts)
+	}
+
+	fail(DefaultFailureThreshold)
+	alerts := queued(r)
+	if len(alerts) != 1 || alerts[0].Severity != Warning {
+		t.Fatalf("alerts at the threshold = %v, want one warning", alerts)
+	}
+	if !strings.Contains(alerts[0].Title, "3 times") || !strings.Contains(alerts[0].Body, "boom") {
+		t.Errorf("alert = %q / %q, want the failure count and last error", alerts[0].Title, alerts[0].Body)
+	}
+
+	for i := DefaultFailureThreshold + 1; i < 2*DefaultFailureThreshold; i++ {
+		fail(i)
+	}
+	// Repeats of the warning are not deduplicated on a route without a
+	// dedup window.
+	for _, a := range queued(r) {
+		if a.Severity != Warning {
+			t.Errorf("alert before twice the threshold has severity %s", a.Severity)
+		}
+	}
+
+	fail(2 * DefaultFailureThreshold)
+	alerts = queued(r)
+	if len(alerts) != 1 || alerts[0].Severity != Critical {
+		t.Fatalf("alerts at twice the threshold = %v, want one critical", alerts)
+	}
+
+	n.observe(&events.StrategyFinished{Meta: events.Meta{Time: t0.Add(time.Hour), Strategy: "solend"}})
+	alerts = queued(r)
+	if len(alerts) != 1 || alerts[0].Severity != Info || !strings.Contains(alerts[0].Title, "recovered after 6 failed runs") {
+		t.Fatalf("alerts on recovery = %v, want one recovery alert", alerts)
+	}
+
+	// A later success with no failures before it raises nothing.
+	n.observe(&events.StrategyFinished{Meta: events.Meta{Time: t0.Add(2 * time.Hour), Strategy: "solend"}})
+	if alerts := queued(r); len(alerts) != 0 {
+		t.Errorf("alerts after a second success: %v", alerts)
+	}
+}
+
+func TestObserveCancelledRunKeepsFailures(t *testing.T) {
+	n := New(Route{Name: "test", Channel: make(recorder)})
+	r := n.routes[0]
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < DefaultFailureThreshold; i++ {
+		n.observe(&events.StrategyFailed{Meta: events.Meta{Time: t0, Strategy: "solend"}, Err: errors.New("boom")})
+	}
+	queued(r)
+
+	n.observe(&events.StrategyFinished{Meta: events.Meta{Time: t0, Strategy: "solend"}, Cancelled: true})
+	if alerts := queued(r); len(alerts) != 0 {
+		t.Errorf("alerts after a cancelled run: %v", alerts)
+	}
+	n.observe(&events.StrategyFinished{Meta: events.Meta{Time: t0, Strategy: "solend"}})
+	if alerts := queued(r); len(alerts) != 1 || alerts[0].Severity != Info {
+		t.Errorf("alerts on recovery = %v, want one recovery alert", alerts)
+	}
+}
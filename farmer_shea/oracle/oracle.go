@@ -2,8 +2,12 @@ package oracle
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
@@ -12,14 +16,21 @@ import (
 
 const (
 	pythMappingAccount = "AHtgzX45WTKfkPG53L6WYhGEXwQkN1BVknET3sVsLL8J"
+	// maxAccountsPerRequest is the most accounts getMultipleAccounts
+	// returns at once.
+	maxAccountsPerRequest = 100
 )
 
 // Oracle defines the interface for a price oracle.	ype Oracle interface {
 	GetPrice(token string) (float64, error)
 }
 
-// Pyth is a price oracle that uses the Pyth network.	ype Pyth struct {
+// Pyth is a price oracle that uses the Pyth network. The price accounts of
+// its products are looked up once and cached.	ype Pyth struct {
 	client *rpc.Client
+
+	mu            sync.Mutex
+	priceAccounts map[string]solana.PublicKey
 }
 
 // NewPyth creates a new Pyth oracle.
@@ -29,88 +40,108 @@ func NewPyth(client *rpc.Client) *Pyth {
 
 // GetPrice returns the price of a given token.
 func (p *Pyth) GetPrice(token string) (float64, error) {
-	mappingAccount, err := solana.PublicKeyFromBase58(pythMappingAccount)
+	ctx := context.Background()
+	priceAccountKey, err := p.priceAccount(ctx, token)
 	if err != nil {
 		return 0, err
 	}
 
-	info, err := p.client.GetAccountInfo(context.Background(), mappingAccount)
+	priceInfo, err := p.client.GetAccountInfo(ctx, priceAccountKey)
 	if err != nil {
 		return 0, err
 	}
 
-	priceAccountKey, err := p.findProductAccount(info.Value.Data, token)
-	if err != nil {
+	var priceAccount PythPriceAccount
+	if err := bin.NewBinDecoder(priceInfo.Value.Data.GetBinary()).Decode(&priceAccount); err != nil {
 		return 0, err
 	}
 
-	priceInfo, err := p.client.GetAccountInfo(context.Background(), *priceAccountKey)
-	if err != nil {
-		return 0, err
+	return float64(priceAccount.Agg.Price) * math.Pow10(int(priceAccount.Exponent)), nil
+}
+
+// priceAccount returns the price account of token, loading the product
+// accounts on first use.
+func (p *Pyth) priceAccount(ctx context.Context, token string) (solana.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.priceAccounts == nil {
+		accounts, err := p.loadProducts(ctx)
+		if err != nil {
+			return solana.PublicKey{}, fmt.Errorf("failed to load Pyth products: %w", err)
+		}
+		p.priceAccounts = accounts
+	}
+	key, ok := p.priceAccounts[token]
+	if !ok {
+		return solana.PublicKey{}, fmt.Errorf("product not found for token %s", token)
 	}
+	return key, nil
+}
 
-	var priceAccount PythPriceAccount
-	if err := bin.NewBinDecoder(priceInfo.Value.Data).Decode(&priceAccount); err != nil {
-		return 0, err
+// loadProducts returns the price accounts of the products in the mapping
+// account by symbol. Product accounts are fetched in batches.
+func (p *Pyth) loadProducts(ctx context.Context) (map[string]solana.PublicKey, error) {
+	mappingAccount, err := solana.PublicKeyFromBase58(pythMappingAccount)
+	if err != nil {
+		return nil, err
 	}
 
-	return float64(priceAccount.Agg.Price) * math.Pow10(int(priceAccount.Exponent)), nil
-}
+	info, err := p.client.GetAccountInfo(ctx, mappingAccount)
+	if err != nil {
+		return nil, err
+	}
 
-func (p *Pyth) findProductAccount(data []byte, token string) (*solana.PublicKey, error) {
 	var mapping PythMappingAccount
-	if err := bin.NewBinDecoder(data).Decode(&mapping); err != nil {
+	if err := bin.NewBinDecoder(info.Value.Data.GetBinary()).Decode(&mapping); err != nil {
 		return nil, err
 	}
 
-	for _, productKey := range mapping.Products {
-		info, err := p.client.GetAccountInfo(context.Background(), productKey)
+	products := mapping.Products[:min(int(mapping.Num), len(mapping.Products))]
+	accounts := make(map[string]solana.PublicKey, len(products))
+	for start := 0; start < len(products); start += maxAccountsPerRequest {
+		batch := products[start:min(start+maxAccountsPerRequest, len(products))]
+		result, err := p.client.GetMultipleAccounts(ctx, batch...)
 		if err != nil {
-			continue
+			return nil, err
 		}
-
-		var product PythProductAccount
-		if err := bin.NewBinDecoder(info.Value.Data).Decode(&product); err != nil {
-			continue
-		}
-
-		if product.Attrs["symbol"] == token {
-			return &product.Price, nil
+		for _, account := range result.Value {
+			if account == nil {
+				continue
+			}
+			var product PythProductAccount
+			if err := bin.NewBinDecoder(account.Data.GetBinary()).Decode(&product); err != nil {
+				continue
+			}
+			if symbol := product.Attrs["symbol"]; symbol != "" {
+				accounts[symbol] = product.Price
+			}
 		}
 	}
-
-	return nil, fmt.Errorf("product not found for token %s", token)
+	return accounts, nil
 }
 
 // GetJupiterPrices returns the prices of the given tokens from the Jupiter API.
-func GetJupiterPrices(mints []string) (map[string]float64, error) {
-	baseURL := "https://price.jup.ag/v4/price?ids="
-	ids := ""
-	for i, mint := range mints {
-		ids += mint
-		if i < len(mints)-1 {
-			ids += ","
-		}
-	}
-
-	resp, err := http.Get(baseURL + ids)
+func GetJupiterPrices(ctx context.Context, mints []string) (map[string]float64, error) {
+	url := "https://price.jup.ag/v4/price?ids=" + strings.Join(mints, ",")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jupiter price API returned %s", resp.Status)
+	}
 
 	var result struct {
 		Data map[string]struct {
 			Price float64 `json:"price"`
 		} `json:"data"`
 	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
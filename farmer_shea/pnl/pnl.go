@@ -0,0 +1,302 @@
+package pnl
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/store"
+)
+
+// Method selects how the cost basis of a disposal is matched against the
+// amounts acquired before it.
+type Method string
+
+const (
+	// FIFO matches disposals against the oldest acquisitions first.
+	FIFO Method = "fifo"
+	// AverageCost matches disposals at the average cost of everything held.
+	AverageCost Method = "average"
+)
+
+// ParseMethod parses a cost basis method name.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(s); m {
+	case FIFO, AverageCost:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown cost basis method %q (known: %s, %s)", s, FIFO, AverageCost)
+}
+
+// Summary is the PnL of a set of ledger entries, in USD.
+type Summary struct {
+	// Realized is the proceeds of disposals less their cost basis. It
+	// includes any impermanent loss.
+	Realized float64
+	// Unrealized is the current value of open positions less their cost
+	// basis.
+	Unrealized float64
+	// Yield is what positions earned in their own asset, e.g. vault or
+	// staking rewards withdrawn on top of the deposit, and collected fees.
+	Yield float64
+	// Fees is gas and other fees paid.
+	Fees float64
+	// ImpermanentLoss is the value LP positions lost compared with holding
+	// the amounts minted, measured when they are burned. It is negative if
+	// the LP did better.
+	ImpermanentLoss float64
+	// Cost and Value are the cost basis and current value of open
+	// positions.
+	Cost  float64
+	Value float64
+}
+
+// Net returns the overall PnL: realized and unrealized PnL plus yield, less
+// fees.
+func (s Summary) Net() float64 {
+	return s.Realized + s.Unrealized + s.Yield - s.Fees
+}
+
+func (s *Summary) add(o Summary) {
+	s.Realized += o.Realized
+	s.Unrealized += o.Unrealized
+	s.Yield += o.Yield
+	s.Fees += o.Fees
+	s.ImpermanentLoss += o.ImpermanentLoss
+	s.Cost += o.Cost
+	s.Value += o.Value
+}
+
+// Line is the PnL of one asset of one strategy in one wallet.
+type Line struct {
+	Strategy string
+	Wallet   string
+	Asset    string
+	Summary
+}
+
+// Report is the PnL of a ledger per strategy, wallet and asset, per
+// strategy, per wallet, per asset and in total.
+type Report struct {
+	Method Method
+	// Lines are ordered by strategy, then wallet, then asset.
+	Lines      []Line
+	ByStrategy map[string]Summary
+	ByWallet   map[string]Summary
+	ByAsset    map[string]Summary
+	Total      Summary
+}
+
+// PriceFunc returns the current price in USD of asset on chain c, or false
+// if it is unknown.
+type PriceFunc func(c chain.ID, asset string) (float64, bool)
+
+// dust is the amount below which a holding is treated as empty, so that
+// rounding errors do not leave positions open.
+const dust = 1e-9
+
+// Compute computes the PnL of entries, which must be in the order they were
+// recorded. Open positions are valued with price; those it cannot price are
+// valued at cost.
+//
+// Each strategy's holdings are tracked per wallet, chain, asset and
+// position, so a strategy moved to another wallet keeps the positions it
+// opened in the old one there.
+// Deposits, stakes, LP mints and buys acquire; withdrawals, unstakes and
+// sells dispose. Withdrawing more than was deposited counts the excess as
+// yield. An LP burn disposes of everything the position holds of its asset,
+// so a burn should be recorded for each leg, even if nothing of it was
+// returned.
+func Compute(entries []store.Entry, method Method, price PriceFunc) Report {
+	type holdingKey struct {
+		strategy string
+		wallet   string
+		chain    chain.ID
+		asset    string
+		position string
+	}
+	type lineKey struct{ strategy, wallet, asset string }
+	type lpKey struct{ strategy, wallet, position string }
+	type lpLeg struct {
+		minted, burned float64
+		burnPrice      float64
+		burnt          bool
+	}
+
+	holdings := make(map[holdingKey]*inventory)
+	var holdingOrder []holdingKey
+	lines := make(map[lineKey]*Summary)
+	lps := make(map[lpKey]map[string]*lpLeg)
+
+	line := func(strategy, wallet, asset string) *Summary {
+		k := lineKey{strategy, wallet, asset}
+		if lines[k] == nil {
+			lines[k] = &Summary{}
+		}
+		return lines[k]
+	}
+	leg := func(e store.Entry) *lpLeg {
+		k := lpKey{e.Strategy, e.Wallet, e.Position}
+		if lps[k] == nil {
+			lps[k] = make(map[string]*lpLeg)
+		}
+		if lps[k][e.Asset] == nil {
+			lps[k][e.Asset] = &lpLeg{}
+		}
+		return lps[k][e.Asset]
+	}
+
+	for _, e := range entries {
+		k := holdingKey{e.Strategy, e.Wallet, e.Chain, e.Asset, e.Position}
+		inv := holdings[k]
+		if inv == nil {
+			inv = &inventory{method: method}
+			holdings[k] = inv
+			holdingOrder = append(holdingOrder, k)
+		}
+		s := line(e.Strategy, e.Wallet, e.Asset)
+		s.Fees += e.Fee
+
+		switch e.Kind {
+		case store.EntryDeposit, store.EntryStake, store.EntryBuy:
+			inv.add(e.Amount, e.Value())
+		case store.EntryLPMint:
+			inv.add(e.Amount, e.Value())
+			leg(e).minted += e.Amount
+		case store.EntryWithdraw, store.EntryUnstake, store.EntrySell:
+			taken, cost := inv.remove(e.Amount)
+			s.Realized += taken*e.Price - cost
+			if excess := e.Amount - taken; excess > dust {
+				if e.Kind == store.EntrySell {
+					s.Realized += excess * e.Price
+				} else {
+					s.Yield += excess * e.Price
+				}
+			}
+		case store.EntryLPBurn:
+			_, cost := inv.remove(inv.amount())
+			s.Realized += e.Value() - cost
+			l := leg(e)
+			l.burned += e.Amount
+			l.burnPrice = e.Price
+			l.burnt = true
+		case store.EntryFeeCollect:
+			s.Yield += e.Value()
+		case store.EntryFee:
+			s.Fees += e.Value()
+		}
+	}
+
+	for k, legs := range lps {
+		for asset, l := range legs {
+			if l.burnt && l.burnPrice != 0 {
+				line(k.strategy, k.wallet, asset).ImpermanentLoss += (l.minted - l.burned) * l.burnPrice
+			}
+		}
+	}
+
+	for _, k := range holdingOrder {
+		inv := holdings[k]
+		amount := inv.amount()
+		if amount <= dust {
+			continue
+		}
+		cost := inv.cost()
+		s := line(k.strategy, k.wallet, k.asset)
+		s.Cost += cost
+		if p, ok := price(k.chain, k.asset); ok {
+			s.Value += amount * p
+			s.Unrealized += amount*p - cost
+		} else {
+			s.Value += cost
+		}
+	}
+
+	r := Report{
+		Method:     method,
+		ByStrategy: make(map[string]Summary),
+		ByWallet:   make(map[string]Summary),
+		ByAsset:    make(map[string]Summary),
+	}
+	for k, s := range lines {
+		r.Lines = append(r.Lines, Line{Strategy: k.strategy, Wallet: k.wallet, Asset: k.asset, Summary: *s})
+	}
+	sort.Slice(r.Lines, func(i, j int) bool {
+		if r.Lines[i].Strategy != r.Lines[j].Strategy {
+			return r.Lines[i].Strategy < r.Lines[j].Strategy
+		}
+		if r.Lines[i].Wallet != r.Lines[j].Wallet {
+			return r.Lines[i].Wallet < r.Lines[j].Wallet
+		}
+		return r.Lines[i].Asset < r.Lines[j].Asset
+	})
+	for _, l := range r.Lines {
+		byStrategy, byWallet, byAsset := r.ByStrategy[l.Strategy], r.ByWallet[l.Wallet], r.ByAsset[l.Asset]
+		byStrategy.add(l.Summary)
+		byWallet.add(l.Summary)
+		byAsset.add(l.Summary)
+		r.ByStrategy[l.Strategy], r.ByWallet[l.Wallet], r.ByAsset[l.Asset] = byStrategy, byWallet, byAsset
+		r.Total.add(l.Summary)
+	}
+	return r
+}
+
+// lot is an amount acquired at once and its cost in USD.
+type lot struct {
+	amount float64
+	cost   float64
+}
+
+// inventory holds the lots of one asset. With AverageCost, everything held
+// is pooled in a single lot.
+type inventory struct {
+	method Method
+	lots   []lot
+}
+
+func (inv *inventory) add(amount, cost float64) {
+	if inv.method == AverageCost && len(inv.lots) > 0 {
+		inv.lots[0].amount += amount
+		inv.lots[0].cost += cost
+		return
+	}
+	inv.lots = append(inv.lots, lot{amount: amount, cost: cost})
+}
+
+// remove takes up to amount from the oldest lots first and returns the
+// amount taken and its cost basis.
+func (inv *inventory) remove(amount float64) (taken, cost float64) {
+	for amount > dust && len(inv.lots) > 0 {
+		l := &inv.lots[0]
+		if l.amount <= amount+dust {
+			taken += l.amount
+			cost += l.cost
+			amount -= l.amount
+			inv.lots = inv.lots[1:]
+			continue
+		}
+		c := l.cost * amount / l.amount
+		l.amount -= amount
+		l.cost -= c
+		taken += amount
+		cost += c
+		amount = 0
+	}
+	return taken, cost
+}
+
+func (inv *inventory) amount() float64 {
+	var total float64
+	for _, l := range inv.lots {
+		total += l.amount
+	}
+	return total
+}
+
+func (inv *inventory) cost() float64 {
+	var total float64
+	for _, l := range inv.lots {
+		total += l.cost
+	}
+	return total
+}
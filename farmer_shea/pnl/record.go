@@ -0,0 +1,40 @@
+package pnl
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/store"
+)
+
+// Pricer returns the current price in USD of an asset, identified as in a
+// ledger entry.
+type Pricer interface {
+	Price(ctx context.Context, c chain.ID, asset string) (float64, error)
+}
+
+type pricerKey struct{}
+
+// WithPricer returns a copy of ctx in which Record prices entries with p.
+func WithPricer(ctx context.Context, p Pricer) context.Context {
+	return context.WithValue(ctx, pricerKey{}, p)
+}
+
+// Record records a ledger entry of the strategy running under ctx, pricing
+// it with the context's Pricer if it has no price. It is a no-op unless ctx
+// carries a live run. An entry that cannot be priced is recorded without a
+// price.
+func Record(ctx context.Context, e store.Entry) {
+	if !store.Recording(ctx) {
+		return
+	}
+	if p, ok := ctx.Value(pricerKey{}).(Pricer); ok && e.Price == 0 {
+		price, err := p.Price(ctx, e.Chain, e.Asset)
+		if err != nil {
+			log.Warn().Err(err).Str("chain", string(e.Chain)).Str("asset", e.Asset).Msg("Failed to price ledger entry")
+		}
+		e.Price = price
+	}
+	store.RecordEntry(ctx, e)
+}
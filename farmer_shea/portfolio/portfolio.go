@@ -0,0 +1,261 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/oracle"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/wallet"
+)
+
+// DefaultInterval is how often a Tracker collects balances unless told
+// otherwise.
+const DefaultInterval = 5 * time.Minute
+
+// Holding is the amount of an asset a wallet holds on one chain.
+type Holding struct {
+	Wallet string
+	Chain  chain.ID
+	// Account distinguishes holdings of the same asset kept in different
+	// places on a chain, e.g. "perps" or a vault address. It is empty for
+	// the wallet's own balance.
+	Account string
+	// Asset is the asset's symbol, or its Token if the symbol is unknown.
+	Asset string
+	// Token identifies the asset on its chain, e.g. a mint, contract address
+	// or coin type. It is empty for native assets other than SOL.
+	Token  string
+	Amount float64
+	// Price is in USD and zero if unknown. Value is Amount times Price.
+	Price float64
+	Value float64
+}
+
+// Snapshot is the holdings of every wallet at a point in time.
+type Snapshot struct {
+	Time     time.Time
+	Holdings []Holding
+	// Errors lists the balances that could not be collected. Their
+	// holdings are missing from the snapshot.
+	Errors []error
+}
+
+// TotalValue returns the value in USD of every priced holding.
+func (s Snapshot) TotalValue() float64 {
+	var total float64
+	for _, h := range s.Holdings {
+		total += h.Value
+	}
+	return total
+}
+
+// ByWallet returns the value in USD of the priced holdings of each wallet.
+func (s Snapshot) ByWallet() map[string]float64 {
+	values := make(map[string]float64)
+	for _, h := range s.Holdings {
+		values[h.Wallet] += h.Value
+	}
+	return values
+}
+
+// Balances returns the holdings as balance records for the state store.
+func (s Snapshot) Balances() []store.Balance {
+	balances := make([]store.Balance, len(s.Holdings))
+	for i, h := range s.Holdings {
+		balances[i] = store.Balance{
+			Time:    s.Time,
+			Wallet:  h.Wallet,
+			Chain:   h.Chain,
+			Account: h.Account,
+			Asset:   h.Asset,
+			Amount:  h.Amount,
+			Value:   h.Value,
+		}
+	}
+	return balances
+}
+
+// Source collects the balances of an address on one chain.
+type Source interface {
+	Chain() chain.ID
+	// Balances returns the non-zero holdings of address. The tracker fills
+	// in the wallet and any missing prices.
+	Balances(ctx context.Context, address string) ([]Holding, error)
+}
+
+// Tracker periodically collects the balances of every wallet on each chain
+// it has a key for, and values them with a price oracle.
+type Tracker struct {
+	// Interval is how often Run collects a snapshot.
+	Interval time.Duration
+	// Events, if set, receives a PriceUpdated event for each asset priced
+	// in a snapshot.
+	Events *events.Bus
+
+	wallets *wallet.Set
+	oracle  oracle.Oracle
+	sources map[chain.ID]Source
+
+	mu         sync.Mutex
+	latest     Snapshot
+	onSnapshot []func(Snapshot)
+}
+
+// NewTracker creates a Tracker that collects the balances of wallets from
+// sources, at most one per chain, and prices them with o.
+func NewTracker(wallets *wallet.Set, o oracle.Oracle, sources ...Source) *Tracker {
+	t := &Tracker{
+		Interval: DefaultInterval,
+		wallets:  wallets,
+		oracle:   o,
+		sources:  make(map[chain.ID]Source, len(sources)),
+	}
+	for _, s := range sources {
+		t.sources[s.Chain()] = s
+	}
+	return t
+}
+
+// OnSnapshot registers fn to be called with each snapshot Run collects.
+func (t *Tracker) OnSnapshot(fn func(Snapshot)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onSnapshot = append(t.onSnapshot, fn)
+}
+
+// Latest returns the most recent snapshot, or an empty one if none has been
+// collected yet.
+func (t *Tracker) Latest() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.latest
+}
+
+// Run collects a snapshot at once and then every Interval until ctx is
+// cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		s := t.Collect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		t.mu.Lock()
+		handlers := t.onSnapshot
+		t.mu.Unlock()
+		for _, fn := range handlers {
+			fn(s)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect collects and prices the balances of every wallet. Chains whose
+// balances cannot be collected are logged and reported in the snapshot's
+// Errors.
+func (t *Tracker) Collect(ctx context.Context) Snapshot {
+	type job struct {
+		wallet  string
+		source  Source
+		address string
+	}
+	var jobs []job
+	for _, name := range t.wallets.Names() {
+		w, err := t.wallets.Get(name)
+		if err != nil {
+			continue
+		}
+		for _, id := range w.Keys.Chains() {
+			source, ok := t.sources[id]
+			if !ok {
+				continue
+			}
+			s, err := w.Keys.Signer(id)
+			if err != nil {
+				continue
+			}
+			jobs = append(jobs, job{wallet: name, source: source, address: s.Address()})
+		}
+	}
+
+	// Results are kept in job order so that snapshots list holdings in a
+	// stable order.
+	holdings := make([][]Holding, len(jobs))
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hs, err := j.source.Balances(ctx, j.address)
+			if err != nil {
+				errs[i] = fmt.Errorf("wallet %s on %s: %w", j.wallet, j.source.Chain(), err)
+				return
+			}
+			for k := range hs {
+				hs[k].Wallet = j.wallet
+				hs[k].Chain = j.source.Chain()
+			}
+			holdings[i] = hs
+		}()
+	}
+	wg.Wait()
+
+	s := Snapshot{Time: time.Now()}
+	for i := range jobs {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Msg("Failed to collect balances")
+			s.Errors = append(s.Errors, errs[i])
+			continue
+		}
+		s.Holdings = append(s.Holdings, holdings[i]...)
+	}
+	t.price(ctx, s.Holdings)
+	t.publishPrices(s)
+
+	t.mu.Lock()
+	t.latest = s
+	t.mu.Unlock()
+	return s
+}
+
+// publishPrices publishes the price of each asset priced in s once.
+func (t *Tracker) publishPrices(s Snapshot) {
+	if t.Events == nil {
+		return
+	}
+	type asset struct {
+		chain chain.ID
+		name  string
+	}
+	seen := make(map[asset]bool)
+	for _, h := range s.Holdings {
+		a := asset{h.Chain, h.Asset}
+		if h.Price == 0 || seen[a] {
+			continue
+		}
+		seen[a] = true
+		t.Events.Publish(&events.PriceUpdated{Meta: events.Meta{Time: s.Time}, Chain: h.Chain, Asset: h.Asset, Price: h.Price})
+	}
+}
+
+// amount converts a raw amount in an asset's smallest unit to whole units.
+func amount(raw *big.Int, decimals uint8) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(raw), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))).Float64()
+	return f
+}
@@ -0,0 +1,107 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/oracle"
+)
+
+// price fills in the missing prices and the values of holdings. Solana
+// tokens are priced by mint from Jupiter and everything else by symbol from
+// the tracker's oracle. Holdings that cannot be priced are left unvalued.
+func (t *Tracker) price(ctx context.Context, holdings []Holding) {
+	var mints []string
+	seen := make(map[string]bool)
+	for _, h := range holdings {
+		if h.Price == 0 && h.Chain == chain.Solana && h.Token != "" && !seen[h.Token] {
+			seen[h.Token] = true
+			mints = append(mints, h.Token)
+		}
+	}
+	var byMint map[string]float64
+	if len(mints) > 0 {
+		var err error
+		byMint, err = oracle.GetJupiterPrices(ctx, mints)
+		if err != nil {
+			log.Warn().Err(err).Int("mints", len(mints)).Msg("Failed to get Jupiter prices")
+		}
+	}
+
+	bySymbol := make(map[string]float64)
+	for i := range holdings {
+		h := &holdings[i]
+		if h.Price == 0 {
+			if p, ok := byMint[h.Token]; ok && h.Chain == chain.Solana {
+				h.Price = p
+			} else {
+				h.Price = t.symbolPrice(h.Asset, bySymbol)
+			}
+		}
+		h.Value = h.Amount * h.Price
+	}
+}
+
+// symbolPrice returns the oracle price of symbol, or zero if it is unknown.
+// Prices, including failures, are cached in cache.
+func (t *Tracker) symbolPrice(symbol string, cache map[string]float64) float64 {
+	if p, ok := cache[symbol]; ok {
+		return p
+	}
+	var p float64
+	if t.oracle != nil {
+		var err error
+		p, err = t.oracle.GetPrice(symbol)
+		if err != nil {
+			log.Warn().Err(err).Str("asset", symbol).Msg("Failed to price asset")
+			p = 0
+		}
+	}
+	cache[symbol] = p
+	return p
+}
+
+// Price returns the price in USD of asset on chain c, as seen in s. asset is
+// matched against both the symbol and the token of each holding.
+func (s Snapshot) Price(c chain.ID, asset string) (float64, bool) {
+	for _, h := range s.Holdings {
+		if h.Chain == c && h.Price != 0 && (h.Asset == asset || strings.EqualFold(h.Token, asset)) {
+			return h.Price, true
+		}
+	}
+	return 0, false
+}
+
+// Price returns the current price in USD of asset on chain c, identified by
+// symbol or token. Prices in the latest snapshot are used if present;
+// otherwise Solana tokens are priced by mint from Jupiter and other assets by
+// symbol from the oracle.
+func (t *Tracker) Price(ctx context.Context, c chain.ID, asset string) (float64, error) {
+	if p, ok := t.Latest().Price(c, asset); ok {
+		return p, nil
+	}
+	if c == chain.Solana {
+		mint := asset
+		if asset == "SOL" {
+			mint = wrappedSOL
+		}
+		if _, err := solanago.PublicKeyFromBase58(mint); err == nil {
+			prices, err := oracle.GetJupiterPrices(ctx, []string{mint})
+			if err != nil {
+				return 0, err
+			}
+			if p, ok := prices[mint]; ok {
+				return p, nil
+			}
+			return 0, fmt.Errorf("no Jupiter price for mint %s", mint)
+		}
+	}
+	if t.oracle == nil {
+		return 0, fmt.Errorf("no price for %s on %s", asset, c)
+	}
+	return t.oracle.GetPrice(asset)
+}
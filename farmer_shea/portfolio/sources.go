@@ -0,0 +1,187 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	solanago "github.com/gagliardetto/solana-go"
+	"github.com/sheawinkler/farmer-shea/base"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/hyperliquid"
+	"github.com/sheawinkler/farmer-shea/solana"
+	"github.com/sheawinkler/farmer-shea/sui"
+)
+
+// wrappedSOL is the mint SOL is priced under.
+const wrappedSOL = "So11111111111111111111111111111111111111112"
+
+// solanaSymbols names well-known SPL mints. Other tokens are listed by mint.
+var solanaSymbols = map[string]string{
+	wrappedSOL: "wSOL",
+	"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v": "USDC",
+	"Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB": "USDT",
+	"mSoLzYCxHdYgdzU16g5QSh3i5K3z3KZK7ytfqcJm7So":  "mSOL",
+}
+
+// Solana collects SOL and SPL token balances.
+type Solana struct {
+	client *solana.Client
+}
+
+// NewSolana creates a Source for Solana.
+func NewSolana(client *solana.Client) *Solana {
+	return &Solana{client: client}
+}
+
+func (s *Solana) Chain() chain.ID { return chain.Solana }
+
+// Balances returns the SOL balance and SPL token balances of address.
+func (s *Solana) Balances(ctx context.Context, address string) ([]Holding, error) {
+	owner, err := solanago.PublicKeyFromBase58(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", address, err)
+	}
+
+	var holdings []Holding
+	lamports, err := s.client.GetSOLBalance(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SOL balance: %w", err)
+	}
+	if lamports > 0 {
+		holdings = append(holdings, Holding{Asset: "SOL", Token: wrappedSOL, Amount: float64(lamports) / float64(solanago.LAMPORTS_PER_SOL)})
+	}
+
+	tokens, err := s.client.GetTokenBalances(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token balances: %w", err)
+	}
+	for _, t := range tokens {
+		mint := t.Mint.String()
+		symbol, ok := solanaSymbols[mint]
+		if !ok {
+			symbol = mint
+		}
+		holdings = append(holdings, Holding{Asset: symbol, Token: mint, Amount: amount(t.Amount, t.Decimals)})
+	}
+	return holdings, nil
+}
+
+// Base collects ETH and ERC-20 token balances.
+type Base struct {
+	client *base.Client
+	tokens []common.Address
+}
+
+// NewBase creates a Source for Base that tracks ETH and the given ERC-20
+// tokens.
+func NewBase(client *base.Client, tokens ...common.Address) *Base {
+	return &Base{client: client, tokens: tokens}
+}
+
+func (b *Base) Chain() chain.ID { return chain.Base }
+
+// Balances returns the ETH balance of address and its balance of each
+// tracked token.
+func (b *Base) Balances(ctx context.Context, address string) ([]Holding, error) {
+	owner := common.HexToAddress(address)
+
+	var holdings []Holding
+	wei, err := b.client.ETHBalance(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ETH balance: %w", err)
+	}
+	if wei.Sign() > 0 {
+		holdings = append(holdings, Holding{Asset: "ETH", Amount: amount(wei, 18)})
+	}
+
+	for _, token := range b.tokens {
+		t, err := b.client.TokenBalance(ctx, token, owner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balance of token %s: %w", token, err)
+		}
+		if t.Amount.Sign() > 0 {
+			holdings = append(holdings, Holding{Asset: t.Symbol, Token: token.Hex(), Amount: amount(t.Amount, t.Decimals)})
+		}
+	}
+	return holdings, nil
+}
+
+// Hyperliquid collects spot balances, perps account value and vault
+// equity. Perps and vault equity are valued in USDC.
+type Hyperliquid struct {
+	client *hyperliquid.Client
+}
+
+// NewHyperliquid creates a Source for Hyperliquid.
+func NewHyperliquid(client *hyperliquid.Client) *Hyperliquid {
+	return &Hyperliquid{client: client}
+}
+
+func (h *Hyperliquid) Chain() chain.ID { return chain.Hyperliquid }
+
+// Balances returns the spot balances of address, its perps account value
+// and its equity in each vault.
+func (h *Hyperliquid) Balances(ctx context.Context, address string) ([]Holding, error) {
+	var holdings []Holding
+	spot, err := h.client.GetSpotBalances(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spot balances: %w", err)
+	}
+	for _, b := range spot {
+		if b.Total == 0 {
+			continue
+		}
+		holding := Holding{Account: "spot", Asset: b.Coin, Amount: b.Total}
+		if b.Coin == "USDC" {
+			holding.Price = 1
+		}
+		holdings = append(holdings, holding)
+	}
+
+	perps, err := h.client.GetPerpAccountValue(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get perps account value: %w", err)
+	}
+	if perps != 0 {
+		holdings = append(holdings, Holding{Account: "perps", Asset: "USDC", Amount: perps, Price: 1})
+	}
+
+	vaults, err := h.client.GetVaultEquities(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault equities: %w", err)
+	}
+	for _, v := range vaults {
+		holdings = append(holdings, Holding{Account: v.VaultAddress, Asset: "USDC", Amount: v.Equity, Price: 1})
+	}
+	return holdings, nil
+}
+
+// Sui collects coin balances.
+type Sui struct {
+	client *sui.Client
+}
+
+// NewSui creates a Source for Sui.
+func NewSui(client *sui.Client) *Sui {
+	return &Sui{client: client}
+}
+
+func (s *Sui) Chain() chain.ID { return chain.Sui }
+
+// Balances returns the balance of each coin type address holds.
+func (s *Sui) Balances(ctx context.Context, address string) ([]Holding, error) {
+	coins, err := s.client.GetBalances(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coin balances: %w", err)
+	}
+	holdings := make([]Holding, 0, len(coins))
+	for _, c := range coins {
+		symbol := c.Symbol
+		if symbol == "" {
+			symbol = c.CoinType
+		}
+		holdings = append(holdings, Holding{Asset: symbol, Token: c.CoinType, Amount: amount(c.Amount, c.Decimals)})
+	}
+	return holdings, nil
+}
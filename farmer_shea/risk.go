@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/config"
+	"github.com/sheawinkler/farmer-shea/risk"
+)
+
+// riskLimits converts the risk config into limits for the risk manager.
+func riskLimits(cfg config.RiskConfig) risk.Limits {
+	reserve := make(map[chain.ID]float64, len(cfg.MinReserve))
+	for id, amount := range cfg.MinReserve {
+		reserve[chain.ID(id)] = amount
+	}
+	return risk.Limits{
+		MaxNotional:     cfg.MaxNotionalUSD,
+		MaxProtocol:     risk.Allocation(cfg.MaxAllocation.Protocol),
+		MaxChain:        risk.Allocation(cfg.MaxAllocation.Chain),
+		MaxAsset:        risk.Allocation(cfg.MaxAllocation.Asset),
+		MaxDailyLoss:    cfg.MaxDailyLossUSD,
+		MaxDailyGas:     cfg.MaxDailyGasUSD,
+		MinReserve:      reserve,
+		HaltOnDailyLoss: cfg.HaltOnDailyLoss,
+		UnwindOnHalt:    cfg.UnwindOnHalt,
+	}
+}
@@ -0,0 +1,451 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/executor"
+	"github.com/sheawinkler/farmer-shea/portfolio"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/strategy"
+)
+
+// Native maps each chain to the asset its fees are paid in.
+var Native = map[chain.ID]string{
+	chain.Solana: "SOL",
+	chain.Base:   "ETH",
+	chain.Sui:    "SUI",
+}
+
+// Allocation caps the share of the portfolio's value held in positions, by
+// name, e.g. of a protocol. The "*" entry applies to names without their
+// own. Names are matched case-insensitively.
+type Allocation map[string]float64
+
+// limit returns the cap for name, or 0 if it has none.
+func (a Allocation) limit(name string) float64 {
+	for k, v := range a {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return a["*"]
+}
+
+// Limits are the limits the Manager enforces. Zero disables a limit.
+type Limits struct {
+	// MaxNotional is the most an action may move, in USD.
+	MaxNotional float64
+	// MaxProtocol, MaxChain and MaxAsset cap the share of the portfolio
+	// held in positions per protocol, chain and asset.
+	MaxProtocol Allocation
+	MaxChain    Allocation
+	MaxAsset    Allocation
+	// MaxDailyLoss is the most the portfolio may lose in value since the
+	// start of the day (UTC), in USD.
+	MaxDailyLoss float64
+	// MaxDailyGas is the most spent on fees since the start of the day
+	// (UTC), in USD.
+	MaxDailyGas float64
+	// MinReserve is the balance of the native asset of each chain a wallet
+	// keeps for fees, in whole units.
+	MinReserve map[chain.ID]float64
+	// HaltOnDailyLoss engages the kill switch once MaxDailyLoss is
+	// exceeded, and UnwindOnHalt then unwinds positions too.
+	HaltOnDailyLoss bool
+	UnwindOnHalt    bool
+}
+
+// Portfolio supplies the holdings and prices the Manager values actions
+// and positions with, e.g. *portfolio.Tracker.
+type Portfolio interface {
+	Latest() portfolio.Snapshot
+	Price(ctx context.Context, c chain.ID, asset string) (float64, error)
+}
+
+// HaltState describes the kill switch.
+type HaltState struct {
+	Halted bool      `json:"halted"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+	// Unwind is set if positions are to be unwound.
+	Unwind bool `json:"unwind,omitempty"`
+}
+
+// Manager enforces Limits on the actions strategies submit, and holds the
+// kill switch that stops them all. It is safe for concurrent use.
+type Manager struct {
+	// Events, if set, receives a KillSwitch event whenever the kill switch
+	// is engaged or lifted.
+	Events *events.Bus
+
+	limits    Limits
+	store     *store.Store
+	portfolio Portfolio
+
+	mu     sync.Mutex
+	halt   HaltState
+	onHalt []func(HaltState)
+	// day is the start of the current day and dayValue the portfolio's
+	// value then, or zero if unknown.
+	day      time.Time
+	dayValue float64
+}
+
+// NewManager creates a Manager enforcing limits. Positions, fees and past
+// portfolio values are read from st; current values from p.
+func NewManager(limits Limits, st *store.Store, p Portfolio) *Manager {
+	return &Manager{limits: limits, store: st, portfolio: p}
+}
+
+// OnHalt registers fn to be called whenever the kill switch is engaged,
+// including automatically, e.g. to halt the executor.
+func (m *Manager) OnHalt(fn func(HaltState)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onHalt = append(m.onHalt, fn)
+}
+
+// Halt engages the kill switch: every action is rejected, except those of
+// runs unwinding positions, until Lift is called. unwind asks for positions
+// to be unwound. Halting again while halted has no effect.
+func (m *Manager) Halt(reason string, unwind bool) {
+	m.mu.Lock()
+	if m.halt.Halted {
+		m.mu.Unlock()
+		return
+	}
+	m.halt = HaltState{Halted: true, Reason: reason, Since: time.Now(), Unwind: unwind}
+	state, fns := m.halt, make([]func(HaltState), len(m.onHalt))
+	copy(fns, m.onHalt)
+	m.mu.Unlock()
+
+	log.Error().Str("reason", reason).Bool("unwind", unwind).Msg("Kill switch engaged")
+	m.publish(&events.KillSwitch{Engaged: true, Reason: reason, Unwind: unwind})
+	for _, fn := range fns {
+		fn(state)
+	}
+}
+
+// Lift disengages the kill switch. It reports whether it was engaged.
+func (m *Manager) Lift() bool {
+	m.mu.Lock()
+	h := m.halt
+	m.halt = HaltState{}
+	m.mu.Unlock()
+
+	if !h.Halted {
+		return false
+	}
+	log.Warn().Str("reason", h.Reason).Msg("Kill switch lifted")
+	m.publish(&events.KillSwitch{Reason: h.Reason})
+	return true
+}
+
+func (m *Manager) publish(e events.Event) {
+	if m.Events != nil {
+		m.Events.Publish(e)
+	}
+}
+
+// State returns the state of the kill switch.
+func (m *Manager) State() HaltState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.halt
+}
+
+// Checkers returns a Checker for each rule, for the executor.
+func (m *Manager) Checkers() []executor.Checker {
+	return []executor.Checker{
+		rule{"kill_switch", m.checkHalt},
+		rule{"max_notional", m.checkNotional},
+		rule{"max_allocation", m.checkAllocation},
+		rule{"max_daily_loss", m.checkDailyLoss},
+		rule{"max_daily_gas", m.checkDailyGas},
+		rule{"min_reserve", m.checkReserve},
+	}
+}
+
+// Observe checks the daily loss of a new portfolio snapshot and engages
+// the kill switch if it is exceeded and HaltOnDailyLoss is set.
+func (m *Manager) Observe(s portfolio.Snapshot) {
+	if m.limits.MaxDailyLoss <= 0 || !m.limits.HaltOnDailyLoss {
+		return
+	}
+	if err := m.dailyLoss(s); err != nil {
+		m.Halt(err.Error(), m.limits.UnwindOnHalt)
+	}
+}
+
+// rule is a named check.
+type rule struct {
+	name  string
+	check func(ctx context.Context, a action.Action) error
+}
+
+func (r rule) Name() string { return r.name }
+
+// Check implements executor.Checker.
+func (r rule) Check(ctx context.Context, a action.Action) error {
+	return r.check(ctx, a)
+}
+
+// exits reports whether a takes funds out of a position. Exits only need
+// to pass the kill switch, so that limits never trap funds.
+func exits(a action.Action) bool {
+	return a.Kind == action.Withdraw || a.Kind == action.VaultWithdraw
+}
+
+func (m *Manager) checkHalt(ctx context.Context, a action.Action) error {
+	h := m.State()
+	if h.Halted && !strategy.Unwinding(ctx) {
+		return fmt.Errorf("trading is halted since %s: %s", h.Since.Format(time.RFC3339), h.Reason)
+	}
+	return nil
+}
+
+func (m *Manager) checkNotional(ctx context.Context, a action.Action) error {
+	if m.limits.MaxNotional <= 0 || exits(a) {
+		return nil
+	}
+	_, value, err := m.legValues(ctx, a)
+	if err != nil {
+		return err
+	}
+	if value > m.limits.MaxNotional {
+		return fmt.Errorf("%s is worth %.2f USD, above the limit of %.2f USD per action", a.Kind, value, m.limits.MaxNotional)
+	}
+	return nil
+}
+
+func (m *Manager) checkAllocation(ctx context.Context, a action.Action) error {
+	l := m.limits
+	if len(l.MaxProtocol)+len(l.MaxChain)+len(l.MaxAsset) == 0 || exits(a) || a.Kind == action.Swap || a.Kind == action.PlaceOrder {
+		return nil
+	}
+	total := m.portfolio.Latest().TotalValue()
+	if total <= 0 {
+		return fmt.Errorf("the portfolio's value is not known yet")
+	}
+	legs, err := a.Legs()
+	if err != nil {
+		return err
+	}
+	values, value, err := m.legValues(ctx, a)
+	if err != nil {
+		return err
+	}
+	exposure, err := m.exposure(ctx)
+	if err != nil {
+		return err
+	}
+
+	type dimension struct {
+		kind, name string
+		limits     Allocation
+		value      float64
+	}
+	dims := []dimension{
+		{"protocol", a.Protocol, l.MaxProtocol, value},
+		{"chain", string(a.Chain), l.MaxChain, value},
+	}
+	for i, leg := range legs {
+		dims = append(dims, dimension{"asset", leg.Asset, l.MaxAsset, values[i]})
+	}
+	for _, dim := range dims {
+		limit := dim.limits.limit(dim.name)
+		if limit <= 0 {
+			continue
+		}
+		share := (exposure[dim.kind+"/"+strings.ToLower(dim.name)] + dim.value) / total
+		if share > limit {
+			return fmt.Errorf("would put %.1f%% of the portfolio in %s %s, above the limit of %.1f%%", share*100, dim.kind, dim.name, limit*100)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) checkDailyLoss(ctx context.Context, a action.Action) error {
+	if m.limits.MaxDailyLoss <= 0 || exits(a) {
+		return nil
+	}
+	return m.dailyLoss(m.portfolio.Latest())
+}
+
+func (m *Manager) checkDailyGas(ctx context.Context, a action.Action) error {
+	if m.limits.MaxDailyGas <= 0 || exits(a) || m.store == nil {
+		return nil
+	}
+	entries, err := m.store.Entries("")
+	if err != nil {
+		return fmt.Errorf("cannot read fees spent today: %w", err)
+	}
+	day := startOfDay(time.Now())
+	var spent float64
+	for _, e := range entries {
+		if e.Time.Before(day) {
+			continue
+		}
+		spent += e.Fee
+		if e.Kind == store.EntryFee {
+			spent += e.Value()
+		}
+	}
+	if spent >= m.limits.MaxDailyGas {
+		return fmt.Errorf("%.2f USD spent on fees today, at or above the daily limit of %.2f USD", spent, m.limits.MaxDailyGas)
+	}
+	return nil
+}
+
+func (m *Manager) checkReserve(ctx context.Context, a action.Action) error {
+	reserve := m.limits.MinReserve[a.Chain]
+	if reserve <= 0 || exits(a) {
+		return nil
+	}
+	native := Native[a.Chain]
+	s := m.portfolio.Latest()
+	if s.Time.IsZero() {
+		return fmt.Errorf("the %s balance of wallet %s is not known yet", native, a.Wallet)
+	}
+	var balance float64
+	for _, h := range s.Holdings {
+		if h.Wallet == a.Wallet && h.Chain == a.Chain && h.Account == "" && h.Asset == native {
+			balance += h.Amount
+		}
+	}
+	legs, err := a.Legs()
+	if err != nil {
+		return err
+	}
+	for _, leg := range legs {
+		if leg.Asset == native {
+			balance -= leg.Units()
+		}
+	}
+	if balance < reserve {
+		return fmt.Errorf("would leave %.6g %s in wallet %s on %s, below the %.6g %s kept for fees", balance, native, a.Wallet, a.Chain, reserve, native)
+	}
+	return nil
+}
+
+// legValues returns the value in USD of each leg of a, in the order of
+// a.Legs, and their total.
+func (m *Manager) legValues(ctx context.Context, a action.Action) ([]float64, float64, error) {
+	legs, err := a.Legs()
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make([]float64, len(legs))
+	var total float64
+	for i, leg := range legs {
+		if values[i], err = m.value(ctx, leg); err != nil {
+			return nil, 0, err
+		}
+		total += values[i]
+	}
+	return values, total, nil
+}
+
+// value returns the value of a's amount in USD.
+func (m *Manager) value(ctx context.Context, a action.Action) (float64, error) {
+	price, err := m.portfolio.Price(ctx, a.Chain, a.Asset)
+	if err != nil || price <= 0 {
+		return 0, fmt.Errorf("cannot value %s of %s on %s: no price (%v)", a.Kind, a.Asset, a.Chain, err)
+	}
+	return a.Units() * price, nil
+}
+
+// exposure returns the value in USD of every open position, summed by
+// "protocol/NAME", "chain/NAME" and "asset/NAME", with names in lower case.
+func (m *Manager) exposure(ctx context.Context) (map[string]float64, error) {
+	exposure := make(map[string]float64)
+	if m.store == nil {
+		return exposure, nil
+	}
+	positions, err := m.store.Positions("")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read open positions: %w", err)
+	}
+	for _, p := range positions {
+		amount, ok := new(big.Int).SetString(p.Amount, 10)
+		if !ok {
+			continue
+		}
+		// An LP position keeps its second token in its details.
+		legs, err := action.Action{Chain: p.Chain, Asset: p.Asset, Amount: amount, Decimals: p.Decimals, Params: p.Details}.Legs()
+		if err != nil {
+			return nil, fmt.Errorf("cannot value position %s of %s: %w", p.ID, p.Strategy, err)
+		}
+		for _, leg := range legs {
+			price, err := m.portfolio.Price(ctx, leg.Chain, leg.Asset)
+			if err != nil {
+				return nil, fmt.Errorf("cannot value position %s of %s: %w", p.ID, p.Strategy, err)
+			}
+			value := leg.Units() * price
+			exposure["protocol/"+strings.ToLower(p.Protocol)] += value
+			exposure["chain/"+strings.ToLower(string(p.Chain))] += value
+			exposure["asset/"+strings.ToLower(leg.Asset)] += value
+		}
+	}
+	return exposure, nil
+}
+
+// dailyLoss returns an error if the value of s is more than MaxDailyLoss
+// below the portfolio's value at the start of the day.
+func (m *Manager) dailyLoss(s portfolio.Snapshot) error {
+	if s.Time.IsZero() {
+		return nil
+	}
+	start := m.startValue(s)
+	loss := start - s.TotalValue()
+	if start > 0 && loss > m.limits.MaxDailyLoss {
+		return fmt.Errorf("the portfolio lost %.2f USD today, above the daily limit of %.2f USD", loss, m.limits.MaxDailyLoss)
+	}
+	return nil
+}
+
+// startValue returns the portfolio's value at the start of the day of s:
+// that of the first balance snapshot recorded that day, or else of s.
+func (m *Manager) startValue(s portfolio.Snapshot) float64 {
+	day := startOfDay(s.Time)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.day.Equal(day) && m.dayValue > 0 {
+		return m.dayValue
+	}
+
+	m.day, m.dayValue = day, s.TotalValue()
+	if m.store == nil {
+		return m.dayValue
+	}
+	balances, err := m.store.Balances(day)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read the portfolio's value at the start of the day")
+		return m.dayValue
+	}
+	var first float64
+	for _, b := range balances {
+		if !b.Time.Equal(balances[0].Time) {
+			break
+		}
+		first += b.Value
+	}
+	if first > 0 {
+		m.dayValue = first
+	}
+	return m.dayValue
+}
+
+// startOfDay returns midnight UTC of t's day.
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,181 @@
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the interval used for strategies that declare no schedule.
+const DefaultInterval = 5 * time.Minute
+
+// defaultKlineDelay gives the data provider time to publish a closed candle.
+const defaultKlineDelay = 5 * time.Second
+
+// Schedule decides when a strategy should run next.
+type Schedule interface {
+	// Next returns the next run time given the time of the last run (zero if
+	// the strategy has never run) and the current time. It returns false if
+	// the strategy should not run again.
+	Next(last, now time.Time) (time.Time, bool)
+	String() string
+}
+
+// Interval runs a strategy every d.
+func Interval(d time.Duration) Schedule {
+	return interval{every: d}
+}
+
+type interval struct {
+	every time.Duration
+}
+
+func (s interval) Next(last, now time.Time) (time.Time, bool) {
+	if last.IsZero() {
+		return now, true
+	}
+	next := last.Add(s.every)
+	if next.Before(now) {
+		return now, true
+	}
+	return next, true
+}
+
+func (s interval) String() string {
+	return "every " + s.every.String()
+}
+
+// Once runs a strategy a single time. Combined with a persistent Store, it
+// does not run again after a restart.
+func Once() Schedule {
+	return once{}
+}
+
+type once struct{}
+
+func (once) Next(last, now time.Time) (time.Time, bool) {
+	if last.IsZero() {
+		return now, true
+	}
+	return time.Time{}, false
+}
+
+func (once) String() string {
+	return "once"
+}
+
+// KlineClose runs a strategy shortly after each candle of the given interval
+// closes. Candles are aligned to the Unix epoch, as on most exchanges.
+func KlineClose(candle time.Duration) Schedule {
+	return klineClose{candle: candle, delay: defaultKlineDelay}
+}
+
+type klineClose struct {
+	candle time.Duration
+	delay  time.Duration
+}
+
+func (s klineClose) Next(last, now time.Time) (time.Time, bool) {
+	if last.IsZero() {
+		return now, true
+	}
+	// time.Time.Truncate rounds relative to year 1, so the candle that
+	// last ran in is found from the Unix time instead.
+	t := last.Add(-s.delay)
+	open := t.Add(-time.Duration(t.UnixNano() % int64(s.candle)))
+	next := open.Add(s.candle + s.delay)
+	if next.Before(now) {
+		// A candle closed while we were not running; catch up once.
+		return now, true
+	}
+	return next, true
+}
+
+func (s klineClose) String() string {
+	return "kline " + s.candle.String()
+}
+
+// WithJitter delays every run of s by a random duration in [0, max).
+func WithJitter(s Schedule, max time.Duration) Schedule {
+	if max <= 0 {
+		return s
+	}
+	return &jitter{Schedule: s, max: max}
+}
+
+type jitter struct {
+	Schedule
+	max time.Duration
+
+	// The delay is drawn once per last run, so that asking again, e.g.
+	// after the runner is woken, does not move the run.
+	mu    sync.Mutex
+	last  time.Time
+	delay time.Duration
+	drawn bool
+}
+
+func (s *jitter) Next(last, now time.Time) (time.Time, bool) {
+	next, ok := s.Schedule.Next(last, now)
+	if !ok {
+		return next, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.drawn || !s.last.Equal(last) {
+		s.last, s.delay, s.drawn = last, time.Duration(rand.Int63n(int64(s.max))), true
+	}
+	return next.Add(s.delay), true
+}
+
+func (s *jitter) String() string {
+	return fmt.Sprintf("%s (jitter %s)", s.Schedule, s.max)
+}
+
+// Parse parses a schedule specification. The supported forms are:
+//
+//	once
+//	every 5m       (or "@every 5m")
+//	kline 1h
+//	cron 0 * * * * (or "@hourly", "@daily", "@weekly", "@monthly")
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	kind, arg, _ := strings.Cut(spec, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch kind {
+	case "once":
+		return Once(), nil
+	case "every", "@every":
+		d, err := parsePositiveDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval schedule %q: %w", spec, err)
+		}
+		return Interval(d), nil
+	case "kline":
+		d, err := parsePositiveDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kline schedule %q: %w", spec, err)
+		}
+		return KlineClose(d), nil
+	case "cron":
+		return ParseCron(arg)
+	case "@hourly", "@daily", "@weekly", "@monthly":
+		return ParseCron(kind)
+	}
+
+	return nil, fmt.Errorf("unknown schedule %q", spec)
+}
+
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive")
+	}
+	return d, nil
+}
@@ -0,0 +1,27 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKlineCloseAlignsToUnixEpoch(t *testing.T) {
+	for _, tc := range []struct {
+		candle     time.Duration
+		last, want string
+	}{
+		{time.Hour, "2024-01-03T10:00:05Z", "2024-01-03T11:00:05Z"},
+		{time.Hour, "2024-01-03T10:59:00Z", "2024-01-03T11:00:05Z"},
+		{4 * time.Hour, "2024-01-03T09:30:00Z", "2024-01-03T12:00:05Z"},
+		// Weekly candles open on Thursdays, as 1970-01-01 was one.
+		{7 * 24 * time.Hour, "2024-01-04T00:00:05Z", "2024-01-11T00:00:05Z"},
+		{7 * 24 * time.Hour, "2024-01-08T12:00:00Z", "2024-01-11T00:00:05Z"},
+	} {
+		last, _ := time.Parse(time.RFC3339, tc.last)
+		want, _ := time.Parse(time.RFC3339, tc.want)
+		got, ok := KlineClose(tc.candle).Next(last, last)
+		if !ok || !got.Equal(want) {
+			t.Errorf("KlineClose(%s).Next(%s) = %s, %v, want %s", tc.candle, tc.last, got.Format(time.RFC3339), ok, tc.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store persists the time each strategy last ran, so that a restart does not
+// immediately re-run strategies that ran recently.
+type Store interface {
+	LastRun(name string) time.Time
+	SetLastRun(name string, t time.Time) error
+}
+
+// FileStore is a Store backed by a JSON file.
+type FileStore struct {
+	path string
+
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+// NewFileStore creates a FileStore at path, loading any existing records.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, runs: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.runs); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// LastRun returns the time name last ran, or the zero time if it never ran.
+func (s *FileStore) LastRun(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[name]
+}
+
+// SetLastRun records that name ran at t and writes the store to disk.
+func (s *FileStore) SetLastRun(name string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[name] = t
+
+	data, err := json.MarshalIndent(s.runs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash cannot leave a truncated store.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// MemoryStore is a Store that does not persist across restarts.
+type MemoryStore struct {
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+// NewMemoryStore creates a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]time.Time)}
+}
+
+// LastRun returns the time name last ran, or the zero time if it never ran.
+func (s *MemoryStore) LastRun(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[name]
+}
+
+// SetLastRun records that name ran at t.
+func (s *MemoryStore) SetLastRun(name string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[name] = t
+	return nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/allocator"
+	"github.com/sheawinkler/farmer-shea/executor"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/portfolio"
+	"github.com/sheawinkler/farmer-shea/risk"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/yield"
+)
+
+// ErrNoAllocation is returned by Allocation when capital is not allocated,
+// or not yet.
+var ErrNoAllocation = errors.New("no capital allocation")
+
+// Service exposes the portfolio, PnL, strategies, transactions, kill
+// switch, capital allocation and yield opportunities of a running bot. It
+// is safe for concurrent use.
+type Service struct {
+	store     *store.Store
+	executor  *executor.Executor
+	tracker   *portfolio.Tracker
+	risk      *risk.Manager
+	allocator *allocator.Allocator
+	yields    *yield.Engine
+	method    pnl.Method
+}
+
+// New creates a Service. PnL is computed with method from the ledger in st
+// and valued at the tracker's latest prices. Whenever rm's kill switch is
+// engaged, the executor is halted.
+func New(st *store.Store, exe *executor.Executor, tracker *portfolio.Tracker, rm *risk.Manager, method pnl.Method) *Service {
+	rm.OnHalt(func(h risk.HaltState) {
+		if cannot := exe.Halt(h.Unwind); h.Unwind && len(cannot) > 0 {
+			log.Warn().Strs("strategies", cannot).Msg("Some strategies cannot unwind their positions; close them by hand")
+		}
+	})
+	return &Service{store: st, executor: exe, tracker: tracker, risk: rm, method: method}
+}
+
+// Portfolio returns the latest portfolio snapshot. It is empty until the
+// first one is collected.
+func (s *Service) Portfolio() portfolio.Snapshot {
+	return s.tracker.Latest()
+}
+
+// PnL returns the PnL of every strategy, with open positions valued at the
+// prices of the latest portfolio snapshot.
+func (s *Service) PnL() (pnl.Report, error) {
+	entries, err := s.store.Entries("")
+	if err != nil {
+		return pnl.Report{}, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	return pnl.Compute(entries, s.method, s.tracker.Latest().Price), nil
+}
+
+// Strategies returns the status of every strategy.
+func (s *Service) Strategies() []executor.Status {
+	return s.executor.Statuses()
+}
+
+// Strategy returns the status of the named strategy.
+func (s *Service) Strategy(name string) (executor.Status, bool) {
+	for _, st := range s.executor.Statuses() {
+		if st.Strategy == name {
+			return st, true
+		}
+	}
+	return executor.Status{}, false
+}
+
+// Transactions returns the latest transactions of every strategy, newest
+// first. A limit of 0 returns them all.
+func (s *Service) Transactions(limit int) ([]store.Tx, error) {
+	txs, err := s.store.Txs(0, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+	return txs, nil
+}
+
+// Pause makes the named strategy skip its scheduled runs until resumed.
+func (s *Service) Pause(name string) error {
+	return s.executor.Pause(name)
+}
+
+// Resume undoes Pause.
+func (s *Service) Resume(name string) error {
+	return s.executor.Resume(name)
+}
+
+// Trigger runs the named strategy now.
+func (s *Service) Trigger(name string) error {
+	return s.executor.Trigger(name)
+}
+
+// Disable stops the named strategy until it is added back by a config
+// reload.
+func (s *Service) Disable(name string) error {
+	return s.executor.Disable(name)
+}
+
+// Halt engages the kill switch, halting every strategy, and unwinds their
+// positions if unwind is set.
+func (s *Service) Halt(reason string, unwind bool) error {
+	if reason == "" {
+		return fmt.Errorf("missing reason")
+	}
+	if s.risk.State().Halted {
+		return fmt.Errorf("already halted: %w", executor.ErrHalted)
+	}
+	s.risk.Halt(reason, unwind)
+	return nil
+}
+
+// Lift disengages the kill switch, so that strategies resume.
+func (s *Service) Lift() error {
+	if !s.risk.Lift() {
+		return fmt.Errorf("not halted")
+	}
+	s.executor.Lift()
+	return nil
+}
+
+// KillSwitch returns the state of the kill switch.
+func (s *Service) KillSwitch() risk.HaltState {
+	return s.risk.State()
+}
+
+// SetAllocator makes Allocation report a's plans. It must be called before
+// the service is used.
+func (s *Service) SetAllocator(a *allocator.Allocator) {
+	s.allocator = a
+}
+
+// Allocation returns the latest capital allocation and the moves it calls
+// for.
+func (s *Service) Allocation() (allocator.Plan, error) {
+	if s.allocator == nil {
+		return allocator.Plan{}, fmt.Errorf("%w: the allocator is disabled", ErrNoAllocation)
+	}
+	plan, ok := s.allocator.Last()
+	if !ok {
+		return allocator.Plan{}, fmt.Errorf("%w: not planned yet", ErrNoAllocation)
+	}
+	return plan, nil
+}
+
+// SetYields makes Yields compare the opportunities of e. It must be called
+// before the service is used.
+func (s *Service) SetYields(e *yield.Engine) {
+	s.yields = e
+}
+
+// Yields ranks the current yield opportunities by their APY net of costs.
+// Sources that fail are reported in the error, alongside the opportunities
+// of the rest.
+func (s *Service) Yields(ctx context.Context) ([]yield.Ranked, error) {
+	if s.yields == nil {
+		return nil, nil
+	}
+	return s.yields.Compare(ctx)
+}
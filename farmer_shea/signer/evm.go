@@ -0,0 +1,96 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+// EVM signs EVM transactions with a secp256k1 key.
+type EVM struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewEVM creates an EVM signer.
+func NewEVM(key *ecdsa.PrivateKey) *EVM {
+	return &EVM{key: key}
+}
+
+// Chain returns chain.Base.
+func (s *EVM) Chain() chain.ID {
+	return chain.Base
+}
+
+// Address returns the checksummed address.
+func (s *EVM) Address() string {
+	return s.CommonAddress().Hex()
+}
+
+// CommonAddress returns the address.
+func (s *EVM) CommonAddress() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+// Sign signs a 32-byte digest, returning a 65-byte [R || S || V] signature.
+func (s *EVM) Sign(digest []byte) ([]byte, error) {
+	return crypto.Sign(digest, s.key)
+}
+
+// TransactOpts returns transaction options that sign for chainID.
+func (s *EVM) TransactOpts(chainID *big.Int) (*bind.TransactOpts, error) {
+	return bind.NewKeyedTransactorWithChainID(s.key, chainID)
+}
+
+// Hyperliquid signs Hyperliquid exchange actions with EIP-712 typed data.
+// Hyperliquid accounts are EVM addresses, so the key is a secp256k1 key.
+type Hyperliquid struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewHyperliquid creates a Hyperliquid signer.
+func NewHyperliquid(key *ecdsa.PrivateKey) *Hyperliquid {
+	return &Hyperliquid{key: key}
+}
+
+// Chain returns chain.Hyperliquid.
+func (s *Hyperliquid) Chain() chain.ID {
+	return chain.Hyperliquid
+}
+
+// Address returns the checksummed address.
+func (s *Hyperliquid) Address() string {
+	return crypto.PubkeyToAddress(s.key.PublicKey).Hex()
+}
+
+// PrivateKey returns the underlying key, for exchange clients that sign
+// requests themselves.
+func (s *Hyperliquid) PrivateKey() *ecdsa.PrivateKey {
+	return s.key
+}
+
+// Sign signs a 32-byte EIP-712 digest.
+func (s *Hyperliquid) Sign(digest []byte) ([]byte, error) {
+	return crypto.Sign(digest, s.key)
+}
+
+// SignTypedData hashes data per EIP-712 and signs it. The returned signature
+// uses V = 27 or 28, as the Hyperliquid API expects.
+func (s *Hyperliquid) SignTypedData(data apitypes.TypedData) ([]byte, error) {
+	digest, _, err := apitypes.TypedDataAndHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash typed data: %w", err)
+	}
+
+	sig, err := crypto.Sign(digest, s.key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
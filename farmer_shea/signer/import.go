@@ -0,0 +1,122 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+// Export formats.
+const (
+	// FormatIDJSON is the Solana CLI id.json format, a JSON array of the
+	// 64 key bytes.
+	FormatIDJSON = "id.json"
+	// FormatBase58 is the base58 secret key used by Solana wallets.
+	FormatBase58 = "base58"
+	// FormatHex is a hex-encoded key, as used by EVM wallets. Sui keys are
+	// exported as their 32-byte seed.
+	FormatHex = "hex"
+)
+
+// legacyWallet is the plaintext wallet file older versions wrote.
+type legacyWallet struct {
+	PrivateKey solana.PrivateKey `json:"privateKey"`
+}
+
+// Import parses a plaintext key for id. It accepts a Solana CLI id.json, a
+// legacy wallet file or a base58 secret for Solana, and a hex key for any
+// chain.
+func Import(id chain.ID, data []byte) (Signer, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty key")
+	}
+
+	switch data[0] {
+	case '[':
+		var ints []int
+		if err := json.Unmarshal(data, &ints); err != nil {
+			return nil, fmt.Errorf("invalid id.json key: %w", err)
+		}
+		key := make([]byte, len(ints))
+		for i, b := range ints {
+			if b < 0 || b > 255 {
+				return nil, fmt.Errorf("invalid id.json key: byte %d out of range", b)
+			}
+			key[i] = byte(b)
+		}
+		return importSolana(id, key)
+	case '{':
+		if _, err := parseKeyFile(data); err == nil {
+			return nil, fmt.Errorf("key is already an encrypted keystore")
+		}
+		var w legacyWallet
+		if err := json.Unmarshal(data, &w); err != nil {
+			return nil, fmt.Errorf("invalid wallet file: %w", err)
+		}
+		return importSolana(id, w.PrivateKey)
+	}
+
+	if raw, err := hex.DecodeString(string(bytes.TrimPrefix(data, []byte("0x")))); err == nil {
+		return fromSecret(id, raw)
+	}
+	key, err := solana.PrivateKeyFromBase58(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("unrecognized key format")
+	}
+	return importSolana(id, key)
+}
+
+func importSolana(id chain.ID, key []byte) (Signer, error) {
+	if id != chain.Solana {
+		return nil, fmt.Errorf("a Solana key cannot be imported for %s", id)
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Solana private key length %d", len(key))
+	}
+	// The second half of a Solana key is its public key, which must match
+	// the one derived from the first.
+	public := ed25519.NewKeyFromSeed(key[:ed25519.SeedSize]).Public().(ed25519.PublicKey)
+	if !bytes.Equal(public, key[ed25519.SeedSize:]) {
+		return nil, fmt.Errorf("invalid Solana key: its public half does not match its private half")
+	}
+	return NewSolana(solana.PrivateKey(key))
+}
+
+// Export returns s's key in the given format. An empty format selects the
+// chain's usual format.
+func Export(s Signer, format string) ([]byte, error) {
+	secret, err := secretOf(s)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = FormatHex
+		if s.Chain() == chain.Solana {
+			format = FormatIDJSON
+		}
+	}
+
+	switch format {
+	case FormatIDJSON, FormatBase58:
+		if s.Chain() != chain.Solana {
+			return nil, fmt.Errorf("format %s is only supported for Solana keys", format)
+		}
+		if format == FormatBase58 {
+			return []byte(solana.PrivateKey(secret).String()), nil
+		}
+		ints := make([]int, len(secret))
+		for i, b := range secret {
+			ints[i] = int(b)
+		}
+		return json.Marshal(ints)
+	case FormatHex:
+		return []byte(hex.EncodeToString(secret)), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
@@ -0,0 +1,223 @@
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/google/uuid"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"golang.org/x/crypto/scrypt"
+)
+
+// gcmCipher is the cipher used for non-EVM keys. EVM keys use the v3
+// keystore's aes-128-ctr so that other Ethereum tooling can read them.
+const gcmCipher = "aes-256-gcm"
+
+const (
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+// ErrNotKeystore is returned when a key file is not an encrypted keystore.
+var ErrNotKeystore = errors.New("not an encrypted keystore")
+
+// ScryptN and ScryptP are the scrypt parameters used for new keystores.
+var (
+	ScryptN = keystore.StandardScryptN
+	ScryptP = keystore.StandardScryptP
+)
+
+// keyFile is the on-disk keystore format. It is the Ethereum v3 keystore
+// format with an added chain field, which Ethereum tooling ignores.
+type keyFile struct {
+	Version int                 `json:"version"`
+	ID      string              `json:"id"`
+	Chain   chain.ID            `json:"chain"`
+	Address string              `json:"address"`
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+}
+
+// Encrypt returns s's key as an encrypted keystore.
+func Encrypt(s Signer, passphrase []byte) ([]byte, error) {
+	secret, err := secretOf(s)
+	if err != nil {
+		return nil, err
+	}
+
+	kf := keyFile{
+		Version: 3,
+		ID:      uuid.NewString(),
+		Chain:   s.Chain(),
+		Address: s.Address(),
+	}
+	switch s.(type) {
+	case *EVM, *Hyperliquid:
+		kf.Address = strings.ToLower(strings.TrimPrefix(kf.Address, "0x"))
+		kf.Crypto, err = keystore.EncryptDataV3(secret, passphrase, ScryptN, ScryptP)
+	default:
+		kf.Crypto, err = encryptGCM(secret, passphrase, []byte(s.Chain()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s key: %w", s.Chain(), err)
+	}
+	return json.MarshalIndent(kf, "", "  ")
+}
+
+// Decrypt decrypts a keystore holding a key for id. Ethereum v3 keystores
+// without a chain field, e.g. from geth, are loaded as id keys, so id must
+// be given for them.
+func Decrypt(id chain.ID, data, passphrase []byte) (Signer, error) {
+	kf, err := parseKeyFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Chain == "" {
+		if id == "" {
+			return nil, fmt.Errorf("keystore does not say which chain its key is for")
+		}
+		kf.Chain = id
+	}
+
+	var secret []byte
+	switch kf.Crypto.Cipher {
+	case "aes-128-ctr":
+		secret, err = keystore.DecryptDataV3(kf.Crypto, string(passphrase))
+	case gcmCipher:
+		secret, err = decryptGCM(kf.Crypto, passphrase, []byte(kf.Chain))
+	default:
+		return nil, fmt.Errorf("unsupported keystore cipher %q", kf.Crypto.Cipher)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s key: %w", kf.Chain, err)
+	}
+	return fromSecret(kf.Chain, secret)
+}
+
+func parseKeyFile(data []byte) (keyFile, error) {
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil || kf.Version != 3 || kf.Crypto.Cipher == "" {
+		return keyFile{}, ErrNotKeystore
+	}
+	return kf, nil
+}
+
+// Save encrypts s's key and writes it to path. It never overwrites an
+// existing file.
+func Save(s Signer, path string, passphrase []byte) error {
+	data, err := Encrypt(s, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func encryptGCM(secret, passphrase, additionalData []byte) (keystore.CryptoJSON, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return keystore.CryptoJSON{}, err
+	}
+	key, err := scrypt.Key(passphrase, salt, ScryptN, scryptR, ScryptP, scryptDKLen)
+	if err != nil {
+		return keystore.CryptoJSON{}, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return keystore.CryptoJSON{}, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return keystore.CryptoJSON{}, err
+	}
+
+	cj := keystore.CryptoJSON{
+		Cipher:     gcmCipher,
+		CipherText: hex.EncodeToString(gcm.Seal(nil, nonce, secret, additionalData)),
+		KDF:        "scrypt",
+		KDFParams: map[string]interface{}{
+			"n":     ScryptN,
+			"r":     scryptR,
+			"p":     ScryptP,
+			"dklen": scryptDKLen,
+			"salt":  hex.EncodeToString(salt),
+		},
+	}
+	cj.CipherParams.IV = hex.EncodeToString(nonce)
+	return cj, nil
+}
+
+func decryptGCM(cj keystore.CryptoJSON, passphrase, additionalData []byte) ([]byte, error) {
+	if cj.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported KDF %q", cj.KDF)
+	}
+	salt, err := hex.DecodeString(fmt.Sprint(cj.KDFParams["salt"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	n, r, p, dkLen := kdfInt(cj, "n"), kdfInt(cj, "r"), kdfInt(cj, "p"), kdfInt(cj, "dklen")
+	key, err := scrypt.Key(passphrase, salt, n, r, p, dkLen)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hex.DecodeString(cj.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(cj.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(nonce))
+	}
+	secret, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, keystore.ErrDecrypt
+	}
+	return secret, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// kdfInt reads an integer KDF parameter. JSON numbers decode as float64.
+func kdfInt(cj keystore.CryptoJSON, name string) int {
+	switch v := cj.KDFParams[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
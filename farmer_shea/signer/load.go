@@ -0,0 +1,130 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+// Load decrypts the keystore at path and returns the signer for id.
+func Load(id chain.ID, path string, passphrase []byte) (Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Decrypt(id, data, passphrase)
+	if errors.Is(err, ErrNotKeystore) {
+		return nil, fmt.Errorf("%s is %w; import it with -import-key %s=%s", path, err, id, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.Chain() != id {
+		return nil, fmt.Errorf("%s holds a %s key, not a %s key", path, s.Chain(), id)
+	}
+	return s, nil
+}
+
+// LoadKeyring loads a Keyring from a map of chain to keystore path. All
+// keystores are unlocked with the same passphrase.
+func LoadKeyring(paths map[string]string, passphrase []byte) (*Keyring, error) {
+	k, err := NewKeyring()
+	if err != nil {
+		return nil, err
+	}
+	for name, path := range paths {
+		id, err := ParseChain(name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := Load(id, path, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s key: %w", id, err)
+		}
+		if err := k.Add(s); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParseChain parses a chain name from the key config.
+func ParseChain(name string) (chain.ID, error) {
+	id := chain.ID(strings.ToLower(name))
+	if !id.Valid() {
+		return "", fmt.Errorf("unknown chain %q in key config", name)
+	}
+	return id, nil
+}
+
+// Generate creates a signer for id with a new random key.
+func Generate(id chain.ID) (Signer, error) {
+	switch id {
+	case chain.Solana:
+		key, err := solana.NewRandomPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewSolana(key)
+	case chain.Base, chain.Hyperliquid:
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		return fromSecret(id, crypto.FromECDSA(key))
+	case chain.Sui:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSui(key)
+	}
+	return nil, fmt.Errorf("unsupported chain %q", id)
+}
+
+// fromSecret creates a signer for id from raw key material: a 64-byte
+// ed25519 key for Solana, a 32-byte secp256k1 key for EVM chains and a
+// 32-byte ed25519 seed for Sui.
+func fromSecret(id chain.ID, secret []byte) (Signer, error) {
+	switch id {
+	case chain.Solana:
+		return NewSolana(solana.PrivateKey(secret))
+	case chain.Base, chain.Hyperliquid:
+		key, err := crypto.ToECDSA(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s key: %w", id, err)
+		}
+		if id == chain.Base {
+			return NewEVM(key), nil
+		}
+		return NewHyperliquid(key), nil
+	case chain.Sui:
+		if len(secret) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid Sui key: expected a %d-byte seed", ed25519.SeedSize)
+		}
+		return NewSui(ed25519.NewKeyFromSeed(secret))
+	}
+	return nil, fmt.Errorf("unsupported chain %q", id)
+}
+
+// secretOf returns the raw key material of s in the form fromSecret accepts.
+func secretOf(s Signer) ([]byte, error) {
+	switch s := s.(type) {
+	case *Solana:
+		return []byte(s.key), nil
+	case *EVM:
+		return crypto.FromECDSA(s.key), nil
+	case *Hyperliquid:
+		return crypto.FromECDSA(s.key), nil
+	case *Sui:
+		return s.key.Seed(), nil
+	}
+	return nil, fmt.Errorf("cannot export keys of signer type %T", s)
+}
@@ -0,0 +1,64 @@
+package signer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// PassphraseEnv is the environment variable ReadPassphrase checks.
+const PassphraseEnv = "FARMER_SHEA_PASSPHRASE"
+
+// ReadPassphrase returns the keystore passphrase. It is read from file if
+// one is given, then from PassphraseEnv, and otherwise prompted for on the
+// terminal. confirm asks for the passphrase twice when prompting, for
+// keystores that are about to be created. An empty passphrase is rejected
+// whatever its source.
+func ReadPassphrase(file string, confirm bool) ([]byte, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		if p := bytes.TrimRight(data, "\r\n"); len(p) > 0 {
+			return p, nil
+		}
+		return nil, fmt.Errorf("empty passphrase in %s", file)
+	}
+	if p, ok := os.LookupEnv(PassphraseEnv); ok {
+		if len(p) == 0 {
+			return nil, fmt.Errorf("empty passphrase in %s", PassphraseEnv)
+		}
+		return []byte(p), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no keystore passphrase: set %s or passphrase_file, or run from a terminal", PassphraseEnv)
+	}
+	p, err := prompt(fd, "Keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		again, err := prompt(fd, "Repeat passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(p, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return p, nil
+}
+
+func prompt(fd int, msg string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, msg)
+	defer fmt.Fprintln(os.Stderr)
+	return term.ReadPassword(fd)
+}
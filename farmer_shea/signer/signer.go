@@ -0,0 +1,97 @@
+package signer
+
+import (
+	"fmt"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+)
+
+// Signer holds the key for a single chain and signs on its behalf.
+//
+// The payload accepted by Sign is chain-specific: Solana and Sui sign the
+// serialized transaction message, while EVM chains sign a 32-byte digest.
+// Implementations also expose typed helpers for their chain's transactions.
+type Signer interface {
+	Chain() chain.ID
+	Address() string
+	Sign(payload []byte) ([]byte, error)
+}
+
+// Keyring holds one Signer per chain.
+type Keyring struct {
+	signers map[chain.ID]Signer
+}
+
+// NewKeyring creates a Keyring holding the given signers.
+func NewKeyring(signers ...Signer) (*Keyring, error) {
+	k := &Keyring{signers: make(map[chain.ID]Signer)}
+	for _, s := range signers {
+		if err := k.Add(s); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Add adds s to the keyring. Each chain may only have one signer.
+func (k *Keyring) Add(s Signer) error {
+	if _, ok := k.signers[s.Chain()]; ok {
+		return fmt.Errorf("keyring already has a signer for %s", s.Chain())
+	}
+	k.signers[s.Chain()] = s
+	return nil
+}
+
+// Signer returns the signer for id.
+func (k *Keyring) Signer(id chain.ID) (Signer, error) {
+	s, ok := k.signers[id]
+	if !ok {
+		return nil, errkind.Wrapf(errkind.Permanent, "no signer configured for %s", id)
+	}
+	return s, nil
+}
+
+// Chains returns the chains the keyring can sign for.
+func (k *Keyring) Chains() []chain.ID {
+	var ids []chain.ID
+	for _, id := range chain.All {
+		if _, ok := k.signers[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Solana returns the Solana signer.
+func (k *Keyring) Solana() (*Solana, error) {
+	return typed[*Solana](k, chain.Solana)
+}
+
+// EVM returns the signer for Base.
+func (k *Keyring) EVM() (*EVM, error) {
+	return typed[*EVM](k, chain.Base)
+}
+
+// Hyperliquid returns the Hyperliquid signer.
+func (k *Keyring) Hyperliquid() (*Hyperliquid, error) {
+	return typed[*Hyperliquid](k, chain.Hyperliquid)
+}
+
+// Sui returns the Sui signer.
+func (k *Keyring) Sui() (*Sui, error) {
+	return typed[*Sui](k, chain.Sui)
+}
+
+func typed[T Signer](k *Keyring, id chain.ID) (T, error) {
+	var zero T
+	s, err := k.Signer(id)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := s.(T)
+	if !ok {
+		return zero, errkind.Wrapf(errkind.Permanent, "signer for %s has unexpected type %T", id, s)
+	}
+	return t, nil
+}
@@ -0,0 +1,56 @@
+package signer
+
+import (
+	"fmt"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+// Solana signs Solana transactions with an ed25519 key.
+type Solana struct {
+	key solana.PrivateKey
+}
+
+// NewSolana creates a Solana signer from a 64-byte ed25519 private key.
+func NewSolana(key solana.PrivateKey) (*Solana, error) {
+	if len(key) != 64 {
+		return nil, fmt.Errorf("invalid Solana private key length %d", len(key))
+	}
+	return &Solana{key: key}, nil
+}
+
+// Chain returns chain.Solana.
+func (s *Solana) Chain() chain.ID {
+	return chain.Solana
+}
+
+// Address returns the base58 public key.
+func (s *Solana) Address() string {
+	return s.PublicKey().String()
+}
+
+// PublicKey returns the public key.
+func (s *Solana) PublicKey() solana.PublicKey {
+	return s.key.PublicKey()
+}
+
+// Sign signs a serialized transaction message.
+func (s *Solana) Sign(payload []byte) ([]byte, error) {
+	sig, err := s.key.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	return sig[:], nil
+}
+
+// SignTransaction adds the signer's signature to tx.
+func (s *Solana) SignTransaction(tx *solana.Transaction) error {
+	_, err := tx.Sign(func(key solana.PublicKey) *solana.PrivateKey {
+		if key.Equals(s.PublicKey()) {
+			return &s.key
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,56 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"golang.org/x/crypto/blake2b"
+)
+
+// suiEd25519Flag is the signature scheme flag Sui uses for ed25519 keys.
+const suiEd25519Flag = 0x00
+
+// suiTxIntent is the intent prefix for transaction data: scope
+// TransactionData, version V0, app id Sui.
+var suiTxIntent = []byte{0, 0, 0}
+
+// Sui signs Sui transactions with an ed25519 key.
+type Sui struct {
+	key ed25519.PrivateKey
+}
+
+// NewSui creates a Sui signer from an ed25519 private key.
+func NewSui(key ed25519.PrivateKey) (*Sui, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid Sui private key length %d", len(key))
+	}
+	return &Sui{key: key}, nil
+}
+
+// Chain returns chain.Sui.
+func (s *Sui) Chain() chain.ID {
+	return chain.Sui
+}
+
+// Address returns the 0x-prefixed Sui address, the BLAKE2b-256 hash of the
+// scheme flag and public key.
+func (s *Sui) Address() string {
+	pub := s.key.Public().(ed25519.PublicKey)
+	sum := blake2b.Sum256(append([]byte{suiEd25519Flag}, pub...))
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+// Sign signs BCS-serialized transaction data. It returns the serialized Sui
+// signature: the scheme flag, the ed25519 signature and the public key.
+func (s *Sui) Sign(txBytes []byte) ([]byte, error) {
+	digest := blake2b.Sum256(append(append([]byte{}, suiTxIntent...), txBytes...))
+	sig := ed25519.Sign(s.key, digest[:])
+
+	out := make([]byte, 0, 1+len(sig)+ed25519.PublicKeySize)
+	out = append(out, suiEd25519Flag)
+	out = append(out, sig...)
+	out = append(out, s.key.Public().(ed25519.PublicKey)...)
+	return out, nil
+}
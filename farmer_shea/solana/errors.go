@@ -0,0 +1,56 @@
+package solana
+
+import (
+	"errors"
+
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/sheawinkler/farmer-shea/errkind"
+)
+
+// Solana JSON-RPC server error codes.
+// See https://github.com/anza-xyz/agave/blob/master/rpc-client-api/src/custom_error.rs
+const (
+	codeBlockCleanedUp             = -32001
+	codePreflightFailure           = -32002
+	codeSignatureVerification      = -32003
+	codeBlockNotAvailable          = -32004
+	codeNodeUnhealthy              = -32005
+	codeSlotSkipped                = -32007
+	codeLongTermStorageSlotSkipped = -32009
+	codeBlockStatusNotAvailable    = -32014
+	codeUnsupportedTxVersion       = -32015
+	codeMinContextSlotNotReached   = -32016
+	codeRateLimited                = 429
+)
+
+// classify annotates errors returned by the RPC node with an errkind.Kind.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case codePreflightFailure:
+			return errkind.Wrap(errkind.Revert, err)
+		case codeSignatureVerification, codeUnsupportedTxVersion,
+			-32600, -32601, -32602: // invalid request, method not found, invalid params
+			return errkind.Wrap(errkind.Permanent, err)
+		case codeRateLimited:
+			return errkind.RateLimit(err, 0)
+		case codeBlockCleanedUp, codeBlockNotAvailable, codeNodeUnhealthy, codeSlotSkipped,
+			codeLongTermStorageSlotSkipped, codeBlockStatusNotAvailable, codeMinContextSlotNotReached,
+			-32603: // internal error
+			return errkind.Wrap(errkind.Transient, err)
+		}
+		return err
+	}
+
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return errkind.FromHTTPStatus(httpErr.Code, "", err)
+	}
+
+	return errkind.Annotate(err)
+}
@@ -2,11 +2,23 @@ package solana
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/programs/associated-token-account"
 	"github.com/gagliardetto/solana-go/rpc"
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/dryrun"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/metrics"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/store"
 )
 
 // Client is a Solana client.
@@ -14,57 +26,250 @@ type Client struct {
 	*rpc.Client
 }
 
-// NewClient creates a new Solana client.
+// NewClient creates a new Solana client. Its requests are recorded in the
+// RPC metrics.
 func NewClient(rpcEndpoint string) (*Client, error) {
-	client := rpc.New(rpcEndpoint)
+	client := rpc.NewWithCustomRPCClient(jsonrpc.NewClientWithOpts(rpcEndpoint, &jsonrpc.RPCClientOpts{
+		HTTPClient: metrics.HTTPClient(chain.Solana),
+	}))
 	return &Client{client}, nil
 }
 
 // GetLatestBlockHeight gets the latest block height of the Solana blockchain.
 func (c *Client) GetLatestBlockHeight() (uint64, error) {
-	return c.GetSlot(context.Background(), rpc.CommitmentFinalized)
+	slot, err := c.GetSlot(context.Background(), rpc.CommitmentFinalized)
+	return slot, classify(err)
 }
 
-// GetOrCreateAssociatedTokenAccount gets or creates an associated token account for the given wallet and mint.
-func (c *Client) GetOrCreateAssociatedTokenAccount(w wallet.Wallet, mint solana.PublicKey) (solana.PublicKey, error) {
-	ata, _, err := solana.FindAssociatedTokenAddress(w.PublicKey(), mint)
+// GetLatestBlockhash gets the latest blockhash at the given commitment.
+func (c *Client) GetLatestBlockhash(ctx context.Context, commitment rpc.CommitmentType) (*rpc.GetLatestBlockhashResult, error) {
+	out, err := c.Client.GetLatestBlockhash(ctx, commitment)
+	return out, classify(err)
+}
+
+// SendTransaction submits a signed transaction.
+func (c *Client) SendTransaction(ctx context.Context, tx *solana.Transaction) (solana.Signature, error) {
+	sig, err := c.Client.SendTransaction(ctx, tx)
+	return sig, classify(err)
+}
+
+// GetOrCreateAssociatedTokenAccount gets or creates an associated token account for the given signer and mint.
+func (c *Client) GetOrCreateAssociatedTokenAccount(ctx context.Context, s *signer.Solana, mint solana.PublicKey) (solana.PublicKey, error) {
+	ata, _, err := solana.FindAssociatedTokenAddress(s.PublicKey(), mint)
 	if err != nil {
 		return solana.PublicKey{}, err
 	}
 
-	_, err = c.GetAccountInfo(context.Background(), ata)
+	_, err = c.GetAccountInfo(ctx, ata)
 	if err == nil {
 		return ata, nil // Account already exists
 	}
 
-	ix, err := associated_token_account.NewCreateInstruction(w.PublicKey(), w.PublicKey(), mint).Validate()
+	ix, err := associated_token_account.NewCreateInstruction(s.PublicKey(), s.PublicKey(), mint).Validate()
 	if err != nil {
 		return solana.PublicKey{}, err
 	}
 
-	blockhash, err := c.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
+	blockhash, err := c.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
 	if err != nil {
 		return solana.PublicKey{}, err
 	}
 
-	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, w.PublicKey())
+	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, s.PublicKey())
 	if err != nil {
 		return solana.PublicKey{}, err
 	}
 
-	if err := w.SignTransaction(tx); err != nil {
+	if err := s.SignTransaction(tx); err != nil {
 		return solana.PublicKey{}, err
 	}
 
-	sig, err := c.SendTransaction(context.Background(), tx)
+	_, err = c.SendAndConfirm(ctx, tx, fmt.Sprintf("create associated token account %s for mint %s", ata, mint))
+	return ata, err
+}
+
+// SendAndConfirm submits a signed transaction and waits for it to be
+// finalized. The transaction is recorded to the run's store, if any. In a dry run, the transaction is simulated and recorded under
+// action instead, and a zero signature is returned. If the outcome of a
+// sent transaction cannot be determined, the error is
+// errkind.Unconfirmed and the transaction must not be sent again.
+func (c *Client) SendAndConfirm(ctx context.Context, tx *solana.Transaction, action string) (solana.Signature, error) {
+	if rec, ok := dryrun.FromContext(ctx); ok {
+		rec.Record(c.simulate(ctx, tx, action))
+		return solana.Signature{}, nil
+	}
+
+	sig, err := c.SendTransaction(ctx, tx)
 	if err != nil {
-		return solana.PublicKey{}, err
+		return sig, err
+	}
+
+	id := store.RecordTx(ctx, chain.Solana, sig.String(), action)
+	events.Publish(ctx, &events.TxSubmitted{Chain: chain.Solana, Hash: sig.String(), Action: action})
+	err = c.confirm(ctx, sig, tx.Message.RecentBlockhash)
+	// The fee is not known without fetching the transaction.
+	store.SettleTx(ctx, id, "", "", err)
+	events.Publish(ctx, &events.TxConfirmed{Chain: chain.Solana, Hash: sig.String(), Err: err})
+	return sig, err
+}
+
+// confirmPollInterval is how often confirm asks for a transaction's status.
+const confirmPollInterval = 2 * time.Second
+
+// confirm waits for the sent transaction sig to be finalized. Once the
+// transaction has reached the node, it is only safe to send again if it
+// can no longer land, so confirm polls until it is finalized or its
+// blockhash has expired. Any other way of giving up returns an
+// errkind.Unconfirmed error.
+func (c *Client) confirm(ctx context.Context, sig solana.Signature, blockhash solana.Hash) error {
+	ticker := time.NewTicker(confirmPollInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+			return errkind.Wrap(errkind.Unconfirmed, fmt.Errorf("transaction %s sent but not confirmed: %w", sig, lastErr))
+		case <-ticker.C:
+		}
+
+		landed, err := c.signatureStatus(ctx, sig, false)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if landed != nil {
+			if landed.Err != nil {
+				return errkind.Wrapf(errkind.Revert, "transaction %s failed: %v", sig, landed.Err)
+			}
+			if landed.ConfirmationStatus == rpc.ConfirmationStatusFinalized {
+				return nil
+			}
+			continue
+		}
+
+		valid, err := c.IsBlockhashValid(ctx, blockhash, rpc.CommitmentFinalized)
+		if err != nil {
+			lastErr = classify(err)
+			continue
+		}
+		if valid.Value {
+			continue
+		}
+		// The transaction can no longer land; look once more in case it
+		// did so just before its blockhash expired.
+		landed, err = c.signatureStatus(ctx, sig, true)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if landed == nil {
+			return errkind.Wrapf(errkind.Transient, "transaction %s expired before it landed", sig)
+		}
 	}
+}
 
-	return ata, c.ConfirmTransaction(context.Background(), sig, rpc.CommitmentFinalized)
+// signatureStatus returns the status of sig, or nil if the node has not
+// seen it.
+func (c *Client) signatureStatus(ctx context.Context, sig solana.Signature, searchHistory bool) (*rpc.SignatureStatusesResult, error) {
+	out, err := c.GetSignatureStatuses(ctx, searchHistory, sig)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, classify(err)
+	}
+	if len(out.Value) == 0 {
+		return nil, nil
+	}
+	return out.Value[0], nil
 }
 
-// GetProgramAccounts gets all accounts owned by a program.
-func (c *Client) GetProgramAccounts(programID string) ([]*rpc.GetProgramAccountsResult, error) {
-	return c.GetProgramAccounts(context.Background(), solana.MustPublicKeyFromBase58(programID), nil)
+func (c *Client) simulate(ctx context.Context, tx *solana.Transaction, action string) dryrun.Step {
+	step := dryrun.Step{Chain: "solana", Action: action}
+	for _, key := range tx.Message.AccountKeys {
+		step.Accounts = append(step.Accounts, key.String())
+	}
+
+	out, err := c.SimulateTransaction(ctx, tx)
+	if err != nil {
+		step.Err = classify(err)
+		return step
+	}
+
+	res := out.Value
+	if res.UnitsConsumed != nil {
+		step.Result = fmt.Sprintf("%d compute units", *res.UnitsConsumed)
+	}
+	if res.Err != nil {
+		step.Err = errkind.Wrapf(errkind.Revert, "simulation failed: %v; logs: %v", res.Err, res.Logs)
+	}
+	return step
+}
+
+// GetProgramAccounts gets the accounts owned by a program that match every
+// filter.
+func (c *Client) GetProgramAccounts(ctx context.Context, programID string, filters ...rpc.RPCFilter) (rpc.GetProgramAccountsResult, error) {
+	accounts, err := c.Client.GetProgramAccountsWithOpts(ctx, solana.MustPublicKeyFromBase58(programID),
+		&rpc.GetProgramAccountsOpts{Encoding: solana.EncodingBase64, Filters: filters})
+	return accounts, classify(err)
+}
+
+// TokenBalance is the balance of one SPL token account.
+type TokenBalance struct {
+	Account  solana.PublicKey
+	Mint     solana.PublicKey
+	Amount   *big.Int
+	Decimals uint8
+}
+
+// GetSOLBalance returns the SOL balance of owner in lamports.
+func (c *Client) GetSOLBalance(ctx context.Context, owner solana.PublicKey) (uint64, error) {
+	out, err := c.GetBalance(ctx, owner, rpc.CommitmentConfirmed)
+	if err != nil {
+		return 0, classify(err)
+	}
+	return out.Value, nil
+}
+
+// GetTokenBalances returns the SPL token accounts of owner with a non-zero
+// balance.
+func (c *Client) GetTokenBalances(ctx context.Context, owner solana.PublicKey) ([]TokenBalance, error) {
+	out, err := c.GetTokenAccountsByOwner(ctx, owner,
+		&rpc.GetTokenAccountsConfig{ProgramId: &solana.TokenProgramID},
+		&rpc.GetTokenAccountsOpts{Commitment: rpc.CommitmentConfirmed, Encoding: solana.EncodingJSONParsed})
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	var balances []TokenBalance
+	for _, acc := range out.Value {
+		var parsed struct {
+			Parsed struct {
+				Info struct {
+					Mint        solana.PublicKey `json:"mint"`
+					TokenAmount struct {
+						Amount   string `json:"amount"`
+						Decimals uint8  `json:"decimals"`
+					} `json:"tokenAmount"`
+				} `json:"info"`
+			} `json:"parsed"`
+		}
+		if err := json.Unmarshal(acc.Account.Data.GetRawJSON(), &parsed); err != nil {
+			return nil, fmt.Errorf("failed to decode token account %s: %w", acc.Pubkey, err)
+		}
+		info := parsed.Parsed.Info
+		amount, ok := new(big.Int).SetString(info.TokenAmount.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("token account %s has malformed amount %q", acc.Pubkey, info.TokenAmount.Amount)
+		}
+		if amount.Sign() == 0 {
+			continue
+		}
+		balances = append(balances, TokenBalance{Account: acc.Pubkey, Mint: info.Mint, Amount: amount, Decimals: info.TokenAmount.Decimals})
+	}
+	return balances, nil
 }
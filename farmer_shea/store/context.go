@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+type scopeKey struct{}
+
+// scope ties the records written under a context to a strategy run.
+type scope struct {
+	store *Store
+	run   Run
+}
+
+// WithRun returns a copy of ctx in which chain clients and strategies record
+// their transactions and positions to s under run. In a dry run, records are
+// read but not written.
+func WithRun(ctx context.Context, s *Store, run Run) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{store: s, run: run})
+}
+
+func fromContext(ctx context.Context) (*scope, bool) {
+	sc, ok := ctx.Value(scopeKey{}).(*scope)
+	return sc, ok
+}
+
+// writable returns the scope of ctx if records may be written under it.
+func writable(ctx context.Context) (*scope, bool) {
+	sc, ok := fromContext(ctx)
+	if !ok || sc.run.DryRun {
+		return nil, false
+	}
+	return sc, true
+}
+
+// Recording reports whether records are written under ctx, i.e. whether it
+// carries a run that is not a dry run.
+func Recording(ctx context.Context) bool {
+	_, ok := writable(ctx)
+	return ok
+}
+
+// Attached reports whether ctx carries a run, so that Positions reads the
+// run's recorded positions. Unlike Recording, it holds in a dry run.
+func Attached(ctx context.Context) bool {
+	_, ok := fromContext(ctx)
+	return ok
+}
+
+// RecordTx records a transaction submitted under ctx as pending and returns
+// its ID, or 0 if ctx carries no run. Failures are logged rather than
+// returned, since the transaction has already been sent.
+func RecordTx(ctx context.Context, c chain.ID, hash, action string) uint64 {
+	sc, ok := writable(ctx)
+	if !ok {
+		return 0
+	}
+	id, err := sc.store.AddTx(Tx{
+		RunID:    sc.run.ID,
+		Strategy: sc.run.Strategy,
+		Wallet:   sc.run.Wallet,
+		Chain:    c,
+		Hash:     hash,
+		Action:   action,
+		Status:   TxPending,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("chain", string(c)).Str("hash", hash).Msg("Failed to record transaction")
+	}
+	return id
+}
+
+// SettleTx records the outcome of a transaction recorded with RecordTx. fee
+// is in the smallest unit of feeAsset and may be empty if unknown. It is a
+// no-op for an ID of 0.
+func SettleTx(ctx context.Context, id uint64, fee, feeAsset string, txErr error) {
+	sc, ok := writable(ctx)
+	if !ok || id == 0 {
+		return
+	}
+	err := sc.store.UpdateTx(id, func(t *Tx) {
+		t.Status = TxConfirmed
+		if txErr != nil {
+			t.Status = TxFailed
+			t.Error = txErr.Error()
+		}
+		t.Fee = fee
+		t.FeeAsset = feeAsset
+	})
+	if err != nil {
+		log.Error().Err(err).Uint64("tx", id).Msg("Failed to record transaction outcome")
+	}
+}
+
+// SavePosition creates or updates a position of the strategy running under
+// ctx. Failures are logged rather than returned, since the funds have
+// already moved.
+func SavePosition(ctx context.Context, p Position) {
+	sc, ok := writable(ctx)
+	if !ok {
+		return
+	}
+	p.Strategy = sc.run.Strategy
+	p.Wallet = sc.run.Wallet
+	if err := sc.store.PutPosition(p); err != nil {
+		log.Error().Err(err).Str("strategy", p.Strategy).Str("position", p.ID).Msg("Failed to record position")
+	}
+}
+
+// ClosePosition removes a position of the strategy running under ctx.
+func ClosePosition(ctx context.Context, id string) {
+	sc, ok := writable(ctx)
+	if !ok {
+		return
+	}
+	if err := sc.store.DeletePosition(sc.run.Strategy, id); err != nil {
+		log.Error().Err(err).Str("strategy", sc.run.Strategy).Str("position", id).Msg("Failed to remove position")
+	}
+}
+
+// RecordEntry records a ledger entry of the strategy running under ctx.
+// Failures are logged rather than returned, since the funds have already
+// moved.
+func RecordEntry(ctx context.Context, e Entry) {
+	sc, ok := writable(ctx)
+	if !ok {
+		return
+	}
+	e.RunID = sc.run.ID
+	e.Strategy = sc.run.Strategy
+	e.Wallet = sc.run.Wallet
+	if _, err := sc.store.AddEntry(e); err != nil {
+		log.Error().Err(err).Str("strategy", e.Strategy).Str("kind", string(e.Kind)).Str("asset", e.Asset).Msg("Failed to record ledger entry")
+	}
+}
+
+// Positions returns the open positions of the strategy running under ctx.
+// It returns none if ctx carries no run.
+func Positions(ctx context.Context) ([]Position, error) {
+	sc, ok := fromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	return sc.store.Positions(sc.run.Strategy)
+}
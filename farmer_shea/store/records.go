@@ -0,0 +1,400 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	bolt "go.etcd.io/bbolt"
+)
+
+// RunStatus is the outcome of a strategy run.
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunCancelled RunStatus = "cancelled"
+)
+
+// Run records one execution of a strategy.
+type Run struct {
+	ID       uint64    `json:"id"`
+	Strategy string    `json:"strategy"`
+	Wallet   string    `json:"wallet"`
+	DryRun   bool      `json:"dry_run"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+	Status   RunStatus `json:"status"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// TxStatus is the state of a submitted transaction.
+type TxStatus string
+
+const (
+	TxPending   TxStatus = "pending"
+	TxConfirmed TxStatus = "confirmed"
+	TxFailed    TxStatus = "failed"
+)
+
+// Tx records a transaction submitted on behalf of a strategy run.
+type Tx struct {
+	ID       uint64   `json:"id"`
+	RunID    uint64   `json:"run_id"`
+	Strategy string   `json:"strategy"`
+	Wallet   string   `json:"wallet"`
+	Chain    chain.ID `json:"chain"`
+	// Hash is the transaction hash or signature.
+	Hash string `json:"hash"`
+	// Action describes what the transaction does.
+	Action string   `json:"action"`
+	Status TxStatus `json:"status"`
+	// Fee is in the smallest unit of FeeAsset, e.g. wei or lamports. It is
+	// empty until known.
+	Fee       string    `json:"fee,omitempty"`
+	FeeAsset  string    `json:"fee_asset,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Submitted time.Time `json:"submitted"`
+	Updated   time.Time `json:"updated"`
+}
+
+// Position records funds a strategy holds in a protocol, e.g. a vault
+// deposit or an LP NFT, so that it can be managed after a restart.
+type Position struct {
+	Strategy string `json:"strategy"`
+	// ID identifies the position within the strategy, e.g. a vault address
+	// or an NFT token ID.
+	ID       string   `json:"id"`
+	Wallet   string   `json:"wallet"`
+	Chain    chain.ID `json:"chain"`
+	Protocol string   `json:"protocol"`
+	Asset    string   `json:"asset"`
+	// Amount is in the asset's smallest unit; Decimals converts it to whole
+	// units.
+	Amount   string `json:"amount"`
+	Decimals uint8  `json:"decimals"`
+	// Details holds protocol-specific data, e.g. the second token of an LP.
+	Details map[string]string `json:"details,omitempty"`
+	Opened  time.Time         `json:"opened"`
+	Updated time.Time         `json:"updated"`
+}
+
+// EntryKind is the type of a ledger entry.
+type EntryKind string
+
+const (
+	EntryDeposit    EntryKind = "deposit"
+	EntryWithdraw   EntryKind = "withdraw"
+	EntryStake      EntryKind = "stake"
+	EntryUnstake    EntryKind = "unstake"
+	EntryLPMint     EntryKind = "lp_mint"
+	EntryLPBurn     EntryKind = "lp_burn"
+	EntryFeeCollect EntryKind = "fee_collect"
+	EntryBuy        EntryKind = "buy"
+	EntrySell       EntryKind = "sell"
+	// EntryFee records gas or another fee paid without moving funds into
+	// or out of a position.
+	EntryFee EntryKind = "fee"
+)
+
+// Entry records funds moving into or out of a strategy's positions, valued
+// at execution time, for PnL accounting. Multi-asset operations such as LP
+// mints are recorded as one entry per asset.
+type Entry struct {
+	ID       uint64    `json:"id"`
+	RunID    uint64    `json:"run_id"`
+	Strategy string    `json:"strategy"`
+	Wallet   string    `json:"wallet"`
+	Chain    chain.ID  `json:"chain"`
+	Kind     EntryKind `json:"kind"`
+	// Position groups the entries of one position, e.g. a vault address or
+	// the token ID of both legs of an LP.
+	Position string `json:"position,omitempty"`
+	// Asset identifies the asset as in action.Action: a mint, a token
+	// address or a symbol.
+	Asset string `json:"asset"`
+	// Amount is in whole units of Asset.
+	Amount float64 `json:"amount"`
+	// Price is in USD per unit at execution time and zero if unknown.
+	Price float64 `json:"price"`
+	// Fee is in USD, paid on top of Amount.
+	Fee  float64   `json:"fee,omitempty"`
+	Time time.Time `json:"time"`
+}
+
+// Value returns the value of the entry's amount in USD.
+func (e Entry) Value() float64 {
+	return e.Amount * e.Price
+}
+
+// Balance is the amount of an asset held by a wallet at a point in time.
+type Balance struct {
+	Time   time.Time `json:"time"`
+	Wallet string    `json:"wallet"`
+	Chain  chain.ID  `json:"chain,omitempty"`
+	// Account distinguishes balances of the same asset kept in different
+	// places on a chain, e.g. a vault address.
+	Account string  `json:"account,omitempty"`
+	Asset   string  `json:"asset"`
+	Amount  float64 `json:"amount"`
+	// Value is in USD and zero if the asset could not be priced.
+	Value float64 `json:"value,omitempty"`
+}
+
+// StartRun records the start of r and returns it with its ID and start time
+// set.
+func (s *Store) StartRun(r Run) (Run, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		id, err := tx.Bucket(runsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+		r.Status = RunRunning
+		if r.Started.IsZero() {
+			r.Started = time.Now()
+		}
+		return put(tx, runsBucket, itob(id), r)
+	})
+	return r, err
+}
+
+// FinishRun records the outcome of the run with the given ID.
+func (s *Store) FinishRun(id uint64, status RunStatus, runErr error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var r Run
+		ok, err := get(tx, runsBucket, itob(id), &r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("run %d not found", id)
+		}
+		r.Status = status
+		r.Finished = time.Now()
+		if runErr != nil {
+			r.Error = runErr.Error()
+		}
+		return put(tx, runsBucket, itob(id), r)
+	})
+}
+
+// Runs returns up to limit runs of the named strategy, newest first. An
+// empty name returns runs of every strategy and a limit of 0 returns all.
+func (s *Store) Runs(strategy string, limit int) ([]Run, error) {
+	var runs []Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return reverse(tx.Bucket(runsBucket), func(v []byte) (bool, error) {
+			var r Run
+			if err := decode(v, &r); err != nil {
+				return false, err
+			}
+			if strategy == "" || r.Strategy == strategy {
+				runs = append(runs, r)
+			}
+			return limit == 0 || len(runs) < limit, nil
+		})
+	})
+	return runs, err
+}
+
+// AddTx records a submitted transaction and returns its ID.
+func (s *Store) AddTx(t Tx) (uint64, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		id, err := tx.Bucket(txsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		t.ID = id
+		if t.Submitted.IsZero() {
+			t.Submitted = time.Now()
+		}
+		t.Updated = t.Submitted
+		return put(tx, txsBucket, itob(id), t)
+	})
+	return t.ID, err
+}
+
+// UpdateTx applies update to the transaction with the given ID.
+func (s *Store) UpdateTx(id uint64, update func(*Tx)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var t Tx
+		ok, err := get(tx, txsBucket, itob(id), &t)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("transaction %d not found", id)
+		}
+		update(&t)
+		t.ID = id
+		t.Updated = time.Now()
+		return put(tx, txsBucket, itob(id), t)
+	})
+}
+
+// Txs returns up to limit transactions, newest first. A runID of 0 returns
+// transactions of every run and a limit of 0 returns all.
+func (s *Store) Txs(runID uint64, limit int) ([]Tx, error) {
+	var txs []Tx
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return reverse(tx.Bucket(txsBucket), func(v []byte) (bool, error) {
+			var t Tx
+			if err := decode(v, &t); err != nil {
+				return false, err
+			}
+			if runID == 0 || t.RunID == runID {
+				txs = append(txs, t)
+			}
+			return limit == 0 || len(txs) < limit, nil
+		})
+	})
+	return txs, err
+}
+
+// PutPosition creates or updates a position. The time the position was
+// first opened is kept across updates.
+func (s *Store) PutPosition(p Position) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key := positionKey(p.Strategy, p.ID)
+		var existing Position
+		ok, err := get(tx, positionsBucket, key, &existing)
+		if err != nil {
+			return err
+		}
+		p.Updated = time.Now()
+		switch {
+		case ok:
+			p.Opened = existing.Opened
+		case p.Opened.IsZero():
+			p.Opened = p.Updated
+		}
+		return put(tx, positionsBucket, key, p)
+	})
+}
+
+// DeletePosition removes a closed position. Deleting a position that does
+// not exist is not an error.
+func (s *Store) DeletePosition(strategy, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(positionsBucket).Delete(positionKey(strategy, id))
+	})
+}
+
+// Positions returns the open positions of the named strategy, or of every
+// strategy if strategy is empty, ordered by strategy and ID.
+func (s *Store) Positions(strategy string) ([]Position, error) {
+	var prefix []byte
+	if strategy != "" {
+		prefix = positionKey(strategy, "")
+	}
+	var positions []Position
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(positionsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var p Position
+			if err := decode(v, &p); err != nil {
+				return err
+			}
+			positions = append(positions, p)
+		}
+		return nil
+	})
+	return positions, err
+}
+
+// AddEntry records a ledger entry and returns its ID.
+func (s *Store) AddEntry(e Entry) (uint64, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		id, err := tx.Bucket(entriesBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		e.ID = id
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		return put(tx, entriesBucket, itob(id), e)
+	})
+	return e.ID, err
+}
+
+// Entries returns the ledger entries of the named strategy, or of every
+// strategy if strategy is empty, oldest first.
+func (s *Store) Entries(strategy string) ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(_, v []byte) error {
+			var e Entry
+			if err := decode(v, &e); err != nil {
+				return err
+			}
+			if strategy == "" || e.Strategy == strategy {
+				entries = append(entries, e)
+			}
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// AddBalances records a balance snapshot. Balances are ordered by their
+// time, then the order they were added in.
+func (s *Store) AddBalances(balances []Balance) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(balancesBucket)
+		for _, balance := range balances {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			key := append(itob(uint64(balance.Time.UnixNano())), itob(seq)...)
+			if err := put(tx, balancesBucket, key, balance); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Balances returns the balances recorded at or after since, oldest first.
+func (s *Store) Balances(since time.Time) ([]Balance, error) {
+	var balances []Balance
+	err := s.db.View(func(tx *bolt.Tx) error {
+		start := since.UnixNano()
+		if since.IsZero() || start < 0 {
+			start = 0
+		}
+		c := tx.Bucket(balancesBucket).Cursor()
+		for k, v := c.Seek(itob(uint64(start))); k != nil; k, v = c.Next() {
+			var b Balance
+			if err := decode(v, &b); err != nil {
+				return err
+			}
+			balances = append(balances, b)
+		}
+		return nil
+	})
+	return balances, err
+}
+
+// positionKey orders positions by strategy, then ID.
+func positionKey(strategy, id string) []byte {
+	return []byte(strategy + "\x00" + id)
+}
+
+// reverse calls fn with each value in b from the last key to the first
+// until fn returns false.
+func reverse(b *bolt.Bucket, fn func(v []byte) (bool, error)) error {
+	c := b.Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		more, err := fn(v)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
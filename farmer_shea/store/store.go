@@ -0,0 +1,148 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket      = []byte("meta")
+	runsBucket      = []byte("runs")
+	txsBucket       = []byte("txs")
+	positionsBucket = []byte("positions")
+	balancesBucket  = []byte("balances")
+	entriesBucket   = []byte("entries")
+
+	versionKey = []byte("schema_version")
+)
+
+// migration upgrades the schema from version-1 to version.
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *bolt.Tx) error
+}
+
+// migrations are applied in order to bring a store up to date. Append new
+// migrations to the end; never edit or reorder released ones.
+var migrations = []migration{
+	{1, "create runs, txs, positions and balances", func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{runsBucket, txsBucket, positionsBucket, balancesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{2, "create ledger entries", func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(entriesBucket)
+		return err
+	}},
+}
+
+// Store persists strategy runs, submitted transactions, open positions,
+// ledger entries and balance snapshots in an embedded bbolt database. It is safe for
+// concurrent use.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating it if needed, and applies any
+// pending migrations.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store %s: %w", path, err)
+	}
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate state store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Version returns the schema version of the store.
+func (s *Store) Version() (int, error) {
+	var v int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v = version(tx)
+		return nil
+	})
+	return v, err
+}
+
+func (s *Store) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		current := version(tx)
+		if latest := migrations[len(migrations)-1].version; current > latest {
+			return fmt.Errorf("schema version %d is newer than this build supports (%d)", current, latest)
+		}
+		for _, m := range migrations {
+			if m.version <= current {
+				continue
+			}
+			if err := m.apply(tx); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+			}
+			current = m.version
+		}
+		return meta.Put(versionKey, itob(uint64(current)))
+	})
+}
+
+// version returns the schema version recorded in tx, or 0 for a new store.
+func version(tx *bolt.Tx) int {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0
+	}
+	v := meta.Get(versionKey)
+	if v == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(v))
+}
+
+// itob encodes v as a big-endian key, so that keys sort numerically.
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// put stores v as JSON under key in bucket.
+func put(tx *bolt.Tx, bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put(key, data)
+}
+
+// get decodes the JSON value under key in bucket into v. It reports whether
+// the key exists.
+func get(tx *bolt.Tx, bucket, key []byte, v any) (bool, error) {
+	data := tx.Bucket(bucket).Get(key)
+	if data == nil {
+		return false, nil
+	}
+	return true, decode(data, v)
+}
+
+func decode(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
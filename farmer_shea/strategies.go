@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/config"
+	"github.com/sheawinkler/farmer-shea/executor"
+	"github.com/sheawinkler/farmer-shea/schedule"
+	"github.com/sheawinkler/farmer-shea/strategy"
+	"github.com/sheawinkler/farmer-shea/wallet"
+)
+
+// buildStrategies creates the enabled strategy instances from the config,
+// assigns them to their wallets and returns their schedule overrides. The
+// config has already been validated, so errors here come from the factories.
+func buildStrategies(cfg *config.Config, deps strategy.Deps) (*strategy.Manager, map[string]schedule.Schedule, error) {
+	m := strategy.NewManager()
+	schedules := make(map[string]schedule.Schedule)
+
+	var errs []error
+	for _, sc := range cfg.Strategies {
+		if !sc.IsEnabled() {
+			log.Info().Str("strategy", sc.Name).Msg("Strategy disabled")
+			continue
+		}
+
+		s, err := strategy.Build(sc.Type, sc.Name, sc.Params, deps)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("strategy %s: %w", sc.Name, err))
+			continue
+		}
+		m.Add(s)
+		if sc.Wallet != "" {
+			m.Assign(sc.Name, sc.Wallet)
+		}
+
+		if sc.Schedule.Spec == "" && sc.Schedule.Jitter == 0 {
+			continue
+		}
+		sched, err := strategySchedule(s, sc.Schedule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("strategy %s: %w", sc.Name, err))
+			continue
+		}
+		schedules[sc.Name] = sched
+		log.Info().Str("strategy", sc.Name).Str("schedule", sched.String()).Msg("Configured strategy schedule")
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	return m, schedules, nil
+}
+
+// applyConfigChange brings the running strategies in line with a reloaded
+// config. Added and changed instances are rebuilt and swapped in before
+// their next run; removed and disabled ones stop after their current run.
+// Unchanged instances keep running untouched. An instance that fails to
+// build keeps its previous version.
+func applyConfigChange(c config.Change, m *strategy.Manager, exe *executor.Executor, deps strategy.Deps, wallets *wallet.Set) {
+	for _, name := range c.Diff.Removed {
+		m.Remove(name)
+		exe.Reload(name)
+		log.Info().Str("strategy", name).Msg("Removed strategy")
+	}
+
+	for _, sc := range c.New.Strategies {
+		if !slices.Contains(c.Diff.Added, sc.Name) && !slices.Contains(c.Diff.Changed, sc.Name) {
+			continue
+		}
+		if !sc.IsEnabled() {
+			m.Remove(sc.Name)
+			exe.Reload(sc.Name)
+			log.Info().Str("strategy", sc.Name).Msg("Strategy disabled")
+			continue
+		}
+
+		walletName := sc.Wallet
+		if walletName == "" {
+			walletName = wallet.Default
+		}
+		if _, err := wallets.Get(walletName); err != nil {
+			log.Error().Err(err).Str("strategy", sc.Name).Msg("Wallet not loaded; restart to unlock new wallets")
+			continue
+		}
+		s, err := strategy.Build(sc.Type, sc.Name, sc.Params, deps)
+		if err != nil {
+			log.Error().Err(err).Str("strategy", sc.Name).Msg("Failed to rebuild strategy; keeping the previous version")
+			continue
+		}
+		var sched schedule.Schedule
+		if sc.Schedule.Spec != "" || sc.Schedule.Jitter != 0 {
+			if sched, err = strategySchedule(s, sc.Schedule); err != nil {
+				log.Error().Err(err).Str("strategy", sc.Name).Msg("Invalid schedule; keeping the previous version")
+				continue
+			}
+		}
+
+		m.Add(s)
+		m.Assign(sc.Name, walletName)
+		exe.SetSchedule(sc.Name, sched)
+		exe.Reload(sc.Name)
+		log.Info().Str("strategy", sc.Name).Str("wallet", walletName).Msg("Reloaded strategy")
+	}
+}
+
+// strategySchedule applies a schedule override to s. A config without a
+// spec only adds jitter to the strategy's own schedule.
+func strategySchedule(s strategy.Strategy, sc config.ScheduleConfig) (schedule.Schedule, error) {
+	sched := schedule.Interval(schedule.DefaultInterval)
+	if declared, ok := s.(strategy.Scheduled); ok {
+		sched = declared.Schedule()
+	}
+	if sc.Spec != "" {
+		parsed, err := schedule.Parse(sc.Spec)
+		if err != nil {
+			return nil, err
+		}
+		sched = parsed
+	}
+	return schedule.WithJitter(sched, sc.Jitter), nil
+}
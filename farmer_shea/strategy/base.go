@@ -1,44 +1,73 @@
 package strategy
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
 	"github.com/sheawinkler/farmer-shea/base"
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/signer"
 )
 
 // --- Simple Yield Farming Strategy ---
 
 type simpleYieldFarmingStrategy struct {
+	name       string
 	baseClient *base.Client
 }
 
-func NewSimpleYieldFarmingStrategy(client *base.Client) Strategy {
-	return &simpleYieldFarmingStrategy{baseClient: client}
+func init() {
+	Register("simple_yield_farming", nil, func(name string, p Params, d Deps) (Strategy, error) {
+		return NewSimpleYieldFarmingStrategy(name, d.Base), nil
+	})
+}
+
+func NewSimpleYieldFarmingStrategy(name string, client *base.Client) Strategy {
+	return &simpleYieldFarmingStrategy{name: name, baseClient: client}
 }
 
 func (s *simpleYieldFarmingStrategy) Name() string {
-	return "SimpleYieldFarming"
+	return s.name
 }
 
-func (s *simpleYieldFarmingStrategy) Execute(w wallet.Wallet) error {
-	fmt.Println("Executing simple yield farming strategy on Base...")
+// Chain holds the strategy's capital on Base.
+func (s *simpleYieldFarmingStrategy) Chain() chain.ID {
+	return chain.Base
+}
 
+func (s *simpleYieldFarmingStrategy) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
 	// Example: Get a USDC-WETH pool with a 0.05% fee
 	usdc := common.HexToAddress("0x833589fCD6eDbE023dEEd136f9aAd50C355A4dF7")
 	weth := common.HexToAddress("0x4200000000000000000000000000000000000006")
 	fee := big.NewInt(500) // 0.05%
 
-	poolAddress, err := s.baseClient.GetUniswapV3PoolAddress(usdc, weth, fee)
+	poolAddress, err := s.baseClient.GetUniswapV3PoolAddress(ctx, usdc, weth, fee)
 	if err != nil {
-		return fmt.Errorf("failed to get Uniswap V3 pool address: %w", err)
+		return nil, fmt.Errorf("failed to get Uniswap V3 pool address: %w", err)
 	}
 
-	fmt.Printf("Uniswap V3 Pool Address: %s\n", poolAddress.Hex())
+	log.Debug().Str("strategy", s.name).Str("pool", poolAddress.Hex()).Msg("Found Uniswap V3 pool")
 
 	// Example: Swap 100 USDC for WETH
-	amount := big.NewInt(100)
-	return s.baseClient.Swap(poolAddress, amount)
+	return []action.Action{{
+		Kind:      action.Swap,
+		Chain:     chain.Base,
+		Protocol:  "uniswap-v3",
+		Asset:     usdc.Hex(),
+		Amount:    big.NewInt(100),
+		Target:    poolAddress.Hex(),
+		Rationale: "example swap of USDC for WETH",
+		Params:    map[string]string{"token_out": weth.Hex()},
+	}}, nil
+}
+
+func (s *simpleYieldFarmingStrategy) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	if a.Kind != action.Swap {
+		return unsupported(s, a)
+	}
+	return s.baseClient.Swap(ctx, common.HexToAddress(a.Target), a.Amount)
 }
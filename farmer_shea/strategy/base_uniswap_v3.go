@@ -1,87 +1,271 @@
 package strategy
 
 import (
-	"crypto/ecdsa"
+	"bytes"
+	"context"
 	"fmt"
 	"math/big"
+	"strconv"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
 	"github.com/sheawinkler/farmer-shea/base"
 	"github.com/sheawinkler/farmer-shea/base/nonfungiblepositionmanager"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/store"
 	"github.com/sheawinkler/farmer-shea/util"
-	"github.com/sheawinkler/farmer-shea/wallet"
 )
 
 // --- Uniswap V3 LP Strategy ---
 
 type uniswapV3LPStrategy struct {
+	name       string
 	baseClient *base.Client
 	hyperliquidClient *hyperliquid.Client
 	tokenA     common.Address
 	tokenB     common.Address
 	fee        *big.Int
-	amountA    *big.Int
-	amountB    *big.Int
+	// amountA and amountB are decimal amounts in whole tokens.
+	amountA string
+	amountB string
 }
 
-func NewUniswapV3LPStrategy(client *base.Client, hyperliquidClient *hyperliquid.Client, tokenA, tokenB, amountA, amountB string, fee int64) Strategy {
-	a, _ := new(big.Int).SetString(amountA, 10)
-	b, _ := new(big.Int).SetString(amountB, 10)
+// uniswapFeeTiers are the fee tiers Uniswap V3 pools are deployed with.
+var uniswapFeeTiers = []int64{100, 500, 3000, 10000}
+
+func init() {
+	Register("uniswap_v3_lp", Schema{
+		{Name: "token_a", Type: Address, Required: true, Doc: "address of the first token"},
+		{Name: "token_b", Type: Address, Required: true, Doc: "address of the second token"},
+		{Name: "amount_a", Type: Decimal, Required: true, Doc: "amount of token A in whole tokens, e.g. \"100\""},
+		{Name: "amount_b", Type: Decimal, Required: true, Doc: "amount of token B in whole tokens, e.g. \"0.05\""},
+		{Name: "fee", Type: Int, Default: 500, Check: OneOf(uniswapFeeTiers...), Doc: "pool fee tier in hundredths of a basis point"},
+	}, func(name string, p Params, d Deps) (Strategy, error) {
+		return NewUniswapV3LPStrategy(name, d.Base, d.Hyperliquid, p.String("token_a"), p.String("token_b"), p.String("amount_a"), p.String("amount_b"), p.Int("fee"))
+	})
+}
+
+// NewUniswapV3LPStrategy creates a Uniswap V3 LP strategy. Amounts are
+// decimal amounts in whole tokens; they are converted using each token's
+// decimals when the strategy plans.
+func NewUniswapV3LPStrategy(name string, client *base.Client, hyperliquidClient *hyperliquid.Client, tokenA, tokenB, amountA, amountB string, fee int64) (Strategy, error) {
+	for _, amount := range []string{amountA, amountB} {
+		r, ok := new(big.Rat).SetString(amount)
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid LP amount %q: must be a positive decimal", amount)
+		}
+	}
 	return &uniswapV3LPStrategy{
+		name:       name,
 		baseClient: client,
 		hyperliquidClient: hyperliquidClient,
 		tokenA:     common.HexToAddress(tokenA),
 		tokenB:     common.HexToAddress(tokenB),
 		fee:        big.NewInt(fee),
-		amountA:    a,
-		amountB:    b,
-	}
+		amountA:    amountA,
+		amountB:    amountB,
+	}, nil
 }
 
 func (s *uniswapV3LPStrategy) Name() string {
-	return "UniswapV3LP"
+	return s.name
 }
 
-func (s *uniswapV3LPStrategy) Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error {
-	fmt.Println("Executing Uniswap V3 LP strategy on Base...")
+// Chain holds the strategy's capital on Base.
+func (s *uniswapV3LPStrategy) Chain() chain.ID {
+	return chain.Base
+}
 
-	// Approve the router to spend tokens
-	if err := s.baseClient.Approve(privateKey, s.tokenA, common.HexToAddress(base.NonfungiblePositionManagerAddress), s.amountA); err != nil {
-		return fmt.Errorf("failed to approve token A: %w", err)
+// Plan mints a position around the current price, sized by recent
+// volatility, unless the strategy already holds a position in the pool.
+func (s *uniswapV3LPStrategy) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	Track(ctx, "looking for an open position")
+	if p, ok, err := s.openPosition(ctx); err != nil || ok {
+		if ok {
+			log.Debug().Str("strategy", s.name).Str("position", p.ID).Msg("Position already open; not minting another")
+		}
+		return nil, err
 	}
-	if err := s.baseClient.Approve(privateKey, s.tokenB, common.HexToAddress(base.NonfungiblePositionManagerAddress), s.amountB); err != nil {
-		return fmt.Errorf("failed to approve token B: %w", err)
+
+	Track(ctx, "resolving token decimals")
+	amountA, decimalsA, err := s.tokenAmount(ctx, s.tokenA, s.amountA)
+	if err != nil {
+		return nil, err
+	}
+	amountB, decimalsB, err := s.tokenAmount(ctx, s.tokenB, s.amountB)
+	if err != nil {
+		return nil, err
 	}
 
 	// Calculate the tick range
-	tickLower, tickUpper, err := s.calculateTickRange()
+	tickLower, tickUpper, err := s.calculateTickRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return []action.Action{s.mintAction(
+		lpLeg{s.tokenA, amountA, decimalsA},
+		lpLeg{s.tokenB, amountB, decimalsB},
+		tickLower, tickUpper,
+	)}, nil
+}
+
+// lpLeg is an amount of one of the pool's tokens.
+type lpLeg struct {
+	token    common.Address
+	amount   *big.Int
+	decimals uint8
+}
+
+// mintAction returns the action minting a position with legs a and b.
+// Pools order their tokens by address, so the action's asset is token0 and
+// its second token token1; risk checks value both.
+func (s *uniswapV3LPStrategy) mintAction(a, b lpLeg, tickLower, tickUpper *big.Int) action.Action {
+	if bytes.Compare(a.token.Bytes(), b.token.Bytes()) > 0 {
+		a, b = b, a
+	}
+	return action.Action{
+		Kind:      action.MintLP,
+		Chain:     chain.Base,
+		Protocol:  "uniswap-v3",
+		Asset:     a.token.Hex(),
+		Amount:    a.amount,
+		Decimals:  a.decimals,
+		Target:    base.NonfungiblePositionManagerAddress,
+		Rationale: "provide liquidity within one standard deviation of the current price",
+		Params: map[string]string{
+			action.ParamTokenB:    b.token.Hex(),
+			action.ParamAmountB:   b.amount.String(),
+			action.ParamDecimalsB: strconv.Itoa(int(b.decimals)),
+			"fee":                 s.fee.String(),
+			"tick_lower":          tickLower.String(),
+			"tick_upper":          tickUpper.String(),
+		},
+	}
+}
+
+// openPosition returns the recorded position of the strategy in its pool,
+// if any.
+func (s *uniswapV3LPStrategy) openPosition(ctx context.Context) (store.Position, bool, error) {
+	positions, err := store.Positions(ctx)
+	if err != nil {
+		return store.Position{}, false, err
+	}
+	for _, p := range positions {
+		if p.Protocol != "uniswap-v3" || p.Details["fee"] != s.fee.String() {
+			continue
+		}
+		// Positions minted before tokens were sorted may hold them either
+		// way round.
+		asset, tokenB := common.HexToAddress(p.Asset), common.HexToAddress(p.Details[action.ParamTokenB])
+		if asset == s.tokenA && tokenB == s.tokenB || asset == s.tokenB && tokenB == s.tokenA {
+			return p, true, nil
+		}
+	}
+	return store.Position{}, false, nil
+}
+
+// tokenAmount converts a decimal amount of token into its smallest unit.
+func (s *uniswapV3LPStrategy) tokenAmount(ctx context.Context, token common.Address, amount string) (*big.Int, uint8, error) {
+	decimals, err := s.baseClient.TokenDecimals(ctx, token)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get decimals of %s: %w", token.Hex(), err)
+	}
+	units, err := action.ParseAmount(amount, decimals)
+	if err != nil {
+		return nil, 0, errkind.Wrap(errkind.Permanent, err)
+	}
+	return units, decimals, nil
+}
+
+// Apply approves both tokens and mints the position.
+func (s *uniswapV3LPStrategy) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	if a.Kind != action.MintLP {
+		return unsupported(s, a)
+	}
+	evm, err := keys.EVM()
 	if err != nil {
 		return err
 	}
 
+	tokenA := common.HexToAddress(a.Asset)
+	tokenB := common.HexToAddress(a.Params[action.ParamTokenB])
+	amountB, okB := new(big.Int).SetString(a.Params[action.ParamAmountB], 10)
+	fee, okFee := new(big.Int).SetString(a.Params["fee"], 10)
+	tickLower, okLower := new(big.Int).SetString(a.Params["tick_lower"], 10)
+	tickUpper, okUpper := new(big.Int).SetString(a.Params["tick_upper"], 10)
+	if !okB || !okFee || !okLower || !okUpper {
+		return errkind.Wrapf(errkind.Permanent, "malformed %s action params: %v", a.Kind, a.Params)
+	}
+	if bytes.Compare(tokenA.Bytes(), tokenB.Bytes()) >= 0 {
+		return errkind.Wrapf(errkind.Permanent, "%s tokens %s and %s are not sorted by address", a.Kind, tokenA.Hex(), tokenB.Hex())
+	}
+
+	// Approve the router to spend tokens
+	npm := common.HexToAddress(base.NonfungiblePositionManagerAddress)
+	Track(ctx, "approving token A %s", tokenA.Hex())
+	if err := s.baseClient.Approve(ctx, evm, tokenA, npm, a.Amount); err != nil {
+		return fmt.Errorf("failed to approve token A: %w", err)
+	}
+	Track(ctx, "approving token B %s", tokenB.Hex())
+	if err := s.baseClient.Approve(ctx, evm, tokenB, npm, amountB); err != nil {
+		return fmt.Errorf("failed to approve token B: %w", err)
+	}
+
 	// Add liquidity to the pool
 	params := nonfungiblepositionmanager.INonfungiblePositionManagerMintParams{
-		Token0:         s.tokenA,
-		Token1:         s.tokenB,
-		Fee:            s.fee,
+		Token0:         tokenA,
+		Token1:         tokenB,
+		Fee:            fee,
 		TickLower:      tickLower,
 		TickUpper:      tickUpper,
-		Amount0Desired: s.amountA,
-		Amount1Desired: s.amountB,
+		Amount0Desired: a.Amount,
+		Amount1Desired: amountB,
 		Amount0Min:     big.NewInt(0),
 		Amount1Min:     big.NewInt(0),
-		Recipient:      w.PublicKey,
+		Recipient:      evm.CommonAddress(),
 		Deadline:       big.NewInt(time.Now().Add(15 * time.Minute).Unix()),
 	}
 
-	return s.baseClient.AddLiquidity(privateKey, params)
+	Track(ctx, "minting position in ticks [%s, %s]", tickLower, tickUpper)
+	minted, err := s.baseClient.AddLiquidity(ctx, evm, params)
+	if err != nil || minted == nil {
+		return err
+	}
+	tokenID := minted.TokenID.String()
+
+	decimalsB, _ := strconv.Atoi(a.Params[action.ParamDecimalsB])
+	pnl.Record(ctx, store.Entry{Kind: store.EntryLPMint, Chain: a.Chain, Position: tokenID, Asset: tokenA.Hex(), Amount: units(minted.Amount0, a.Decimals)})
+	pnl.Record(ctx, store.Entry{Kind: store.EntryLPMint, Chain: a.Chain, Position: tokenID, Asset: tokenB.Hex(), Amount: units(minted.Amount1, uint8(decimalsB))})
+
+	savePosition(ctx, store.Position{
+		ID:       tokenID,
+		Chain:    a.Chain,
+		Protocol: a.Protocol,
+		Asset:    a.Asset,
+		Amount:   a.Amount.String(),
+		Decimals: a.Decimals,
+		Details: map[string]string{
+			action.ParamTokenB:    tokenB.Hex(),
+			action.ParamAmountB:   amountB.String(),
+			action.ParamDecimalsB: a.Params[action.ParamDecimalsB],
+			"fee":                 fee.String(),
+			"tick_lower":          tickLower.String(),
+			"tick_upper":          tickUpper.String(),
+		},
+	})
+	return nil
 }
 
-func (s *uniswapV3LPStrategy) calculateTickRange() (*big.Int, *big.Int, error) {
+func (s *uniswapV3LPStrategy) calculateTickRange(ctx context.Context) (*big.Int, *big.Int, error) {
 	// This is a simplified implementation. A more robust implementation would
 	// involve using a more sophisticated volatility model.
-	klines, err := s.hyperliquidClient.GetKlines("ETH", "1h", 100)
+	klines, err := s.hyperliquidClient.GetKlines(ctx, "ETH", "1h", 100)
 	if err != nil {
 		return nil, nil, err
 	}
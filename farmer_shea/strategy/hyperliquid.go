@@ -1,85 +1,179 @@
 package strategy
 
 import (
-	"crypto/ecdsa"
+	"context"
 	"fmt"
-	"sort"
+	"math/big"
 
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
 	"github.com/sheawinkler/farmer-shea/hyperliquid"
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/yield"
 )
 
+// usdcDecimals is the precision of USDC, in which Hyperliquid vaults are
+// denominated.
+const usdcDecimals = 6
+
 // --- Simple Vault Deposit Strategy ---
 
 type simpleVaultDepositStrategy struct {
+	name              string
 	hyperliquidClient *hyperliquid.Client
+	vaults            []string
 	amount            string
 	stopLoss          float64
+	costs             yield.Costs
+}
+
+func init() {
+	Register("hyperliquid_vault", Schema{
+		{Name: "vaults", Type: Addresses, Required: true, Doc: "addresses of the vaults to choose between"},
+		{Name: "amount", Type: Decimal, Required: true, Doc: "USDC to deposit, e.g. \"100\""},
+		{Name: "stop_loss", Type: Float, Default: 0.05, Check: Range(0, 1), Doc: "withdraw when the best vault's APY net of costs falls below this fraction"},
+	}, func(name string, p Params, d Deps) (Strategy, error) {
+		if _, err := action.ParseAmount(p.String("amount"), usdcDecimals); err != nil {
+			return nil, err
+		}
+		return NewSimpleVaultDepositStrategy(name, d.Hyperliquid, p.Strings("vaults"), p.String("amount"), p.Float("stop_loss"), d.Costs), nil
+	})
 }
 
-func NewSimpleVaultDepositStrategy(client *hyperliquid.Client, amount string, stopLoss float64) Strategy {
+func NewSimpleVaultDepositStrategy(name string, client *hyperliquid.Client, vaults []string, amount string, stopLoss float64, costs yield.Costs) Strategy {
 	return &simpleVaultDepositStrategy{
+		name:              name,
 		hyperliquidClient: client,
+		vaults:            vaults,
 		amount:            amount,
 		stopLoss:          stopLoss,
+		costs:             costs,
 	}
 }
 
 func (s *simpleVaultDepositStrategy) Name() string {
-	return "SimpleVaultDeposit"
+	return s.name
+}
+
+// Chain holds the strategy's capital on Hyperliquid.
+func (s *simpleVaultDepositStrategy) Chain() chain.ID {
+	return chain.Hyperliquid
 }
 
-func (s *simpleVaultDepositStrategy) Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error {
-	vaults, err := s.getVaults()
+// Plan deposits into the vault with the best APY net of costs, or withdraws
+// if even that is below the stop-loss threshold.
+func (s *simpleVaultDepositStrategy) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	amount, err := action.ParseAmount(s.amount, usdcDecimals)
 	if err != nil {
-		return err
+		return nil, errkind.Wrap(errkind.Permanent, err)
 	}
 
-	bestVault, err := s.determineBestVault(vaults)
+	bestVault, err := s.bestVault(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.planVault(ctx, bestVault, amount)
+}
 
-	if bestVault.Apy < s.stopLoss {
-		fmt.Printf("Vault APY (%f) is below stop-loss threshold (%f). Withdrawing funds...\n", bestVault.Apy, s.stopLoss)
-		return s.hyperliquidClient.WithdrawFromVault(s.amount, bestVault.Address)
+// planVault deposits amount into bestVault, or withdraws if its APY net of
+// costs is below the stop-loss threshold.
+func (s *simpleVaultDepositStrategy) planVault(ctx context.Context, bestVault yield.Ranked, amount *big.Int) ([]action.Action, error) {
+	a := action.Action{
+		Kind:      action.VaultDeposit,
+		Chain:     chain.Hyperliquid,
+		Protocol:  "hyperliquid-vaults",
+		Asset:     "USDC",
+		Amount:    amount,
+		Decimals:  usdcDecimals,
+		Target:    bestVault.ID,
+		Rationale: fmt.Sprintf("best vault APY %.2f%% net of costs (%.2f%% before)", bestVault.NetAPY*100, bestVault.APY*100),
+	}
+	if bestVault.NetAPY >= s.stopLoss {
+		return []action.Action{a}, nil
 	}
 
-	fmt.Printf("Executing simple vault deposit strategy on Hyperliquid with amount %s to vault %s...\n", s.amount, bestVault.Address)
-	return s.hyperliquidClient.DepositToVault(s.amount, bestVault.Address)
+	// Withdraw everything held in the vaults we deposited into; once that
+	// is done there is nothing left to withdraw. Without a state store
+	// there are no recorded positions, so fall back to withdrawing the
+	// configured amount from the best vault.
+	a.Kind = action.VaultWithdraw
+	a.Rationale = fmt.Sprintf("best vault APY net of costs (%.2f%%) is below stop-loss threshold (%.2f%%)", bestVault.NetAPY*100, s.stopLoss*100)
+	if !store.Attached(ctx) {
+		return []action.Action{a}, nil
+	}
+	return withdrawAll(ctx, action.VaultWithdraw, a.Rationale)
 }
 
-func (s *simpleVaultDepositStrategy) getVaults() ([]hyperliquid.VaultDetails, error) {
-	// In a real implementation, this list would be fetched from a community-maintained list or by scraping the Hyperliquid UI.
-	vaultAddresses := []string{
-		"0x1a2b3c4d5e6f7g8h9i0j1k2l3m4n5o6p7q8r9s0t",
-		"0x1a2b3c4d5e6f7g8h9i0j1k2l3m4n5o6p7q8r9s0u",
-	}
+// Unwind withdraws everything held in the vaults we deposited into.
+func (s *simpleVaultDepositStrategy) Unwind(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	return withdrawAll(ctx, action.VaultWithdraw, "unwinding positions")
+}
 
-	var vaults []hyperliquid.VaultDetails
-	for _, address := range vaultAddresses {
-		details, err := s.hyperliquidClient.GetVaultDetails(address)
-		if err != nil {
-			// Log the error, but continue to the next vault
-			fmt.Printf("Error getting details for vault %s: %s\n", address, err)
-			continue
-		}
-		vaults = append(vaults, *details)
-	}
+// vaultRisk scores vault deposits: vaults trade perps, so their capital can
+// be lost, but the stop-loss withdraws it once their APY falls.
+const vaultRisk = 0.5
 
-	return vaults, nil
+// Yield reports the APY of the best vault, net of costs.
+func (s *simpleVaultDepositStrategy) Yield(ctx context.Context) (Yield, error) {
+	best, err := s.bestVault(ctx)
+	if err != nil {
+		return Yield{}, err
+	}
+	return Yield{APY: best.NetAPY, Risk: vaultRisk}, nil
 }
 
-func (s *simpleVaultDepositStrategy) determineBestVault(vaults []hyperliquid.VaultDetails) (*hyperliquid.VaultDetails, error) {
-	if len(vaults) == 0 {
-		return nil, fmt.Errorf("no vaults found")
+// Apply deposits into or withdraws from a vault.
+func (s *simpleVaultDepositStrategy) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	hl, err := keys.Hyperliquid()
+	if err != nil {
+		return err
+	}
+	amount := action.FormatAmount(a.Amount, a.Decimals)
+	delta := new(big.Int).Set(a.Amount)
+	kind := store.EntryDeposit
+	switch a.Kind {
+	case action.VaultDeposit:
+		Track(ctx, "depositing %s to vault %s", amount, a.Target)
+		err = s.hyperliquidClient.DepositToVault(ctx, hl, a.Amount, a.Target)
+	case action.VaultWithdraw:
+		Track(ctx, "withdrawing %s from vault %s", amount, a.Target)
+		err = s.hyperliquidClient.WithdrawFromVault(ctx, hl, a.Amount, a.Target)
+		delta.Neg(delta)
+		kind = store.EntryWithdraw
+	default:
+		return unsupported(s, a)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Sort by APY in descending order
-	sort.Slice(vaults, func(i, j int) bool {
-		return vaults[i].Apy > vaults[j].Apy
-	})
+	recordEntry(ctx, kind, a.Target, a)
+	if a.Kind == action.VaultWithdraw && !Unwinding(ctx) {
+		events.Publish(ctx, &events.StopLoss{Action: a.String(), Reason: a.Rationale})
+	}
+	adjustPosition(ctx, store.Position{
+		ID:       a.Target,
+		Chain:    a.Chain,
+		Protocol: a.Protocol,
+		Asset:    a.Asset,
+		Decimals: a.Decimals,
+	}, delta)
+	return nil
+}
 
-	// In a real implementation, we would also consider TVL and other factors.
-	return &vaults[0], nil
+// bestVault returns the vault with the best APY net of costs.
+func (s *simpleVaultDepositStrategy) bestVault(ctx context.Context) (yield.Ranked, error) {
+	source := &yield.HyperliquidVaults{Client: s.hyperliquidClient, Vaults: s.vaults}
+	vaults, err := source.Opportunities(ctx)
+	if err != nil {
+		return yield.Ranked{}, err
+	}
+	best, err := yield.Best(vaults, s.costs)
+	if err != nil {
+		return yield.Ranked{}, fmt.Errorf("no vaults found: %w", err)
+	}
+	return best, nil
 }
@@ -1,25 +1,47 @@
 package strategy
 
 import (
-	"crypto/ecdsa"
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/events"
 	"github.com/sheawinkler/farmer-shea/hyperliquid"
+	"github.com/sheawinkler/farmer-shea/schedule"
+	"github.com/sheawinkler/farmer-shea/signer"
 	"github.com/sheawinkler/farmer-shea/util"
-	"github.com/sheawinkler/farmer-shea/wallet"
 )
 
 // --- Moving Average Crossover Strategy ---
 
 type maCrossoverStrategy struct {
+	name              string
 	hyperliquidClient *hyperliquid.Client
 	symbol            string
 	shortPeriod       int
 	longPeriod        int
 }
 
-func NewMACrossoverStrategy(client *hyperliquid.Client, symbol string, shortPeriod, longPeriod int) Strategy {
+func init() {
+	Register("ma_crossover", Schema{
+		{Name: "symbol", Type: String, Required: true, Doc: "coin to trade, e.g. \"ETH\""},
+		{Name: "short_period", Type: Int, Default: 10, Doc: "short moving average period, in hourly klines"},
+		{Name: "long_period", Type: Int, Default: 50, Doc: "long moving average period, in hourly klines"},
+	}, func(name string, p Params, d Deps) (Strategy, error) {
+		if short, long := p.Int("short_period"), p.Int("long_period"); short <= 0 || short >= long {
+			return nil, fmt.Errorf("periods must satisfy 0 < short_period < long_period, got %d and %d", short, long)
+		}
+		return NewMACrossoverStrategy(name, d.Hyperliquid, p.String("symbol"), int(p.Int("short_period")), int(p.Int("long_period"))), nil
+	})
+}
+
+func NewMACrossoverStrategy(name string, client *hyperliquid.Client, symbol string, shortPeriod, longPeriod int) Strategy {
 	return &maCrossoverStrategy{
+		name:              name,
 		hyperliquidClient: client,
 		symbol:            symbol,
 		shortPeriod:       shortPeriod,
@@ -28,27 +50,64 @@ func NewMACrossoverStrategy(client *hyperliquid.Client, symbol string, shortPeri
 }
 
 func (s *maCrossoverStrategy) Name() string {
-	return "MACrossover"
+	return s.name
+}
+
+// Chain holds the strategy's capital on Hyperliquid.
+func (s *maCrossoverStrategy) Chain() chain.ID {
+	return chain.Hyperliquid
+}
+
+// Schedule runs the strategy each time a new 1h candle closes.
+func (s *maCrossoverStrategy) Schedule() schedule.Schedule {
+	return schedule.KlineClose(time.Hour)
 }
 
-func (s *maCrossoverStrategy) Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error {
-	klines, err := s.hyperliquidClient.GetKlines(s.symbol, "1h", s.longPeriod)
+// Plan computes the crossover signal. It does not place orders yet, so it
+// never returns actions.
+func (s *maCrossoverStrategy) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	if s.shortPeriod <= 0 || s.shortPeriod >= s.longPeriod {
+		return nil, errkind.Wrapf(errkind.Permanent, "invalid MA periods: short (%d) must be positive and less than long (%d)", s.shortPeriod, s.longPeriod)
+	}
+
+	klines, err := s.hyperliquidClient.GetKlines(ctx, s.symbol, "1h", s.longPeriod)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(klines) < s.longPeriod {
+		return nil, errkind.Wrapf(errkind.Transient, "got %d klines for %s, need %d", len(klines), s.symbol, s.longPeriod)
 	}
 
 	shortSMA := util.CalculateSMA(klines, s.shortPeriod)
 	longSMA := util.CalculateSMA(klines, s.longPeriod)
+	signal := crossoverSignal(shortSMA, longSMA)
+	log.Debug().Str("strategy", s.name).Str("symbol", s.symbol).Float64("shortSMA", shortSMA).Float64("longSMA", longSMA).Msg("Computed moving averages")
 
-	fmt.Printf("Executing MA Crossover strategy for %s. Short SMA: %f, Long SMA: %f\n", s.symbol, shortSMA, longSMA)
+	// In a real implementation, a signal would place an order.
+	if signal == "" {
+		return nil, nil
+	}
+	events.Publish(ctx, &events.SignalGenerated{
+		Symbol: s.symbol,
+		Signal: signal,
+		Values: map[string]float64{"short_sma": shortSMA, "long_sma": longSMA},
+	})
+	return nil, nil
+}
 
-	if shortSMA > longSMA {
-		fmt.Println("BUY SIGNAL")
-		// In a real implementation, this would involve placing a buy order.
-	} else if shortSMA < longSMA {
-		fmt.Println("SELL SIGNAL")
-		// In a real implementation, this would involve placing a sell order.
+// crossoverSignal returns "buy" if the short moving average is above the
+// long one, "sell" if it is below and "" if they are equal.
+func crossoverSignal(shortSMA, longSMA float64) string {
+	switch {
+	case shortSMA > longSMA:
+		return "buy"
+	case shortSMA < longSMA:
+		return "sell"
 	}
+	return ""
+}
 
-	return nil
+// Apply rejects all actions; see Plan.
+func (s *maCrossoverStrategy) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	return unsupported(s, a)
 }
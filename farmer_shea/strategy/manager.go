@@ -1,16 +1,84 @@
 package strategy
 
-// Manager manages all the strategies.
+import (
+	"sync"
+
+	"github.com/sheawinkler/farmer-shea/wallet"
+)
+
+// Manager manages all the strategies. It is safe for concurrent use, so
+// strategies can be replaced while the executor runs them.
 type Manager struct {
-	Strategies []Strategy
+	mu         sync.RWMutex
+	strategies []Strategy
+	wallets    map[string]string
 }
 
 // NewManager creates a new strategy manager.
 func NewManager() *Manager {
-	return &Manager{Strategies: []Strategy{}}
+	return &Manager{wallets: make(map[string]string)}
 }
 
-// Add adds a new strategy to the manager.
+// Add adds a new strategy to the manager, replacing any strategy with the
+// same name.
 func (m *Manager) Add(s Strategy) {
-	m.Strategies = append(m.Strategies, s)
-}
\ No newline at end of file
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.strategies {
+		if existing.Name() == s.Name() {
+			m.strategies[i] = s
+			return
+		}
+	}
+	m.strategies = append(m.strategies, s)
+}
+
+// Remove removes the named strategy and its wallet assignment.
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, s := range m.strategies {
+		if s.Name() == name {
+			m.strategies = append(m.strategies[:i], m.strategies[i+1:]...)
+			break
+		}
+	}
+	delete(m.wallets, name)
+}
+
+// Get returns the named strategy.
+func (m *Manager) Get(name string) (Strategy, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.strategies {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Strategies returns the strategies in the order they were added.
+func (m *Manager) Strategies() []Strategy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Strategy(nil), m.strategies...)
+}
+
+// Assign runs the named strategy with the keys of the named wallet.
+func (m *Manager) Assign(strategy, wallet string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wallets[strategy] = wallet
+}
+
+// Wallet returns the name of the wallet assigned to the named strategy.
+// Unassigned strategies use wallet.Default.
+func (m *Manager) Wallet(strategy string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if name, ok := m.wallets[strategy]; ok {
+		return name
+	}
+	return wallet.Default
+}
@@ -2,14 +2,20 @@ package strategy
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
+	"math/big"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/programs/system"
 	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/schedule"
+	"github.com/sheawinkler/farmer-shea/signer"
 	"github.com/sheawinkler/farmer-shea/solana"
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/yield"
 )
 
 const (
@@ -20,33 +26,110 @@ const (
 // MarinadeStakingStrategy is a strategy for staking SOL on Marinade Finance.	
 
 type MarinadeStakingStrategy struct {
+	name         string
 	solanaClient *solana.Client
 	amount       uint64
+	apy          *yield.Marinade
+	costs        yield.Costs
+}
+
+func init() {
+	Register("marinade", Schema{
+		{Name: "amount", Type: Decimal, Required: true, Doc: "SOL to stake, e.g. \"1.5\""},
+	}, func(name string, p Params, d Deps) (Strategy, error) {
+		lamports, err := action.ParseAmount(p.String("amount"), 9)
+		if err != nil {
+			return nil, err
+		}
+		if lamports.Sign() <= 0 || !lamports.IsUint64() {
+			return nil, fmt.Errorf("amount %s SOL is out of range", p.String("amount"))
+		}
+		return NewMarinadeStakingStrategy(name, d.Solana, lamports.Uint64(), d.Marinade, d.Costs), nil
+	})
 }
 
 // NewMarinadeStakingStrategy creates a new MarinadeStakingStrategy.
-func NewMarinadeStakingStrategy(solanaClient *solana.Client, amount uint64) *MarinadeStakingStrategy {
+func NewMarinadeStakingStrategy(name string, solanaClient *solana.Client, amount uint64, apy *yield.Marinade, costs yield.Costs) *MarinadeStakingStrategy {
+	if apy == nil {
+		apy = &yield.Marinade{}
+	}
 	return &MarinadeStakingStrategy{
+		name:         name,
 		solanaClient: solanaClient,
 		amount:       amount,
+		apy:          apy,
+		costs:        costs,
 	}
 }
 
 func (s *MarinadeStakingStrategy) Name() string {
-	return "MarinadeStaking"
+	return s.name
 }
 
-func (s *MarinadeStakingStrategy) Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error {
-	fmt.Printf("Executing Marinade staking strategy with amount %d...\n", s.amount)
+// Chain holds the strategy's capital on Solana.
+func (s *MarinadeStakingStrategy) Chain() chain.ID {
+	return chain.Solana
+}
 
-	programID, err := solana.PublicKeyFromBase58(MarinadeFinanceProgramID)
+// Schedule stakes a single time.
+func (s *MarinadeStakingStrategy) Schedule() schedule.Schedule {
+	return schedule.Once()
+}
+
+// Plan stakes the configured amount of SOL.
+func (s *MarinadeStakingStrategy) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	return []action.Action{{
+		Kind:      action.Stake,
+		Chain:     chain.Solana,
+		Protocol:  "marinade",
+		Asset:     "SOL",
+		Amount:    new(big.Int).SetUint64(s.amount),
+		Decimals:  9,
+		Target:    MarinadeFinanceProgramID,
+		Rationale: "stake SOL for mSOL",
+	}}, nil
+}
+
+// marinadeRisk scores staking with Marinade: mSOL is backed by stake
+// spread across many validators, so the main risks are the contracts and
+// slashing.
+const marinadeRisk = 0.1
+
+// Yield reports Marinade's staking APY, net of costs.
+func (s *MarinadeStakingStrategy) Yield(ctx context.Context) (Yield, error) {
+	opportunities, err := s.apy.Opportunities(ctx)
+	if err != nil {
+		return Yield{}, err
+	}
+	best, err := yield.Best(opportunities, s.costs)
+	if err != nil {
+		return Yield{}, err
+	}
+	return Yield{APY: best.NetAPY, Risk: marinadeRisk}, nil
+}
+
+// Apply stakes SOL with Marinade.
+func (s *MarinadeStakingStrategy) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	if a.Kind != action.Stake {
+		return unsupported(s, a)
+	}
+	sol, err := keys.Solana()
 	if err != nil {
 		return err
 	}
+	if !a.Amount.IsUint64() {
+		return errkind.Wrapf(errkind.Permanent, "amount %s does not fit in a u64", a.Amount)
+	}
+	amount := a.Amount.Uint64()
+
+	programID, err := solana.PublicKeyFromBase58(MarinadeFinanceProgramID)
+	if err != nil {
+		return errkind.Wrap(errkind.Permanent, err)
+	}
 
 	mSOLMint, err := solana.PublicKeyFromBase58(mSOLMintAddress)
 	if err != nil {
-		return err
+		return errkind.Wrap(errkind.Permanent, err)
 	}
 
 	state, err := s.getMarinadeState(programID)
@@ -54,33 +137,44 @@ func (s *MarinadeStakingStrategy) Execute(w wallet.Wallet, privateKey *ecdsa.Pri
 		return err
 	}
 
-	mSOLTokenAccount, err := s.solanaClient.GetOrCreateAssociatedTokenAccount(w, mSOLMint)
+	Track(ctx, "resolving mSOL token account")
+	mSOLTokenAccount, err := s.solanaClient.GetOrCreateAssociatedTokenAccount(ctx, sol, mSOLMint)
 	if err != nil {
 		return err
 	}
 
-	ix := s.createDepositInstruction(w.PublicKey(), programID, state, mSOLTokenAccount, s.amount)
+	ix := s.createDepositInstruction(sol.PublicKey(), programID, state, mSOLTokenAccount, amount)
 
-	blockhash, err := s.solanaClient.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
+	blockhash, err := s.solanaClient.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
 	if err != nil {
 		return err
 	}
 
-	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, w.PublicKey())
+	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, sol.PublicKey())
 	if err != nil {
 		return err
 	}
 
-	if err := w.SignTransaction(tx); err != nil {
+	if err := sol.SignTransaction(tx); err != nil {
 		return err
 	}
 
-	sig, err := s.solanaClient.SendTransaction(context.Background(), tx)
-	if err != nil {
+	Track(ctx, "sending stake transaction")
+	if _, err := s.solanaClient.SendAndConfirm(ctx, tx, fmt.Sprintf("Marinade stake of %d lamports", amount)); err != nil {
 		return err
 	}
 
-	return s.solanaClient.ConfirmTransaction(context.Background(), sig, rpc.CommitmentFinalized)
+	// The position tracks the SOL staked; it is held as mSOL.
+	recordEntry(ctx, store.EntryStake, "stake", a)
+	adjustPosition(ctx, store.Position{
+		ID:       "stake",
+		Chain:    a.Chain,
+		Protocol: a.Protocol,
+		Asset:    a.Asset,
+		Decimals: a.Decimals,
+		Details:  map[string]string{"token": mSOLMintAddress, "token_account": mSOLTokenAccount.String()},
+	}, a.Amount)
+	return nil
 }
 
 func (s *MarinadeStakingStrategy) getMarinadeState(programID solana.PublicKey) (*solana.PublicKey, error) {
@@ -0,0 +1,198 @@
+package strategy
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/store"
+	"github.com/sheawinkler/farmer-shea/yield"
+)
+
+// withPositions returns a context carrying a run of the named strategy
+// whose store holds positions.
+func withPositions(t *testing.T, strategy string, positions ...store.Position) context.Context {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	for _, p := range positions {
+		p.Strategy = strategy
+		if err := st.PutPosition(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store.WithRun(context.Background(), st, store.Run{ID: 1, Strategy: strategy})
+}
+
+func TestVaultPlan(t *testing.T) {
+	s := &simpleVaultDepositStrategy{name: "vaults", stopLoss: 0.05}
+	amount := big.NewInt(100_000_000) // 100 USDC
+	held := store.Position{ID: "0xvault1", Chain: chain.Hyperliquid, Protocol: "hyperliquid-vaults", Asset: "USDC", Amount: "250000000", Decimals: usdcDecimals}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		netAPY float64
+		want   []action.Action
+	}{{
+		name:   "deposit above the stop-loss",
+		ctx:    context.Background(),
+		netAPY: 0.12,
+		want:   []action.Action{{Kind: action.VaultDeposit, Asset: "USDC", Amount: amount, Target: "0xbest"}},
+	}, {
+		name:   "deposit at the stop-loss",
+		ctx:    withPositions(t, "vaults", held),
+		netAPY: 0.05,
+		want:   []action.Action{{Kind: action.VaultDeposit, Asset: "USDC", Amount: amount, Target: "0xbest"}},
+	}, {
+		name:   "withdraw recorded positions below the stop-loss",
+		ctx:    withPositions(t, "vaults", held),
+		netAPY: 0.01,
+		want:   []action.Action{{Kind: action.VaultWithdraw, Asset: "USDC", Amount: big.NewInt(250_000_000), Target: "0xvault1"}},
+	}, {
+		name:   "nothing left to withdraw",
+		ctx:    withPositions(t, "vaults"),
+		netAPY: 0.01,
+	}, {
+		name:   "withdraw the configured amount without a store",
+		ctx:    context.Background(),
+		netAPY: 0.01,
+		want:   []action.Action{{Kind: action.VaultWithdraw, Asset: "USDC", Amount: amount, Target: "0xbest"}},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			best := yield.Ranked{Opportunity: yield.Opportunity{ID: "0xbest", APY: tc.netAPY + 0.01}, NetAPY: tc.netAPY}
+			got, err := s.planVault(tc.ctx, best, amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("planVault() = %v, want %v", got, tc.want)
+			}
+			for i, w := range tc.want {
+				g := got[i]
+				if g.Kind != w.Kind || g.Asset != w.Asset || g.Amount.Cmp(w.Amount) != 0 || g.Target != w.Target ||
+					g.Chain != chain.Hyperliquid || g.Decimals != usdcDecimals || g.Rationale == "" {
+					t.Errorf("action %d = %v, want %v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestCrossoverSignal(t *testing.T) {
+	for _, tc := range []struct {
+		short, long float64
+		want        string
+	}{
+		{2010, 2000, "buy"},
+		{1990, 2000, "sell"},
+		{2000, 2000, ""},
+		{0.0001, 0, "buy"},
+	} {
+		if got := crossoverSignal(tc.short, tc.long); got != tc.want {
+			t.Errorf("crossoverSignal(%v, %v) = %q, want %q", tc.short, tc.long, got, tc.want)
+		}
+	}
+}
+
+func TestUniswapMintAction(t *testing.T) {
+	// WETH sorts before USDC on Base.
+	weth := common.HexToAddress("0x4200000000000000000000000000000000000006")
+	usdc := common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+	wethLeg := lpLeg{weth, big.NewInt(50_000_000_000_000_000), 18} // 0.05 WETH
+	usdcLeg := lpLeg{usdc, big.NewInt(100_000_000), 6}             // 100 USDC
+
+	for _, tc := range []struct {
+		name         string
+		tokenA       common.Address
+		legA, legB   lpLeg
+		token0       lpLeg
+		token1       lpLeg
+		wantDecimals string
+	}{
+		{"sorted", weth, wethLeg, usdcLeg, wethLeg, usdcLeg, "6"},
+		{"reversed", usdc, usdcLeg, wethLeg, wethLeg, usdcLeg, "6"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &uniswapV3LPStrategy{tokenA: tc.legA.token, tokenB: tc.legB.token, fee: big.NewInt(500)}
+			a := s.mintAction(tc.legA, tc.legB, big.NewInt(-100), big.NewInt(100))
+			if a.Kind != action.MintLP || a.Chain != chain.Base || a.Protocol != "uniswap-v3" {
+				t.Errorf("action = %v, want a Uniswap V3 mint on base", a)
+			}
+			if a.Asset != tc.token0.token.Hex() || a.Amount.Cmp(tc.token0.amount) != 0 || a.Decimals != tc.token0.decimals {
+				t.Errorf("token0 = %s %s (%d decimals), want %s of %s", a.Amount, a.Asset, a.Decimals, tc.token0.amount, tc.token0.token.Hex())
+			}
+			if a.Params[action.ParamTokenB] != tc.token1.token.Hex() || a.Params[action.ParamAmountB] != tc.token1.amount.String() || a.Params[action.ParamDecimalsB] != tc.wantDecimals {
+				t.Errorf("token1 params = %v, want %s of %s", a.Params, tc.token1.amount, tc.token1.token.Hex())
+			}
+			if a.Params["fee"] != "500" || a.Params["tick_lower"] != "-100" || a.Params["tick_upper"] != "100" {
+				t.Errorf("params = %v, want fee 500 and ticks [-100, 100]", a.Params)
+			}
+			legs, err := a.Legs()
+			if err != nil || len(legs) != 2 || legs[0].Units() != 0.05 || legs[1].Units() != 100 {
+				t.Errorf("Legs() = %v, %v, want 0.05 WETH and 100 USDC", legs, err)
+			}
+		})
+	}
+}
+
+func TestUniswapOpenPosition(t *testing.T) {
+	weth := common.HexToAddress("0x4200000000000000000000000000000000000006")
+	usdc := common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913")
+	s := &uniswapV3LPStrategy{name: "lp", tokenA: usdc, tokenB: weth, fee: big.NewInt(500)}
+	position := func(id, asset, tokenB, fee string) store.Position {
+		return store.Position{ID: id, Chain: chain.Base, Protocol: "uniswap-v3", Asset: asset, Amount: "1", Details: map[string]string{action.ParamTokenB: tokenB, "fee": fee}}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		ctx       context.Context
+		wantID    string
+		wantFound bool
+	}{
+		{"no store", context.Background(), "", false},
+		{"no positions", withPositions(t, "lp"), "", false},
+		{"other fee tier", withPositions(t, "lp", position("1", weth.Hex(), usdc.Hex(), "3000")), "", false},
+		{"sorted", withPositions(t, "lp", position("2", weth.Hex(), usdc.Hex(), "500")), "2", true},
+		{"minted unsorted", withPositions(t, "lp", position("3", usdc.Hex(), weth.Hex(), "500")), "3", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, ok, err := s.openPosition(tc.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.wantFound || p.ID != tc.wantID {
+				t.Errorf("openPosition() = %q, %v, want %q, %v", p.ID, ok, tc.wantID, tc.wantFound)
+			}
+		})
+	}
+}
+
+func TestMarinadePlan(t *testing.T) {
+	for _, lamports := range []uint64{1, 1_500_000_000, 1 << 63} {
+		s := NewMarinadeStakingStrategy("marinade", nil, lamports, nil, yield.Costs{})
+		got, err := s.Plan(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("Plan() = %v, want one stake", got)
+		}
+		a := got[0]
+		if a.Kind != action.Stake || a.Chain != chain.Solana || a.Asset != "SOL" || a.Decimals != 9 ||
+			!a.Amount.IsUint64() || a.Amount.Uint64() != lamports || a.Target != MarinadeFinanceProgramID {
+			t.Errorf("Plan() = %v, want a stake of %d lamports", a, lamports)
+		}
+		if err := a.Validate(); err != nil {
+			t.Errorf("Validate() = %v", err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package strategy
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/events"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/store"
+)
+
+// findPosition returns the open position with the given ID of the strategy
+// running under ctx.
+func findPosition(ctx context.Context, id string) (store.Position, bool) {
+	positions, err := store.Positions(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("position", id).Msg("Failed to read positions")
+		return store.Position{}, false
+	}
+	for _, p := range positions {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return store.Position{}, false
+}
+
+// adjustPosition adds delta, which is negative for a withdrawal, to the
+// amount of position p.ID and records p with the new amount. The position is
+// closed once its amount reaches zero.
+func adjustPosition(ctx context.Context, p store.Position, delta *big.Int) {
+	amount := new(big.Int)
+	if existing, ok := findPosition(ctx, p.ID); ok {
+		amount.SetString(existing.Amount, 10)
+		if p.Details == nil {
+			p.Details = existing.Details
+		}
+	}
+	amount.Add(amount, delta)
+	if amount.Sign() <= 0 {
+		store.ClosePosition(ctx, p.ID)
+		events.Publish(ctx, &events.PositionChanged{Position: p, Closed: true})
+		return
+	}
+	p.Amount = amount.String()
+	savePosition(ctx, p)
+}
+
+// savePosition records p and reports the change.
+func savePosition(ctx context.Context, p store.Position) {
+	store.SavePosition(ctx, p)
+	events.Publish(ctx, &events.PositionChanged{Position: p})
+}
+
+// recordEntry records a ledger entry of the given kind for the funds a moved
+// into or out of position.
+func recordEntry(ctx context.Context, kind store.EntryKind, position string, a action.Action) {
+	pnl.Record(ctx, store.Entry{Kind: kind, Chain: a.Chain, Position: position, Asset: a.Asset, Amount: a.Units()})
+}
+
+// units converts an amount in an asset's smallest unit to whole units.
+func units(amount *big.Int, decimals uint8) float64 {
+	f, _ := new(big.Rat).SetFrac(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)).Float64()
+	return f
+}
@@ -0,0 +1,51 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type progressKey struct{}
+
+// Progress records the most recent stage reported by a running strategy.
+// The executor uses it to report what a strategy was doing when it was
+// interrupted, e.g. whether a transaction had already been submitted.
+type Progress struct {
+	mu    sync.Mutex
+	stage string
+	since time.Time
+}
+
+// NewProgress creates a new Progress.
+func NewProgress() *Progress {
+	return &Progress{stage: "starting", since: time.Now()}
+}
+
+// Stage returns the last reported stage and when it was reported.
+func (p *Progress) Stage() (string, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stage, p.since
+}
+
+func (p *Progress) set(stage string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stage = stage
+	p.since = time.Now()
+}
+
+// WithProgress returns a copy of ctx that carries p.
+func WithProgress(ctx context.Context, p *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// Track reports the current stage of the strategy running under ctx.
+// It is a no-op if ctx carries no Progress.
+func Track(ctx context.Context, format string, args ...interface{}) {
+	if p, ok := ctx.Value(progressKey{}).(*Progress); ok {
+		p.set(fmt.Sprintf(format, args...))
+	}
+}
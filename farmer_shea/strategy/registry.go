@@ -0,0 +1,273 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sheawinkler/farmer-shea/base"
+	"github.com/sheawinkler/farmer-shea/hyperliquid"
+	"github.com/sheawinkler/farmer-shea/oracle"
+	"github.com/sheawinkler/farmer-shea/solana"
+	"github.com/sheawinkler/farmer-shea/sui"
+	"github.com/sheawinkler/farmer-shea/yield"
+	"github.com/spf13/cast"
+)
+
+// ParamType is the type of a strategy parameter.
+type ParamType int
+
+const (
+	String ParamType = iota
+	Int
+	Float
+	// Decimal is a non-negative decimal number kept as a string, e.g.
+	// "0.05", so that amounts are not rounded.
+	Decimal
+	// Address is a hex-encoded EVM address.
+	Address
+	// Addresses is a non-empty list of hex-encoded EVM addresses.
+	Addresses
+)
+
+var decimalPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+func (t ParamType) String() string {
+	switch t {
+	case String:
+		return "string"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Decimal:
+		return "decimal"
+	case Address:
+		return "address"
+	case Addresses:
+		return "address list"
+	}
+	return fmt.Sprintf("ParamType(%d)", int(t))
+}
+
+// Param describes a strategy parameter.
+type Param struct {
+	Name     string
+	Type     ParamType
+	Required bool
+	// Default is used when an optional parameter is not set.
+	Default any
+	// Check, if set, validates the decoded value.
+	Check func(v any) error
+	Doc   string
+}
+
+// OneOf returns a Check that accepts only the given int values.
+func OneOf(values ...int64) func(v any) error {
+	return func(v any) error {
+		for _, allowed := range values {
+			if v == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %v", values)
+	}
+}
+
+// Range returns a Check that accepts float values in [min, max].
+func Range(min, max float64) func(v any) error {
+	return func(v any) error {
+		if f, _ := v.(float64); f < min || f > max {
+			return fmt.Errorf("must be between %g and %g", min, max)
+		}
+		return nil
+	}
+}
+
+// Schema lists the parameters a strategy type accepts.
+type Schema []Param
+
+// Params holds the parameters of a strategy instance. Build ensures that
+// every parameter in the schema is present with its declared type.
+type Params map[string]any
+
+// String returns the named string parameter.
+func (p Params) String(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+
+// Int returns the named int parameter.
+func (p Params) Int(name string) int64 {
+	v, _ := p[name].(int64)
+	return v
+}
+
+// Float returns the named float parameter.
+func (p Params) Float(name string) float64 {
+	v, _ := p[name].(float64)
+	return v
+}
+
+// Strings returns the named list parameter.
+func (p Params) Strings(name string) []string {
+	v, _ := p[name].([]string)
+	return v
+}
+
+// Deps are the clients strategy factories may use.
+type Deps struct {
+	Solana      *solana.Client
+	Base        *base.Client
+	Hyperliquid *hyperliquid.Client
+	Sui         *sui.Client
+	Oracle      oracle.Oracle
+	// Marinade reports Marinade's staking APY.
+	Marinade *yield.Marinade
+	// Costs are the costs strategies rank yield opportunities net of.
+	Costs yield.Costs
+}
+
+// Factory creates a strategy instance from its parameters. Factories must
+// not use their Deps until the strategy runs, since Validate calls them
+// without any.
+type Factory func(name string, p Params, d Deps) (Strategy, error)
+
+type registration struct {
+	schema  Schema
+	factory Factory
+}
+
+var registry = make(map[string]registration)
+
+// Register makes a strategy type available to Build. It panics if typ is
+// already registered, so it should be called from init.
+func Register(typ string, schema Schema, factory Factory) {
+	if _, ok := registry[typ]; ok {
+		panic(fmt.Sprintf("strategy type %q registered twice", typ))
+	}
+	registry[typ] = registration{schema: schema, factory: factory}
+}
+
+// Types returns the registered strategy types in sorted order.
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SchemaOf returns the schema of a registered strategy type.
+func SchemaOf(typ string) (Schema, bool) {
+	r, ok := registry[typ]
+	return r.schema, ok
+}
+
+// Build creates a strategy of type typ named name. raw holds the
+// parameters from config; they are checked against the type's schema and
+// all problems are reported together.
+func Build(typ, name string, raw map[string]any, d Deps) (Strategy, error) {
+	r, ok := registry[typ]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy type %q (known types: %v)", typ, Types())
+	}
+	p, err := r.schema.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	return r.factory(name, p, d)
+}
+
+// Validate checks the parameters of a strategy of type typ without
+// connecting to any chain, reporting all problems together.
+func Validate(typ string, raw map[string]any) error {
+	_, err := Build(typ, typ, raw, Deps{})
+	return err
+}
+
+// decode checks raw against the schema, converts values to their declared
+// types and fills in defaults.
+func (s Schema) decode(raw map[string]any) (Params, error) {
+	var errs []error
+	p := make(Params, len(s))
+	known := make(map[string]bool, len(s))
+	for _, param := range s {
+		known[param.Name] = true
+
+		v, ok := raw[param.Name]
+		if !ok {
+			if param.Required {
+				errs = append(errs, fmt.Errorf("missing required parameter %q", param.Name))
+				continue
+			}
+			v = param.Default
+			if v == nil {
+				continue
+			}
+		}
+
+		decoded, err := param.Type.decode(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q: expected %s, got %v", param.Name, param.Type, v))
+			continue
+		}
+		if param.Check != nil {
+			if err := param.Check(decoded); err != nil {
+				errs = append(errs, fmt.Errorf("parameter %q: %w", param.Name, err))
+				continue
+			}
+		}
+		p[param.Name] = decoded
+	}
+
+	var unknown []string
+	for name := range raw {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("unknown parameter %q", name))
+	}
+	return p, errors.Join(errs...)
+}
+
+func (t ParamType) decode(v any) (any, error) {
+	switch t {
+	case Int:
+		return cast.ToInt64E(v)
+	case Float:
+		return cast.ToFloat64E(v)
+	case Addresses:
+		list, err := cast.ToStringSliceE(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, errors.New("empty address list")
+		}
+		for _, s := range list {
+			if !common.IsHexAddress(s) {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+		}
+		return list, nil
+	}
+
+	s, err := cast.ToStringE(v)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t == Decimal && !decimalPattern.MatchString(s):
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	case t == Address && !common.IsHexAddress(s):
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	return s, nil
+}
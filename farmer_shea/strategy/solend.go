@@ -2,32 +2,63 @@ package strategy
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
+	"math/big"
 	"sort"
 
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/rpc"
 	bin "github.com/gagliardetto/binary"
 
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/signer"
 	"github.com/sheawinkler/farmer-shea/solana"
+	"github.com/sheawinkler/farmer-shea/store"
 	"github.com/sheawinkler/farmer-shea/oracle"
+	"github.com/sheawinkler/farmer-shea/yield"
 )
 
 const (
 	solendProgramID = "So1endDq2YkqhipRh3WViPa8hdiSpxWy6z3Z6tMCpAo"
+	// solendMainMarket is the lending market whose reserves are used.
+	solendMainMarket = "4UpD2fh7xH3VP9QQaXtsS1YY3bxzWhtfpks7FatyKvdY"
+
+	// reserveSize is the size of a reserve account (RESERVE_LEN); other
+	// Solend accounts, e.g. obligations, have other sizes.
+	reserveSize = 619
+	// reserveVersion is the version of the reserve layout decoded.
+	reserveVersion = 1
+	// reserveLendingMarketOffset is the offset of LendingMarket in a
+	// reserve account.
+	reserveLendingMarketOffset = 10
 )
 
 // Solend is a farming strategy for the Solend protocol.	ype Solend struct {
+	name         string
 	solanaClient *solana.Client
 	oracle       oracle.Oracle
-	amount       uint64
+	amount       string
 }
 
-// NewSolend creates a new Solend strategy.
-func NewSolend(solanaClient *solana.Client, oracle oracle.Oracle, amount uint64) *Solend {
+func init() {
+	Register("solend", Schema{
+		{Name: "amount", Type: Decimal, Required: true, Doc: "amount of the reserve's token to supply, e.g. \"100\""},
+	}, func(name string, p Params, d Deps) (Strategy, error) {
+		amount, ok := new(big.Rat).SetString(p.String("amount"))
+		if !ok || amount.Sign() <= 0 {
+			return nil, fmt.Errorf("amount must be positive, got %q", p.String("amount"))
+		}
+		return NewSolend(name, d.Solana, d.Oracle, p.String("amount")), nil
+	})
+}
+
+// NewSolend creates a new Solend strategy that supplies amount, in whole
+// units of the reserve's token, e.g. "100".
+func NewSolend(name string, solanaClient *solana.Client, oracle oracle.Oracle, amount string) *Solend {
 	return &Solend{
+		name:         name,
 		solanaClient: solanaClient,
 		oracle:       oracle,
 		amount:       amount,
@@ -35,94 +66,242 @@ func NewSolend(solanaClient *solana.Client, oracle oracle.Oracle, amount uint64)
 }
 
 func (s *Solend) Name() string {
-	return "Solend"
+	return s.name
+}
+
+// Chain holds the strategy's capital on Solana.
+func (s *Solend) Chain() chain.ID {
+	return chain.Solana
 }
 
-func (s *Solend) Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error {
-	reserves, err := s.getAllReserves()
+// Plan deposits into the best reserve.
+func (s *Solend) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	Track(ctx, "fetching reserves")
+	reserves, err := solendReserves(ctx, s.solanaClient)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	bestReserve, amount, err := s.determineBestReserve(reserves, s.amount)
+	if err != nil {
+		return nil, err
 	}
 
-	bestReserve, err := s.determineBestReserve(reserves)
+	return []action.Action{{
+		Kind:      action.Deposit,
+		Chain:     chain.Solana,
+		Protocol:  "solend",
+		Asset:     bestReserve.Liquidity.MintPubkey.String(),
+		Amount:    new(big.Int).SetUint64(amount),
+		Decimals:  bestReserve.Liquidity.MintDecimals,
+		Rationale: fmt.Sprintf("reserve has the best risk-adjusted supply APY %.2f%% (%.2f%% before risk, utilization %.2f%%)",
+			bestReserve.RiskAdjustedAPY()*100, bestReserve.SupplyAPY()*100, bestReserve.Utilization()*100),
+	}}, nil
+}
+
+// Unwind withdraws everything supplied to the reserves we deposited into.
+func (s *Solend) Unwind(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	return withdrawAll(ctx, action.Withdraw, "unwinding positions")
+}
+
+// Apply deposits into or withdraws from the reserve for the action's mint.
+func (s *Solend) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	sol, err := keys.Solana()
 	if err != nil {
 		return err
 	}
+	mint, err := solana.PublicKeyFromBase58(a.Asset)
+	if err != nil {
+		return errkind.Wrap(errkind.Permanent, err)
+	}
+	if !a.Amount.IsUint64() {
+		return errkind.Wrapf(errkind.Permanent, "amount %s does not fit in a u64", a.Amount)
+	}
 
-	tx, err := s.deposit(s.solanaClient, w, s.amount, bestReserve.Liquidity.MintPubkey)
+	var (
+		tx      *solana.Transaction
+		reserve solana.PublicKey
+	)
+	delta := new(big.Int).Set(a.Amount)
+	kind := store.EntryDeposit
+	switch a.Kind {
+	case action.Deposit:
+		Track(ctx, "building deposit into reserve for mint %s", mint)
+		tx, reserve, err = s.deposit(ctx, s.solanaClient, sol, a.Amount.Uint64(), mint)
+	case action.Withdraw:
+		Track(ctx, "building withdrawal from reserve for mint %s", mint)
+		tx, reserve, err = s.withdraw(ctx, s.solanaClient, sol, a.Amount.Uint64(), mint)
+		delta.Neg(delta)
+		kind = store.EntryWithdraw
+	default:
+		return unsupported(s, a)
+	}
 	if err != nil {
 		return err
 	}
 
-	if err := w.SignTransaction(tx); err != nil {
+	if err := sol.SignTransaction(tx); err != nil {
 		return err
 	}
 
-	sig, err := s.solanaClient.SendTransaction(context.Background(), tx)
-	if err != nil {
+	Track(ctx, "sending %s transaction", a.Kind)
+	if _, err := s.solanaClient.SendAndConfirm(ctx, tx, fmt.Sprintf("Solend %s of %s into reserve for mint %s", a.Kind, a.Amount, mint)); err != nil {
 		return err
 	}
 
-	return s.solanaClient.ConfirmTransaction(context.Background(), sig, rpc.CommitmentFinalized)
+	// The position tracks the liquidity supplied to the reserve; it is held
+	// as the reserve's cTokens.
+	recordEntry(ctx, kind, reserve.String(), a)
+	adjustPosition(ctx, store.Position{
+		ID:       reserve.String(),
+		Chain:    a.Chain,
+		Protocol: a.Protocol,
+		Asset:    a.Asset,
+		Decimals: a.Decimals,
+	}, delta)
+	return nil
 }
 
-func (s *Solend) getAllReserves() ([]Reserve, error) {
-	programID, err := solana.PublicKeyFromBase58(solendProgramID)
+// SolendReserves reports the reserves of the Solend main market as yield
+// opportunities.
+type SolendReserves struct {
+	Client *solana.Client
+}
+
+// Name implements yield.Source.
+func (*SolendReserves) Name() string { return "solend" }
+
+// Opportunities implements yield.Source.
+func (r *SolendReserves) Opportunities(ctx context.Context) ([]yield.Opportunity, error) {
+	reserves, err := solendReserves(ctx, r.Client)
 	if err != nil {
 		return nil, err
 	}
+	opportunities := make([]yield.Opportunity, len(reserves))
+	for i, reserve := range reserves {
+		mint := reserve.Liquidity.MintPubkey.String()
+		opportunities[i] = yield.Opportunity{
+			Protocol:    "solend",
+			Chain:       chain.Solana,
+			Asset:       mint,
+			ID:          mint,
+			APY:         reserve.SupplyAPY(),
+			TVL:         reserve.TVL(),
+			Utilization: reserve.Utilization(),
+		}
+	}
+	return opportunities, nil
+}
 
-	accounts, err := s.solanaClient.GetProgramAccounts(programID.String())
+// reserveAccount is a decoded reserve account.
+type reserveAccount struct {
+	Pubkey  solana.PublicKey
+	Reserve Reserve
+}
+
+// fetchReserves fetches and decodes the reserve accounts of the Solend main
+// market.
+func fetchReserves(ctx context.Context, client *solana.Client) ([]reserveAccount, error) {
+	market, err := solana.PublicKeyFromBase58(solendMainMarket)
 	if err != nil {
 		return nil, err
 	}
 
-	var reserves []Reserve
+	accounts, err := client.GetProgramAccounts(ctx, solendProgramID,
+		rpc.RPCFilter{DataSize: reserveSize},
+		rpc.RPCFilter{Memcmp: &rpc.RPCFilterMemcmp{Offset: reserveLendingMarketOffset, Bytes: market.Bytes()}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var reserves []reserveAccount
 	for _, account := range accounts {
+		data := account.Account.Data.GetBinary()
+		if len(data) != reserveSize {
+			continue
+		}
 		var reserve Reserve
-		if err := bin.NewBinDecoder(account.Account.Data).Decode(&reserve); err != nil {
+		if err := bin.NewBinDecoder(data).Decode(&reserve); err != nil {
 			continue
 		}
-		reserves = append(reserves, reserve)
+		// The node applies the filters; check what was decoded anyway.
+		if reserve.Version != reserveVersion || reserve.LendingMarket != market {
+			continue
+		}
+		reserves = append(reserves, reserveAccount{Pubkey: account.Pubkey, Reserve: reserve})
 	}
 
 	return reserves, nil
 }
 
-func (s *Solend) determineBestReserve(reserves []Reserve) (*Reserve, error) {
+// solendReserves fetches and decodes the reserves of the Solend main market.
+func solendReserves(ctx context.Context, client *solana.Client) ([]Reserve, error) {
+	accounts, err := fetchReserves(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	reserves := make([]Reserve, len(accounts))
+	for i, account := range accounts {
+		reserves[i] = account.Reserve
+	}
+	return reserves, nil
+}
+
+// determineBestReserve returns the reserve with the best risk-adjusted
+// supply APY among those with room under their deposit limit for amount,
+// and amount in the smallest unit of that reserve's token. amount is in
+// whole units of each reserve's token, e.g. "100".
+func (s *Solend) determineBestReserve(reserves []Reserve, amount string) (*Reserve, uint64, error) {
 	if len(reserves) == 0 {
-		return nil, fmt.Errorf("no reserves found")
+		return nil, 0, fmt.Errorf("no reserves found")
 	}
 
-	// This is a simplified implementation. A more robust implementation would
-	// involve fetching the APY for each reserve from an oracle and then
-	// selecting the one with the highest APY.
-	sort.Slice(reserves, func(i, j int) bool {
-		return reserves[i].Liquidity.AvailableAmount > reserves[j].Liquidity.AvailableAmount
+	type candidate struct {
+		reserve Reserve
+		amount  uint64
+	}
+	var open []candidate
+	for _, r := range reserves {
+		raw, err := action.ParseAmount(amount, r.Liquidity.MintDecimals)
+		if err != nil || !raw.IsUint64() {
+			// More precise than the token, or too large for it.
+			continue
+		}
+		if r.Headroom() >= raw.Uint64() {
+			open = append(open, candidate{r, raw.Uint64()})
+		}
+	}
+	if len(open) == 0 {
+		return nil, 0, errkind.Wrapf(errkind.Transient, "no reserve has room under its deposit limit for %s", amount)
+	}
+
+	sort.Slice(open, func(i, j int) bool {
+		return open[i].reserve.RiskAdjustedAPY() > open[j].reserve.RiskAdjustedAPY()
 	})
 
-	return &reserves[0], nil
+	return &open[0].reserve, open[0].amount, nil
 }
 
-func (s *Solend) deposit(client *solana.Client, w wallet.Wallet, amount uint64, tokenMint solana.PublicKey) (*solana.Transaction, error) {
+func (s *Solend) deposit(ctx context.Context, client *solana.Client, sol *signer.Solana, amount uint64, tokenMint solana.PublicKey) (*solana.Transaction, solana.PublicKey, error) {
 	programID, err := solana.PublicKeyFromBase58(solendProgramID)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	reservePubkey, reserve, err := s.findReserveAccount(client, tokenMint)
+	reservePubkey, reserve, err := s.findReserveAccount(ctx, client, tokenMint)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	userTokenAccount, err := client.GetOrCreateAssociatedTokenAccount(w, tokenMint)
+	userTokenAccount, err := client.GetOrCreateAssociatedTokenAccount(ctx, sol, tokenMint)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	userCollateralAccount, err := client.GetOrCreateAssociatedTokenAccount(w, reserve.Collateral.MintPubkey)
+	userCollateralAccount, err := client.GetOrCreateAssociatedTokenAccount(ctx, sol, reserve.Collateral.MintPubkey)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
 	lendingMarketAuthority, _, err := solana.FindProgramAddress(
@@ -130,7 +309,7 @@ func (s *Solend) deposit(client *solana.Client, w wallet.Wallet, amount uint64,
 		programID,
 	)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
 	ix := solana.NewInstruction(
@@ -143,44 +322,44 @@ func (s *Solend) deposit(client *solana.Client, w wallet.Wallet, amount uint64,
 			{PublicKey: reserve.Collateral.MintPubkey, IsSigner: false, IsWritable: true},
 			{PublicKey: reserve.LendingMarket, IsSigner: false, IsWritable: false},
 			{PublicKey: lendingMarketAuthority, IsSigner: false, IsWritable: false},
-			{PublicKey: w.PublicKey(), IsSigner: true, IsWritable: false},
+			{PublicKey: sol.PublicKey(), IsSigner: true, IsWritable: false},
 			{PublicKey: solana.TokenProgramID, IsSigner: false, IsWritable: false},
 		},
 		append([]byte{1}, new(bin.Buffer).WriteUint64(amount, bin.LE).Bytes()...),
 	)
 
-	blockhash, err := client.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
+	blockhash, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, w.PublicKey())
+	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, sol.PublicKey())
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	return tx, nil
+	return tx, *reservePubkey, nil
 }
 
-func (s *Solend) withdraw(client *solana.Client, w wallet.Wallet, amount uint64, tokenMint solana.PublicKey) (*solana.Transaction, error) {
+func (s *Solend) withdraw(ctx context.Context, client *solana.Client, sol *signer.Solana, amount uint64, tokenMint solana.PublicKey) (*solana.Transaction, solana.PublicKey, error) {
 	programID, err := solana.PublicKeyFromBase58(solendProgramID)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	reservePubkey, reserve, err := s.findReserveAccount(client, tokenMint)
+	reservePubkey, reserve, err := s.findReserveAccount(ctx, client, tokenMint)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	userTokenAccount, err := client.GetOrCreateAssociatedTokenAccount(w, tokenMint)
+	userTokenAccount, err := client.GetOrCreateAssociatedTokenAccount(ctx, sol, tokenMint)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	userCollateralAccount, err := client.GetOrCreateAssociatedTokenAccount(w, reserve.Collateral.MintPubkey)
+	userCollateralAccount, err := client.GetOrCreateAssociatedTokenAccount(ctx, sol, reserve.Collateral.MintPubkey)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
 	lendingMarketAuthority, _, err := solana.FindProgramAddress(
@@ -188,7 +367,7 @@ func (s *Solend) withdraw(client *solana.Client, w wallet.Wallet, amount uint64,
 		programID,
 	)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
 	ix := solana.NewInstruction(
@@ -200,44 +379,34 @@ func (s *Solend) withdraw(client *solana.Client, w wallet.Wallet, amount uint64,
 			{PublicKey: reserve.Collateral.SupplyPubkey, IsSigner: false, IsWritable: true},
 			{PublicKey: reserve.LendingMarket, IsSigner: false, IsWritable: false},
 			{PublicKey: lendingMarketAuthority, IsSigner: false, IsWritable: false},
-			{PublicKey: w.PublicKey(), IsSigner: true, IsWritable: false},
+			{PublicKey: sol.PublicKey(), IsSigner: true, IsWritable: false},
 			{PublicKey: solana.TokenProgramID, IsSigner: false, IsWritable: false},
 		},
 		append([]byte{2}, new(bin.Buffer).WriteUint64(amount, bin.LE).Bytes()...),
 	)
 
-	blockhash, err := client.GetLatestBlockhash(context.Background(), rpc.CommitmentFinalized)
+	blockhash, err := client.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, w.PublicKey())
+	tx, err := solana.NewTransaction([]solana.Instruction{ix}, blockhash.Value.Blockhash, sol.PublicKey())
 	if err != nil {
-		return nil, err
+		return nil, solana.PublicKey{}, err
 	}
 
-	return tx, nil
+	return tx, *reservePubkey, nil
 }
 
-func (s *Solend) findReserveAccount(client *solana.Client, tokenMint solana.PublicKey) (*solana.PublicKey, *Reserve, error) {
-	programID, err := solana.PublicKeyFromBase58(solendProgramID)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	accounts, err := client.GetProgramAccounts(programID.String())
+func (s *Solend) findReserveAccount(ctx context.Context, client *solana.Client, tokenMint solana.PublicKey) (*solana.PublicKey, *Reserve, error) {
+	accounts, err := fetchReserves(ctx, client)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	for _, account := range accounts {
-		var reserve Reserve
-		if err := bin.NewBinDecoder(account.Account.Data).Decode(&reserve); err != nil {
-			continue
-		}
-
-		if reserve.Liquidity.MintPubkey == tokenMint {
-			return &account.Pubkey, &reserve, nil
+		if account.Reserve.Liquidity.MintPubkey == tokenMint {
+			return &account.Pubkey, &account.Reserve, nil
 		}
 	}
 
@@ -251,13 +420,15 @@ func (s *Solend) findReserveAccount(client *solana.Client, tokenMint solana.Publ
 	Liquidity             ReserveLiquidity
 	Collateral            ReserveCollateral
 	Config                ReserveConfig
-	Padding               [248]byte
+	// AccumulatedProtocolFeesWads belongs to the liquidity but is laid out
+	// after the config.
+	AccumulatedProtocolFeesWads bin.Uint128
+	Padding               [230]byte
 }
 
 // LastUpdate is the structure of the LastUpdate field in a Reserve account.	ype LastUpdate struct {
 	Slot    uint64
 	Stale   bool
-	Padding [7]byte
 }
 
 // ReserveLiquidity is the structure of the Liquidity field in a Reserve account.	ype ReserveLiquidity struct {
@@ -267,9 +438,9 @@ func (s *Solend) findReserveAccount(client *solana.Client, tokenMint solana.Publ
 	PythOraclePubkey      solana.PublicKey
 	SwitchboardOraclePubkey solana.PublicKey
 	AvailableAmount       uint64
-	BorrowedAmountWads    solana.U128
-	CumulativeBorrowRateWads solana.U128
-	MarketPrice           solana.U128
+	BorrowedAmountWads    bin.Uint128
+	CumulativeBorrowRateWads bin.Uint128
+	MarketPrice           bin.Uint128
 }
 
 // ReserveCollateral is the structure of the Collateral field in a Reserve account.	ype ReserveCollateral struct {
@@ -298,5 +469,4 @@ func (s *Solend) findReserveAccount(client *solana.Client, tokenMint solana.Publ
 	BorrowFeeWad        uint64
 	FlashLoanFeeWad     uint64
 	HostFeePercentage   uint8
-	Padding             [7]byte
 }
@@ -0,0 +1,103 @@
+package strategy
+
+import (
+	"math"
+	"math/big"
+
+	bin "github.com/gagliardetto/binary"
+)
+
+const (
+	// solendSlotsPerYear is the number of slots the Solend program assumes
+	// in a year when it compounds interest every slot.
+	solendSlotsPerYear = 63072000
+	// wad is the scale of Solend's fixed-point decimals.
+	wad = 1e18
+)
+
+// wads converts a fixed-point decimal scaled by wad to a float.
+func wads(v bin.Uint128) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(v.BigInt()), big.NewFloat(wad)).Float64()
+	return f
+}
+
+// borrowed is the amount lent out of the reserve, in the smallest unit of
+// its mint.
+func (r *Reserve) borrowed() float64 {
+	return wads(r.Liquidity.BorrowedAmountWads)
+}
+
+// supplied is the amount deposited into the reserve, lent out or not, in
+// the smallest unit of its mint.
+func (r *Reserve) supplied() float64 {
+	return float64(r.Liquidity.AvailableAmount) + r.borrowed()
+}
+
+// Utilization is the share of the reserve's deposits that is lent out.
+func (r *Reserve) Utilization() float64 {
+	supplied := r.supplied()
+	if supplied == 0 {
+		return 0
+	}
+	return r.borrowed() / supplied
+}
+
+// BorrowAPR is the annual borrow rate at the reserve's utilization. It
+// follows the reserve's rate curve: linear from the min to the optimal rate
+// up to the optimal utilization, and from the optimal to the max rate past
+// it.
+func (r *Reserve) BorrowAPR() float64 {
+	c := r.Config
+	util := r.Utilization()
+	optimalUtil := float64(c.OptimalUtilizationRate) / 100
+	minRate := float64(c.MinBorrowRate) / 100
+	optimalRate := float64(c.OptimalBorrowRate) / 100
+	maxRate := float64(c.MaxBorrowRate) / 100
+
+	if optimalUtil == 1 || util < optimalUtil {
+		if optimalUtil == 0 {
+			return minRate
+		}
+		return minRate + util/optimalUtil*(optimalRate-minRate)
+	}
+	return optimalRate + (util-optimalUtil)/(1-optimalUtil)*(maxRate-optimalRate)
+}
+
+// SupplyAPY is the annual yield of deposits: the borrow rate compounded
+// every slot, earned on the share of deposits lent out, less the
+// protocol's take.
+func (r *Reserve) SupplyAPY() float64 {
+	borrowAPY := math.Pow(1+r.BorrowAPR()/solendSlotsPerYear, solendSlotsPerYear) - 1
+	return borrowAPY * r.Utilization() * (1 - float64(r.Config.ProtocolTakeRate)/100)
+}
+
+// RiskAdjustedAPY discounts the supply APY by the reserve's risks. Assets
+// the market lends little against are riskier to hold, so half the share
+// of their value it will not lend against is taken off. Utilization past
+// the optimal rate means withdrawals may have to wait for repayments, so
+// half of how far it is toward full is taken off too.
+func (r *Reserve) RiskAdjustedAPY() float64 {
+	collateralRisk := 1 - float64(r.Config.LoanToValueRatio)/100
+	liquidityRisk := 0.0
+	if optimal := float64(r.Config.OptimalUtilizationRate) / 100; optimal < 1 {
+		liquidityRisk = max(r.Utilization()-optimal, 0) / (1 - optimal)
+	}
+	return r.SupplyAPY() * (1 - collateralRisk/2) * (1 - liquidityRisk/2)
+}
+
+// Headroom is how much more the reserve accepts before reaching its
+// deposit limit, in the smallest unit of its mint.
+func (r *Reserve) Headroom() uint64 {
+	supplied := r.supplied()
+	if supplied >= float64(r.Config.DepositLimit) {
+		return 0
+	}
+	return r.Config.DepositLimit - uint64(math.Ceil(supplied))
+}
+
+// TVL is the value deposited into the reserve in USD, at the market price
+// it records.
+func (r *Reserve) TVL() float64 {
+	tokens := r.supplied() / math.Pow10(int(r.Liquidity.MintDecimals))
+	return tokens * wads(r.Liquidity.MarketPrice)
+}
@@ -1,13 +1,56 @@
 package strategy
 
 import (
-	"crypto/ecdsa"
+	"context"
 
-	"github.com/sheawinkler/farmer-shea/wallet"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/schedule"
+	"github.com/sheawinkler/farmer-shea/signer"
 )
 
 // Strategy defines the interface for all trading strategies.
+//
+// Strategies are split into a decision step and a submission step. Plan
+// decides what to do and may read chain state, but must not submit
+// transactions. Apply submits a single action returned by Plan. Both must
+// return promptly once ctx is cancelled.
 type Strategy interface {
-	Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error
+	Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error)
+	Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error
 	Name() string
 }
+
+// Scheduled is implemented by strategies that declare their own schedule.
+// Strategies that do not implement it run every schedule.DefaultInterval
+// unless the config assigns them a schedule.
+type Scheduled interface {
+	Schedule() schedule.Schedule
+}
+
+// OnChain is implemented by strategies that hold their capital on a single
+// chain. The capital allocator only moves funds between strategies on the
+// same chain.
+type OnChain interface {
+	Chain() chain.ID
+}
+
+// Yield is the return a strategy expects on the capital it holds.
+type Yield struct {
+	// APY is the expected annual yield as a fraction, e.g. 0.05 for 5%.
+	APY float64
+	// Risk scores the strategy from 0, the safest, to 1.
+	Risk float64
+}
+
+// Yielder is implemented by strategies that report their expected yield,
+// e.g. for the capital allocator to favour the best risk-adjusted return.
+type Yielder interface {
+	Yield(ctx context.Context) (Yield, error)
+}
+
+// unsupported is returned by Apply for actions a strategy does not plan.
+func unsupported(s Strategy, a action.Action) error {
+	return errkind.Wrapf(errkind.Permanent, "%s cannot apply %s actions", s.Name(), a.Kind)
+}
@@ -1,32 +1,50 @@
 package strategy
 
 import (
-	"crypto/ecdsa"
-	"fmt"
+	"context"
 
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/signer"
 	"github.com/sheawinkler/farmer-shea/sui"
-	"github.com/sheawinkler/farmer-shea/wallet"
 )
 
 // --- Sui Placeholder Strategy ---
 
 type suiPlaceholderStrategy struct {
+	name      string
 	suiClient *sui.Client
 }
 
-func NewSuiPlaceholderStrategy(client *sui.Client) Strategy {
-	return &suiPlaceholderStrategy{suiClient: client}
+func init() {
+	Register("sui_placeholder", nil, func(name string, p Params, d Deps) (Strategy, error) {
+		return NewSuiPlaceholderStrategy(name, d.Sui), nil
+	})
+}
+
+func NewSuiPlaceholderStrategy(name string, client *sui.Client) Strategy {
+	return &suiPlaceholderStrategy{name: name, suiClient: client}
 }
 
 func (s *suiPlaceholderStrategy) Name() string {
-	return "SuiPlaceholder"
+	return s.name
+}
+
+// Chain holds the strategy's capital on Sui.
+func (s *suiPlaceholderStrategy) Chain() chain.ID {
+	return chain.Sui
 }
 
-func (s *suiPlaceholderStrategy) Execute(w wallet.Wallet, privateKey *ecdsa.PrivateKey) error {
-	fmt.Println("Executing placeholder strategy on Sui...")
+func (s *suiPlaceholderStrategy) Plan(ctx context.Context, keys *signer.Keyring) ([]action.Action, error) {
+	log.Debug().Str("strategy", s.name).Msg("Sui placeholder strategy has nothing to do")
 	// This is a placeholder. A real implementation would involve:
 	// 1. Getting a pool address.
 	// 2. Approving the router to spend tokens.
 	// 3. Adding liquidity to the pool.
-	return nil
+	return nil, nil
+}
+
+func (s *suiPlaceholderStrategy) Apply(ctx context.Context, keys *signer.Keyring, a action.Action) error {
+	return unsupported(s, a)
 }
@@ -0,0 +1,58 @@
+package strategy
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/signer"
+	"github.com/sheawinkler/farmer-shea/store"
+)
+
+// Unwinder is implemented by strategies that can exit their positions,
+// e.g. when the kill switch is engaged. Like Plan, Unwind only decides what
+// to do; Apply submits the actions it returns.
+type Unwinder interface {
+	Unwind(ctx context.Context, keys *signer.Keyring) ([]action.Action, error)
+}
+
+type unwindKey struct{}
+
+// WithUnwind marks ctx as belonging to a run that unwinds positions.
+func WithUnwind(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unwindKey{}, true)
+}
+
+// Unwinding reports whether ctx belongs to a run that unwinds positions.
+func Unwinding(ctx context.Context) bool {
+	unwinding, _ := ctx.Value(unwindKey{}).(bool)
+	return unwinding
+}
+
+// withdrawAll returns an action of the given kind withdrawing everything
+// held in each recorded position of the strategy running under ctx.
+func withdrawAll(ctx context.Context, kind action.Kind, rationale string) ([]action.Action, error) {
+	positions, err := store.Positions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var actions []action.Action
+	for _, p := range positions {
+		held, ok := new(big.Int).SetString(p.Amount, 10)
+		if !ok {
+			return nil, errkind.Wrapf(errkind.Permanent, "recorded position %s has malformed amount %q", p.ID, p.Amount)
+		}
+		actions = append(actions, action.Action{
+			Kind:      kind,
+			Chain:     p.Chain,
+			Protocol:  p.Protocol,
+			Asset:     p.Asset,
+			Amount:    held,
+			Decimals:  p.Decimals,
+			Target:    p.ID,
+			Rationale: rationale,
+		})
+	}
+	return actions, nil
+}
@@ -0,0 +1,101 @@
+package sui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/metrics"
+)
+
+// httpClient sends the JSON-RPC requests made directly, recording them in
+// the RPC metrics.
+var httpClient = metrics.HTTPClient(chain.Sui)
+
+// CoinBalance is the total balance of one coin type owned by an address.
+type CoinBalance struct {
+	CoinType string
+	Symbol   string
+	Amount   *big.Int
+	Decimals uint8
+}
+
+// GetBalances returns the balance of every coin type owner holds.
+func (c *Client) GetBalances(ctx context.Context, owner string) ([]CoinBalance, error) {
+	var totals []struct {
+		CoinType     string `json:"coinType"`
+		TotalBalance string `json:"totalBalance"`
+	}
+	if err := c.call(ctx, "suix_getAllBalances", []any{owner}, &totals); err != nil {
+		return nil, err
+	}
+
+	var balances []CoinBalance
+	for _, t := range totals {
+		amount, ok := new(big.Int).SetString(t.TotalBalance, 10)
+		if !ok {
+			return nil, fmt.Errorf("coin %s has malformed balance %q", t.CoinType, t.TotalBalance)
+		}
+		if amount.Sign() == 0 {
+			continue
+		}
+		var meta struct {
+			Symbol   string `json:"symbol"`
+			Decimals uint8  `json:"decimals"`
+		}
+		if err := c.call(ctx, "suix_getCoinMetadata", []any{t.CoinType}, &meta); err != nil {
+			return nil, fmt.Errorf("failed to get metadata of coin %s: %w", t.CoinType, err)
+		}
+		balances = append(balances, CoinBalance{CoinType: t.CoinType, Symbol: meta.Symbol, Amount: amount, Decimals: meta.Decimals})
+	}
+	return balances, nil
+}
+
+// call sends a JSON-RPC request and decodes its result into out.
+func (c *Client) call(ctx context.Context, method string, params []any, out any) error {
+	data, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.rpcURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errkind.Annotate(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errkind.Wrap(errkind.Transient, err)
+	}
+	if resp.StatusCode >= 400 {
+		err := fmt.Errorf("sui %s: %s: %s", method, resp.Status, bytes.TrimSpace(body))
+		return errkind.FromHTTPStatus(resp.StatusCode, resp.Header.Get("Retry-After"), err)
+	}
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to decode sui %s response: %w", method, err)
+	}
+	if result.Error != nil {
+		return fmt.Errorf("sui %s: %s (code %d)", method, result.Error.Message, result.Error.Code)
+	}
+	return json.Unmarshal(result.Result, out)
+}
@@ -10,6 +10,7 @@ import (
 // Client is a Sui client.
 type Client struct {
 	*client.Client
+	rpcURL string
 }
 
 // NewClient creates a new Sui client.
@@ -18,7 +19,7 @@ func NewClient(rpcEndpoint string) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{c}, nil
+	return &Client{Client: c, rpcURL: rpcEndpoint}, nil
 }
 
 // GetLatestBlockHeight gets the latest block height of the Sui blockchain.
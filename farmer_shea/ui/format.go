@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// assetDecimals are the decimals shown for the amounts of well-known assets.
+// Other assets get decimals by magnitude.
+var assetDecimals = map[string]int{
+	"BTC":   8,
+	"WBTC":  8,
+	"cbBTC": 8,
+	"ETH":   6,
+	"WETH":  6,
+	"SOL":   4,
+	"mSOL":  4,
+	"SUI":   4,
+	"HYPE":  4,
+	"USDC":  2,
+	"USDT":  2,
+}
+
+// minSignificant is the number of significant digits an amount keeps
+// however small it is, so that e.g. 0.0004 is not shown as 0.00.
+const minSignificant = 2
+
+// formatAmount formats an amount of asset with the asset's decimals.
+func formatAmount(asset string, v float64) string {
+	decimals, ok := assetDecimals[asset]
+	if !ok {
+		switch abs := math.Abs(v); {
+		case abs >= 1000:
+			decimals = 2
+		case abs >= 1:
+			decimals = 4
+		default:
+			decimals = 6
+		}
+	}
+	if abs := math.Abs(v); abs > 0 && abs < math.Pow10(-decimals+minSignificant-1) {
+		decimals = int(-math.Floor(math.Log10(abs))) + minSignificant - 1
+	}
+	return groupThousands(strconv.FormatFloat(v, 'f', decimals, 64))
+}
+
+// formatUSD formats a USD value with cents and thousands separators.
+func formatUSD(v float64) string {
+	s := "$" + groupThousands(strconv.FormatFloat(math.Abs(v), 'f', 2, 64))
+	if v < 0 && s != "$0.00" {
+		return "-" + s
+	}
+	return s
+}
+
+// formatSignedUSD is like formatUSD but always shows the sign, for PnL.
+func formatSignedUSD(v float64) string {
+	s := formatUSD(v)
+	if !strings.HasPrefix(s, "-") {
+		return "+" + s
+	}
+	return s
+}
+
+// formatPercent formats a fraction as a percentage.
+func formatPercent(f float64) string {
+	return strconv.FormatFloat(f*100, 'f', 1, 64) + "%"
+}
+
+// pnlColor is the color of a PnL value: green for gains and red for losses.
+func pnlColor(v float64) tcell.Color {
+	switch {
+	case math.Round(v*100) > 0:
+		return tcell.ColorGreen
+	case math.Round(v*100) < 0:
+		return tcell.ColorRed
+	default:
+		return tcell.ColorDefault
+	}
+}
+
+// pnlTag is pnlColor as a tview color tag.
+func pnlTag(v float64) string {
+	switch pnlColor(v) {
+	case tcell.ColorGreen:
+		return "[green]"
+	case tcell.ColorRed:
+		return "[red]"
+	default:
+		return "[-]"
+	}
+}
+
+// groupThousands adds thousands separators to the integer part of a
+// formatted number.
+func groupThousands(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if hasFraction {
+		b.WriteString("." + fraction)
+	}
+	return sign + b.String()
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values as a line of block characters scaled between
+// their minimum and maximum.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
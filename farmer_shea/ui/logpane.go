@@ -0,0 +1,329 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rs/zerolog"
+)
+
+// maxLogEntries bounds the log history kept for scroll-back and filtering.
+const maxLogEntries = 5000
+
+// logLevels are the minimum levels the log pane cycles through.
+var logLevels = []zerolog.Level{zerolog.TraceLevel, zerolog.DebugLevel, zerolog.InfoLevel, zerolog.WarnLevel, zerolog.ErrorLevel}
+
+// logEntry is a log record as shown in the log pane.
+type logEntry struct {
+	time     time.Time
+	level    zerolog.Level
+	strategy string
+	message  string
+	// fields are the record's other fields as sorted key=value pairs.
+	fields string
+}
+
+// text is the entry as plain text, for search.
+func (e logEntry) text() string {
+	return strings.ToLower(e.strategy + " " + e.message + " " + e.fields)
+}
+
+// line formats the entry with tview color tags.
+func (e logEntry) line() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[gray]%s[-] [%s]%-5s[-] ", e.time.Format("15:04:05"), levelColor(e.level), strings.ToUpper(e.level.String()))
+	if e.strategy != "" {
+		fmt.Fprintf(&b, "[::b]%s[::-] ", tview.Escape(e.strategy))
+	}
+	b.WriteString(tview.Escape(e.message))
+	if e.fields != "" {
+		fmt.Fprintf(&b, " [gray]%s[-]", tview.Escape(e.fields))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func levelColor(l zerolog.Level) string {
+	switch {
+	case l >= zerolog.ErrorLevel:
+		return "red"
+	case l == zerolog.WarnLevel:
+		return "yellow"
+	case l == zerolog.InfoLevel:
+		return "green"
+	default:
+		return "gray"
+	}
+}
+
+// logPane shows log records and lets the user filter them by level,
+// strategy and text, and pause them to scroll back. It is an io.Writer of
+// zerolog JSON records; writes never wait for the UI.
+type logPane struct {
+	*tview.Flex
+	view   *tview.TextView
+	status *tview.TextView
+	search *tview.InputField
+
+	// dirty signals that entries were written since the last flush.
+	dirty chan struct{}
+
+	mu         sync.Mutex
+	entries    []logEntry
+	pending    []logEntry
+	strategies map[string]bool
+	level      zerolog.Level
+	strategy   string
+	query      string
+	paused     bool
+	unseen     int
+}
+
+func newLogPane(app *tview.Application) *logPane {
+	p := &logPane{
+		Flex: tview.NewFlex().SetDirection(tview.FlexRow),
+		view: tview.NewTextView().
+			SetDynamicColors(true).
+			SetWordWrap(true).
+			SetMaxLines(maxLogEntries),
+		status:     tview.NewTextView().SetDynamicColors(true),
+		search:     tview.NewInputField().SetLabel("/"),
+		dirty:      make(chan struct{}, 1),
+		strategies: make(map[string]bool),
+		level:      logLevels[0],
+	}
+
+	p.view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'l':
+			p.cycleLevel()
+		case 's':
+			p.cycleStrategy()
+		case 'p':
+			p.togglePause()
+		case '/':
+			p.RemoveItem(p.status)
+			p.AddItem(p.search, 1, 0, true)
+			app.SetFocus(p.search)
+		default:
+			return event
+		}
+		return nil
+	})
+	p.search.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			p.setQuery(p.search.GetText())
+		case tcell.KeyEscape:
+			p.search.SetText("")
+			p.setQuery("")
+		default:
+			return
+		}
+		p.RemoveItem(p.search)
+		p.AddItem(p.status, 1, 0, false)
+		app.SetFocus(p.view)
+	})
+
+	p.AddItem(p.view, 0, 1, true).AddItem(p.status, 1, 0, false)
+	p.updateStatus()
+	return p
+}
+
+// Write adds the zerolog JSON record in b to the pane. Records that are not
+// JSON are shown as info messages.
+func (p *logPane) Write(b []byte) (int, error) {
+	p.add(parseRecord(b))
+	return len(b), nil
+}
+
+// add adds e to the pane and schedules a flush.
+func (p *logPane) add(e logEntry) {
+	p.mu.Lock()
+	p.entries = append(p.entries, e)
+	if len(p.entries) > maxLogEntries+maxLogEntries/10 {
+		p.entries = append(p.entries[:0], p.entries[len(p.entries)-maxLogEntries:]...)
+	}
+	p.pending = append(p.pending, e)
+	if e.strategy != "" {
+		p.strategies[e.strategy] = true
+	}
+	p.mu.Unlock()
+
+	select {
+	case p.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// pump flushes written entries to the view until done is closed.
+func (p *logPane) pump(app *tview.Application, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-p.dirty:
+			app.QueueUpdateDraw(p.flush)
+		}
+	}
+}
+
+// flush shows the entries written since the last flush, or counts them
+// while paused. It must run on the UI goroutine.
+func (p *logPane) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.view.BatchWriter()
+	defer w.Close()
+	for _, e := range p.pending {
+		if !p.matches(e) {
+			continue
+		}
+		if p.paused {
+			p.unseen++
+		} else {
+			w.Write([]byte(e.line()))
+		}
+	}
+	p.pending = p.pending[:0]
+	p.updateStatus()
+}
+
+// render redraws the view from the history. p.mu must be held.
+func (p *logPane) render() {
+	p.pending = p.pending[:0]
+	p.unseen = 0
+	p.view.Clear()
+	w := p.view.BatchWriter()
+	for _, e := range p.entries {
+		if p.matches(e) {
+			w.Write([]byte(e.line()))
+		}
+	}
+	w.Close()
+	p.view.ScrollToEnd()
+	p.updateStatus()
+}
+
+// matches reports whether e passes the filters. p.mu must be held.
+func (p *logPane) matches(e logEntry) bool {
+	if e.level < p.level {
+		return false
+	}
+	if p.strategy != "" && e.strategy != p.strategy {
+		return false
+	}
+	return p.query == "" || strings.Contains(e.text(), strings.ToLower(p.query))
+}
+
+func (p *logPane) cycleLevel() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, l := range logLevels {
+		if l == p.level {
+			p.level = logLevels[(i+1)%len(logLevels)]
+			break
+		}
+	}
+	p.render()
+}
+
+// cycleStrategy moves the strategy filter to the next strategy seen, in
+// alphabetical order, and after the last one back to all strategies.
+func (p *logPane) cycleStrategy() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.strategies))
+	for name := range p.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	next := ""
+	for _, name := range names {
+		if p.strategy == "" || name > p.strategy {
+			next = name
+			break
+		}
+	}
+	p.strategy = next
+	p.render()
+}
+
+func (p *logPane) setQuery(query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.query = query
+	p.render()
+}
+
+// togglePause stops or resumes showing new entries. While paused, the view
+// can be scrolled back without new entries moving it.
+func (p *logPane) togglePause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = !p.paused
+	if !p.paused {
+		p.render()
+		return
+	}
+	p.updateStatus()
+}
+
+// updateStatus shows the filters on the status line. p.mu must be held.
+func (p *logPane) updateStatus() {
+	strategy := p.strategy
+	if strategy == "" {
+		strategy = "all"
+	}
+	status := fmt.Sprintf("level [::b]%s[::-] (l)  strategy [::b]%s[::-] (s)  search [::b]%s[::-] (/)", p.level, tview.Escape(strategy), tview.Escape(p.query))
+	if p.paused {
+		status += fmt.Sprintf("  [yellow]paused, %d new[-] (p)", p.unseen)
+	} else {
+		status += "  pause (p)"
+	}
+	p.status.SetText(status)
+}
+
+// parseRecord parses a zerolog JSON record.
+func parseRecord(b []byte) logEntry {
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return logEntry{time: time.Now(), level: zerolog.InfoLevel, message: strings.TrimSpace(string(b))}
+	}
+
+	e := logEntry{time: time.Now(), level: zerolog.InfoLevel}
+	if s, ok := fields[zerolog.LevelFieldName].(string); ok {
+		if l, err := zerolog.ParseLevel(s); err == nil {
+			e.level = l
+		}
+	}
+	if s, ok := fields[zerolog.TimestampFieldName].(string); ok {
+		if t, err := time.Parse(zerolog.TimeFieldFormat, s); err == nil {
+			e.time = t
+		}
+	}
+	e.message, _ = fields[zerolog.MessageFieldName].(string)
+	e.strategy, _ = fields["strategy"].(string)
+	for _, key := range []string{zerolog.LevelFieldName, zerolog.TimestampFieldName, zerolog.MessageFieldName, "strategy"} {
+		delete(fields, key)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, fields[key])
+	}
+	e.fields = strings.Join(pairs, " ")
+	return e
+}
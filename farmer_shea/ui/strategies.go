@@ -0,0 +1,270 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/action"
+	"github.com/sheawinkler/farmer-shea/executor"
+)
+
+// StrategyControls changes how strategies run, e.g. *service.Service.
+type StrategyControls interface {
+	Pause(name string) error
+	Resume(name string) error
+	Trigger(name string) error
+	Disable(name string) error
+	// Halt engages the kill switch and Lift disengages it.
+	Halt(reason string, unwind bool) error
+	Lift() error
+}
+
+// Page names.
+const (
+	mainPage    = "main"
+	detailPage  = "detail"
+	confirmPage = "confirm"
+	haltPage    = "halt"
+)
+
+// strategiesPane lists strategies and their status. Keys on the selected
+// strategy: p pauses or resumes it, r runs it now, d disables it and Enter
+// shows its recent actions. H engages or lifts the kill switch, after
+// confirmation.
+type strategiesPane struct {
+	*tview.Table
+	pages  *tview.Pages
+	detail *tview.TextView
+
+	// statuses and detailName are only used on the UI goroutine.
+	statuses   []executor.Status
+	detailName string
+
+	mu       sync.Mutex
+	controls StrategyControls
+}
+
+func newStrategiesPane(pages *tview.Pages) *strategiesPane {
+	p := &strategiesPane{
+		Table:  tview.NewTable().SetBorders(true).SetSelectable(true, false).SetFixed(1, 0),
+		pages:  pages,
+		detail: tview.NewTextView().SetDynamicColors(true).SetWordWrap(true),
+	}
+	p.detail.SetBorder(true)
+	p.detail.SetDoneFunc(func(tcell.Key) {
+		p.detailName = ""
+		p.pages.RemovePage(detailPage)
+	})
+
+	p.SetSelectedFunc(func(row, _ int) {
+		if st, ok := p.status(row); ok {
+			p.detailName = st.Strategy
+			p.showDetail(st)
+			p.pages.AddPage(detailPage, p.detail, true, true)
+		}
+	})
+	p.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() == 'H' {
+			p.confirmHalt()
+			return nil
+		}
+		st, ok := p.selected()
+		if !ok {
+			return event
+		}
+		var err error
+		switch event.Rune() {
+		case 'p':
+			if st.Paused {
+				err = p.control(func(c StrategyControls) error { return c.Resume(st.Strategy) })
+			} else {
+				err = p.control(func(c StrategyControls) error { return c.Pause(st.Strategy) })
+			}
+		case 'r':
+			err = p.control(func(c StrategyControls) error { return c.Trigger(st.Strategy) })
+		case 'd':
+			err = p.control(func(c StrategyControls) error { return c.Disable(st.Strategy) })
+		default:
+			return event
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("strategy", st.Strategy).Msg("Strategy control failed")
+		}
+		return nil
+	})
+	p.update(nil)
+	return p
+}
+
+// confirmHalt asks whether to engage the kill switch, or to lift it if it
+// is engaged. It must run on the UI goroutine.
+func (p *strategiesPane) confirmHalt() {
+	halted := false
+	for _, st := range p.statuses {
+		halted = halted || st.Halted
+	}
+
+	modal := tview.NewModal()
+	if halted {
+		modal.SetText("Lift the kill switch and let strategies run again?").
+			AddButtons([]string{"Lift", "Cancel"})
+	} else {
+		modal.SetText("Halt every strategy?\n\nUnwinding also withdraws the positions of strategies that support it.").
+			AddButtons([]string{"Halt", "Halt and unwind", "Cancel"})
+	}
+	modal.SetDoneFunc(func(_ int, label string) {
+		p.pages.RemovePage(haltPage)
+		var err error
+		switch label {
+		case "Lift":
+			err = p.control(func(c StrategyControls) error { return c.Lift() })
+		case "Halt", "Halt and unwind":
+			err = p.control(func(c StrategyControls) error { return c.Halt("halted from the UI", label != "Halt") })
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("Kill switch control failed")
+		}
+	})
+	p.pages.AddPage(haltPage, modal, true, true)
+}
+
+// control calls fn with the controls, once they are set.
+func (p *strategiesPane) control(fn func(StrategyControls) error) error {
+	p.mu.Lock()
+	c := p.controls
+	p.mu.Unlock()
+	if c == nil {
+		return fmt.Errorf("strategies are not running yet")
+	}
+	return fn(c)
+}
+
+func (p *strategiesPane) setControls(c StrategyControls) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.controls = c
+}
+
+// status returns the status shown in row.
+func (p *strategiesPane) status(row int) (executor.Status, bool) {
+	if row < 1 || row > len(p.statuses) {
+		return executor.Status{}, false
+	}
+	return p.statuses[row-1], true
+}
+
+func (p *strategiesPane) selected() (executor.Status, bool) {
+	row, _ := p.GetSelection()
+	return p.status(row)
+}
+
+// update shows statuses, keeping the selected strategy selected. It must
+// run on the UI goroutine.
+func (p *strategiesPane) update(statuses []executor.Status) {
+	selected, _ := p.selected()
+	p.statuses = statuses
+
+	p.Clear()
+	for col, title := range []string{"Strategy", "Wallet", "Status", "Last run", "Next run", "Failures", "Last error"} {
+		p.SetCell(0, col, tview.NewTableCell(title).SetSelectable(false))
+	}
+	row := 1
+	for i, st := range statuses {
+		cells := []string{st.Strategy, st.Wallet, st.State(), formatTime(st.LastRun), formatTime(st.NextRun), fmt.Sprint(st.Failures), st.LastError}
+		for col, text := range cells {
+			p.SetCell(i+1, col, tview.NewTableCell(tview.Escape(text)).SetMaxWidth(40))
+		}
+		if st.Strategy == selected.Strategy {
+			row = i + 1
+		}
+		if st.Strategy == p.detailName {
+			p.showDetail(st)
+		}
+	}
+	p.Select(row, 0)
+}
+
+// showDetail shows the status of a strategy and its recent actions, newest
+// first, in the detail view.
+func (p *strategiesPane) showDetail(st executor.Status) {
+	p.detail.SetTitle(" " + tview.Escape(st.Strategy) + " (Esc to close) ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Wallet: %s\nStatus: %s\nLast run: %s\nNext run: %s\nFailures: %d\n",
+		tview.Escape(st.Wallet), st.State(), formatTime(st.LastRun), formatTime(st.NextRun), st.Failures)
+	if st.LastError != "" {
+		fmt.Fprintf(&b, "Last error: [red]%s[-]\n", tview.Escape(st.LastError))
+	}
+	b.WriteString("\nRecent actions:\n")
+	if len(st.Recent) == 0 {
+		b.WriteString("  none\n")
+	}
+	for i := len(st.Recent) - 1; i >= 0; i-- {
+		r := st.Recent[i]
+		manual := ""
+		if r.Manual {
+			manual = " (manual)"
+		}
+		fmt.Fprintf(&b, "  [gray]%s[-] %s%s\n", formatTime(r.Time), tview.Escape(r.Action), manual)
+		if r.Rationale != "" {
+			fmt.Fprintf(&b, "    %s\n", tview.Escape(r.Rationale))
+		}
+		if r.Err != "" {
+			fmt.Fprintf(&b, "    [red]%s[-]\n", tview.Escape(r.Err))
+		} else {
+			b.WriteString("    [green]applied[-]\n")
+		}
+	}
+	p.detail.SetText(b.String())
+}
+
+// formatTime formats t for a table cell, or "-" if it is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("15:04:05")
+}
+
+// Approve asks the user to confirm a live action in a modal that blocks the
+// rest of the UI until answered. It implements executor.Approver and
+// returns false if ctx is cancelled first.
+func (ui *UI) Approve(ctx context.Context, a action.Action) (bool, error) {
+	// One modal at a time.
+	ui.confirmMu.Lock()
+	defer ui.confirmMu.Unlock()
+
+	answer := make(chan bool, 1)
+	modal := tview.NewModal().
+		SetText(tview.Escape(fmt.Sprintf("Submit this live transaction for %s?\n\n%s\n\n%s", a.Strategy, a, a.Rationale))).
+		AddButtons([]string{"Submit", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			select {
+			case answer <- label == "Submit":
+			default:
+			}
+		})
+
+	// The UI may have stopped, so never wait for it here.
+	go ui.app.QueueUpdateDraw(func() {
+		ui.pages.AddPage(confirmPage, modal, true, true)
+	})
+	defer func() {
+		go ui.app.QueueUpdateDraw(func() {
+			ui.pages.RemovePage(confirmPage)
+		})
+	}()
+
+	select {
+	case ok := <-answer:
+		return ok, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
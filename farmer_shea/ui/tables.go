@@ -0,0 +1,270 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/portfolio"
+)
+
+// historyLen is the number of updates kept for sparklines.
+const historyLen = 24
+
+// sortOrder is how a table sorts its rows.
+type sortOrder int
+
+const (
+	// byValue sorts the largest values first.
+	byValue sortOrder = iota
+	// byName sorts alphabetically.
+	byName
+)
+
+func (o sortOrder) String() string {
+	if o == byName {
+		return "name"
+	}
+	return "value"
+}
+
+// history keeps the latest values of each row of a table.
+type history map[string][]float64
+
+// add appends v to the values of key.
+func (h history) add(key string, v float64) {
+	values := append(h[key], v)
+	if len(values) > historyLen {
+		values = values[len(values)-historyLen:]
+	}
+	h[key] = values
+}
+
+// retain forgets the rows not in keys.
+func (h history) retain(keys map[string]bool) {
+	for key := range h {
+		if !keys[key] {
+			delete(h, key)
+		}
+	}
+}
+
+// sortedTable is a table whose rows can be sorted by value or by name; o
+// toggles between the two.
+type sortedTable struct {
+	*tview.Table
+	title  string
+	order  sortOrder
+	render func()
+}
+
+func newSortedTable(title string) *sortedTable {
+	t := &sortedTable{Table: tview.NewTable().SetFixed(1, 0).SetSelectable(true, false), title: title}
+	t.SetBorder(true)
+	t.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() != 'o' {
+			return event
+		}
+		t.order = 1 - t.order
+		t.render()
+		return nil
+	})
+	return t
+}
+
+// header resets the table to the column titles, with numeric columns from
+// first on aligned to the right.
+func (t *sortedTable) header(first int, titles ...string) {
+	t.Clear()
+	t.SetTitle(fmt.Sprintf(" %s, by %s (o) ", t.title, t.order))
+	for col, title := range titles {
+		cell := tview.NewTableCell(title).SetSelectable(false).SetAttributes(tcell.AttrBold)
+		if col >= first {
+			cell.SetAlign(tview.AlignRight)
+		}
+		t.SetCell(0, col, cell)
+	}
+}
+
+// text sets a left-aligned cell.
+func (t *sortedTable) text(row, col int, text string) {
+	t.SetCell(row, col, tview.NewTableCell(tview.Escape(text)))
+}
+
+// number sets a right-aligned cell.
+func (t *sortedTable) number(row, col int, text string, color tcell.Color) {
+	t.SetCell(row, col, tview.NewTableCell(text).SetAlign(tview.AlignRight).SetTextColor(color))
+}
+
+// portfolioTable shows the holdings of the latest snapshot with their share
+// of the portfolio and the trend of their value, and the total of each
+// wallet if there are several.
+type portfolioTable struct {
+	*sortedTable
+	snapshot portfolio.Snapshot
+	history  history
+	totals   []float64
+}
+
+func newPortfolioTable() *portfolioTable {
+	t := &portfolioTable{sortedTable: newSortedTable("Portfolio"), history: make(history)}
+	t.sortedTable.render = t.render
+	t.render()
+	return t
+}
+
+// holdingKey identifies a holding across snapshots.
+func holdingKey(h portfolio.Holding) string {
+	return strings.Join([]string{h.Wallet, string(h.Chain), h.Asset, h.Account}, "\x00")
+}
+
+// update shows s and adds it to the history.
+func (t *portfolioTable) update(s portfolio.Snapshot) {
+	t.snapshot = s
+	keys := make(map[string]bool)
+	for _, h := range s.Holdings {
+		key := holdingKey(h)
+		keys[key] = true
+		t.history.add(key, h.Value)
+	}
+	t.history.retain(keys)
+	t.totals = append(t.totals, s.TotalValue())
+	if len(t.totals) > historyLen {
+		t.totals = t.totals[len(t.totals)-historyLen:]
+	}
+	t.render()
+}
+
+func (t *portfolioTable) render() {
+	t.header(3, "Wallet", "Chain", "Asset", "Amount", "Price", "Value", "Share", "Trend")
+
+	holdings := append([]portfolio.Holding(nil), t.snapshot.Holdings...)
+	sort.SliceStable(holdings, func(i, j int) bool {
+		if t.order == byValue && holdings[i].Value != holdings[j].Value {
+			return holdings[i].Value > holdings[j].Value
+		}
+		return holdingKey(holdings[i]) < holdingKey(holdings[j])
+	})
+
+	total := t.snapshot.TotalValue()
+	for i, h := range holdings {
+		row := i + 1
+		asset := h.Asset
+		if h.Account != "" {
+			asset += " (" + h.Account + ")"
+		}
+		price, value, share := "-", "-", "-"
+		if h.Price != 0 {
+			price, value = formatUSD(h.Price), formatUSD(h.Value)
+			if total > 0 {
+				share = formatPercent(h.Value / total)
+			}
+		}
+		t.text(row, 0, h.Wallet)
+		t.text(row, 1, string(h.Chain))
+		t.text(row, 2, asset)
+		t.number(row, 3, formatAmount(h.Asset, h.Amount), tcell.ColorDefault)
+		t.number(row, 4, price, tcell.ColorDefault)
+		t.number(row, 5, value, tcell.ColorDefault)
+		t.number(row, 6, share, tcell.ColorDefault)
+		t.text(row, 7, sparkline(t.history[holdingKey(h)]))
+	}
+
+	row := len(holdings) + 1
+	if byWallet := t.snapshot.ByWallet(); len(byWallet) > 1 {
+		for _, w := range sortedKeys(byWallet) {
+			t.text(row, 0, w+" total")
+			t.number(row, 5, formatUSD(byWallet[w]), tcell.ColorDefault)
+			if total > 0 {
+				t.number(row, 6, formatPercent(byWallet[w]/total), tcell.ColorDefault)
+			}
+			row++
+		}
+	}
+	t.text(row, 0, "total")
+	t.number(row, 5, formatUSD(total), tcell.ColorDefault)
+	t.text(row, 7, sparkline(t.totals))
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pnlTable shows the PnL of each strategy, wallet and asset with the trend
+// of its net PnL, and the PnL of each wallet if there are several.
+type pnlTable struct {
+	*sortedTable
+	report  pnl.Report
+	history history
+}
+
+func newPnLTable() *pnlTable {
+	t := &pnlTable{sortedTable: newSortedTable("PnL"), history: make(history)}
+	t.sortedTable.render = t.render
+	t.render()
+	return t
+}
+
+// lineKey identifies a line of a report across reports.
+func lineKey(l pnl.Line) string {
+	return strings.Join([]string{l.Strategy, l.Wallet, l.Asset}, "\x00")
+}
+
+// update shows r and adds it to the history.
+func (t *pnlTable) update(r pnl.Report) {
+	t.report = r
+	keys := make(map[string]bool)
+	for _, l := range r.Lines {
+		key := lineKey(l)
+		keys[key] = true
+		t.history.add(key, l.Net())
+	}
+	keys[""] = true
+	t.history.add("", r.Total.Net())
+	t.history.retain(keys)
+	t.render()
+}
+
+func (t *pnlTable) render() {
+	t.header(3, "Strategy", "Wallet", "Asset", "Realized", "Unrealized", "Yield", "Fees", "Net", "Trend")
+
+	lines := append([]pnl.Line(nil), t.report.Lines...)
+	sort.SliceStable(lines, func(i, j int) bool {
+		if t.order == byValue && lines[i].Net() != lines[j].Net() {
+			return lines[i].Net() > lines[j].Net()
+		}
+		return lineKey(lines[i]) < lineKey(lines[j])
+	})
+
+	for i, l := range lines {
+		t.row(i+1, l.Strategy, l.Wallet, l.Asset, l.Summary, t.history[lineKey(l)])
+	}
+	row := len(lines) + 1
+	if len(t.report.ByWallet) > 1 {
+		for _, w := range sortedKeys(t.report.ByWallet) {
+			t.row(row, "", w+" total", "", t.report.ByWallet[w], nil)
+			row++
+		}
+	}
+	t.row(row, "total", "", "", t.report.Total, t.history[""])
+}
+
+// row writes the PnL of s to row.
+func (t *pnlTable) row(row int, strategy, wallet, asset string, s pnl.Summary, trend []float64) {
+	t.text(row, 0, strategy)
+	t.text(row, 1, wallet)
+	t.text(row, 2, asset)
+	for col, v := range []float64{s.Realized, s.Unrealized, s.Yield, -s.Fees, s.Net()} {
+		t.number(row, col+3, formatSignedUSD(v), pnlColor(v))
+	}
+	t.text(row, 8, sparkline(trend))
+}
@@ -2,98 +2,143 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/rs/zerolog"
+	"github.com/sheawinkler/farmer-shea/executor"
+	"github.com/sheawinkler/farmer-shea/pnl"
+	"github.com/sheawinkler/farmer-shea/portfolio"
 )
 
 // UI is the user interface for the application.	ype UI struct {
 	app         *tview.Application
-	logView     *tview.TextView
-	portfolioView *tview.Table
-	pnlView     *tview.Table
-	totalPnLView *tview.TextView
+	pages       *tview.Pages
+	strategies  *strategiesPane
+	logs        *logPane
+	summary     *tview.TextView
+	portfolio   *portfolioTable
+	pnl         *pnlTable
+
+	confirmMu sync.Mutex
 }
 
 // New creates a new UI.
 func New() *UI {
 	app := tview.NewApplication()
 
-	logView := tview.NewTextView().
-		SetDynamicColors(true).
-		SetRegions(true).
-		SetWordWrap(true)
+	pages := tview.NewPages()
+	strategies := newStrategiesPane(pages)
+	logs := newLogPane(app)
 
-	portfolioView := tview.NewTable().
-		SetBorders(true)
-
-	pnlView := tview.NewTable().
-		SetBorders(true)
-
-	totalPnLView := tview.NewTextView().
-		SetBorders(true)
+	summary := tview.NewTextView().SetDynamicColors(true)
+	portfolioView := newPortfolioTable()
+	pnlView := newPnLTable()
 
 	flex := tview.NewFlex().
 		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(summary, 1, 0, false).
+			AddItem(strategies, 0, 1, true).
 			AddItem(portfolioView, 0, 1, false).
-			AddItem(pnlView, 0, 1, false).
-			AddItem(totalPnLView, 0, 1, false), 0, 1, false).
-		AddItem(logView, 0, 2, false)
+			AddItem(pnlView, 0, 1, false), 0, 1, true).
+		AddItem(logs, 0, 2, false)
+
+	pages.AddPage(mainPage, flex, true, true)
+	app.SetRoot(pages, true).SetFocus(strategies)
 
-	app.SetRoot(flex, true)
+	// Tab moves the focus through the panes.
+	panes := []tview.Primitive{strategies, portfolioView, pnlView, logs.view}
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyTab {
+			return event
+		}
+		for i, pane := range panes {
+			if app.GetFocus() == pane {
+				app.SetFocus(panes[(i+1)%len(panes)])
+				return nil
+			}
+		}
+		return event
+	})
 
 	return &UI{
 		app:         app,
-		logView:     logView,
-		portfolioView: portfolioView,
-		pnlView:     pnlView,
-		totalPnLView: totalPnLView,
+		pages:       pages,
+		strategies:  strategies,
+		logs:        logs,
+		summary:     summary,
+		portfolio:   portfolioView,
+		pnl:         pnlView,
 	}
 }
 
 // Run runs the UI.
 func (ui *UI) Run() error {
+	done := make(chan struct{})
+	defer close(done)
+	go ui.logs.pump(ui.app, done)
 	return ui.app.Run()
 }
 
-// Log logs a message to the log view.
+// Stop stops the UI, causing Run to return.
+func (ui *UI) Stop() {
+	ui.app.Stop()
+}
+
+// LogWriter returns the writer of the log pane. It takes zerolog JSON
+// records and never blocks.
+func (ui *UI) LogWriter() io.Writer {
+	return ui.logs
+}
+
+// Log logs a message to the log pane at info level.
 func (ui *UI) Log(message string) {
+	ui.logs.add(logEntry{time: time.Now(), level: zerolog.InfoLevel, message: strings.TrimSpace(message)})
+}
+
+// SetStrategyControls lets the strategies pane pause, resume, trigger and
+// disable strategies with c.
+func (ui *UI) SetStrategyControls(c StrategyControls) {
+	ui.strategies.setControls(c)
+}
+
+// UpdateStrategies updates the strategies pane with statuses.
+func (ui *UI) UpdateStrategies(statuses []executor.Status) {
 	ui.app.QueueUpdateDraw(func() {
-		ui.logView.Write([]byte(message))
+		ui.strategies.update(statuses)
 	})
 }
 
-// UpdatePortfolio updates the portfolio view.
-func (ui *UI) UpdatePortfolio(portfolio map[string]float64) {
+// UpdatePortfolio updates the portfolio view and the summary with the
+// holdings in s.
+func (ui *UI) UpdatePortfolio(s portfolio.Snapshot) {
 	ui.app.QueueUpdateDraw(func() {
-		ui.portfolioView.Clear()
-		ui.portfolioView.SetCell(0, 0, tview.NewTableCell("Asset"))
-		ui.portfolioView.SetCell(0, 1, tview.NewTableCell("Amount"))
-
-		i := 1
-		for asset, amount := range portfolio {
-			ui.portfolioView.SetCell(i, 0, tview.NewTableCell(asset))
-			ui.portfolioView.SetCell(i, 1, tview.NewTableCell(fmt.Sprintf("%.2f", amount)))
-			i++
-		}
+		ui.portfolio.update(s)
+		ui.updateSummary()
 	})
 }
 
-// UpdatePnL updates the PnL view.
-func (ui *UI) UpdatePnL(pnl map[string]float64) {
+// UpdatePnL updates the PnL view and the summary with r.
+func (ui *UI) UpdatePnL(r pnl.Report) {
 	ui.app.QueueUpdateDraw(func() {
-		ui.pnlView.Clear()
-		ui.pnlView.SetCell(0, 0, tview.NewTableCell("Asset"))
-		ui.pnlView.SetCell(0, 1, tview.NewTableCell("PnL"))
-
-		i := 1
-		totalPnL := 0.0
-		for asset, amount := range pnl {
-			ui.pnlView.SetCell(i, 0, tview.NewTableCell(asset))
-			ui.pnlView.SetCell(i, 1, tview.NewTableCell(fmt.Sprintf("%.2f", amount)))
-			totalPnL += amount
-			i++
-		}
-
-		ui.totalPnLView.SetText(fmt.Sprintf("Total PnL: %.2f", totalPnL))
+		ui.pnl.update(r)
+		ui.updateSummary()
 	})
 }
+
+// updateSummary shows the total portfolio value and PnL. It must run on the
+// UI goroutine.
+func (ui *UI) updateSummary() {
+	s, r := ui.portfolio.snapshot, ui.pnl.report
+	text := fmt.Sprintf("Portfolio [::b]%s[::-] %s   PnL %s%s[-] (%s)   IL %s",
+		formatUSD(s.TotalValue()), sparkline(ui.portfolio.totals),
+		pnlTag(r.Total.Net()), formatSignedUSD(r.Total.Net()), r.Method, formatUSD(r.Total.ImpermanentLoss))
+	if !s.Time.IsZero() {
+		text += "   as of " + formatTime(s.Time)
+	}
+	ui.summary.SetText(text)
+}
@@ -1,53 +1,82 @@
 package wallet
 
 import (
-	"encoding/json"
-	"os"
+	"fmt"
+	"sort"
 
-	"github.com/gagliardetto/solana-go"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/signer"
 )
 
-// Wallet represents a Solana wallet.
+// Default is the wallet strategies use unless they are assigned another.
+const Default = "default"
+
+// Wallet is a named set of keys, at most one per chain. Strategies assigned
+// to different wallets never share keys.
 type Wallet struct {
-	PrivateKey solana.PrivateKey `json:"privateKey"`
-	PublicKey  solana.PublicKey  `json:"publicKey"`
+	Name string
+	Keys *signer.Keyring
 }
 
-// NewWallet creates a new Solana wallet.
-func NewWallet() (*Wallet, error) {
-	privateKey, err := solana.NewRandomPrivateKey()
-	if err != nil {
-		return nil, err
-	}
+// Set holds wallets by name.
+type Set struct {
+	wallets map[string]*Wallet
+}
 
-	return &Wallet{
-		PrivateKey: privateKey,
-		PublicKey:  privateKey.PublicKey(),
-	}, nil
+// NewSet creates a Set holding the given wallets.
+func NewSet(wallets ...*Wallet) (*Set, error) {
+	s := &Set{wallets: make(map[string]*Wallet)}
+	for _, w := range wallets {
+		if err := s.Add(w); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
-// Save saves the wallet to a file.
-func (w *Wallet) Save(path string) error {
-	data, err := json.Marshal(w)
-	if err != nil {
-		return err
+// Add adds w to the set. Wallet names must be unique.
+func (s *Set) Add(w *Wallet) error {
+	if _, ok := s.wallets[w.Name]; ok {
+		return fmt.Errorf("duplicate wallet %q", w.Name)
 	}
+	s.wallets[w.Name] = w
+	return nil
+}
 
-	return os.WriteFile(path, data, 0600)
+// Get returns the wallet with the given name.
+func (s *Set) Get(name string) (*Wallet, error) {
+	w, ok := s.wallets[name]
+	if !ok {
+		return nil, errkind.Wrapf(errkind.Permanent, "no wallet named %q", name)
+	}
+	return w, nil
 }
 
-// Load loads a wallet from a file.
-func Load(path string) (*Wallet, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
+// Names returns the wallet names in sorted order.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.wallets))
+	for name := range s.wallets {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	var wallet Wallet
-	err = json.Unmarshal(data, &wallet)
+// Load unlocks the keystores of each wallet. paths maps a wallet name to its
+// keystore paths by chain, as accepted by signer.LoadKeyring.
+func Load(paths map[string]map[string]string, passphrase []byte) (*Set, error) {
+	s, err := NewSet()
 	if err != nil {
 		return nil, err
 	}
-
-	return &wallet, nil
+	for name, keys := range paths {
+		keyring, err := signer.LoadKeyring(keys, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("wallet %s: %w", name, err)
+		}
+		if err := s.Add(&Wallet{Name: name, Keys: keyring}); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
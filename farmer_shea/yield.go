@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/config"
+	"github.com/sheawinkler/farmer-shea/strategy"
+	"github.com/sheawinkler/farmer-shea/yield"
+)
+
+// yieldCosts converts the yield config into the costs opportunities are
+// ranked net of.
+func yieldCosts(cfg config.YieldConfig) yield.Costs {
+	gas := make(map[chain.ID]float64, len(cfg.GasUSD))
+	for id, usd := range cfg.GasUSD {
+		gas[chain.ID(id)] = usd
+	}
+	return yield.Costs{
+		Amount:  cfg.AmountUSD,
+		Horizon: cfg.Horizon,
+		Gas:     gas,
+		Home:    chain.ID(cfg.HomeChain),
+		Bridge:  cfg.BridgeUSD,
+	}
+}
+
+// buildYields creates the engine that compares the yield opportunities of
+// every protocol the strategies can deploy to.
+func buildYields(cfg config.YieldConfig, deps strategy.Deps) *yield.Engine {
+	sources := []yield.Source{
+		&strategy.SolendReserves{Client: deps.Solana},
+		deps.Marinade,
+	}
+	if len(cfg.HyperliquidVaults) > 0 {
+		sources = append(sources, &yield.HyperliquidVaults{Client: deps.Hyperliquid, Vaults: cfg.HyperliquidVaults})
+	}
+	if cfg.UniswapSubgraph != "" {
+		sources = append(sources, &yield.UniswapV3{SubgraphURL: cfg.UniswapSubgraph, Chain: chain.Base, Pools: cfg.UniswapPools})
+	}
+	return &yield.Engine{Sources: sources, Costs: deps.Costs}
+}
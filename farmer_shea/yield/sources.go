@@ -0,0 +1,220 @@
+package yield
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sheawinkler/farmer-shea/chain"
+	"github.com/sheawinkler/farmer-shea/errkind"
+	"github.com/sheawinkler/farmer-shea/hyperliquid"
+)
+
+const (
+	// DefaultMarinadeAPI is the Marinade Finance API endpoint.
+	DefaultMarinadeAPI = "https://api.marinade.finance"
+	// marinadeUnstakeDelay is how long a delayed unstake of mSOL takes: it
+	// completes after the next epoch boundary, up to two epochs away.
+	marinadeUnstakeDelay = 4 * 24 * time.Hour
+	// vaultLockup is how long Hyperliquid vault deposits are locked.
+	vaultLockup = 24 * time.Hour
+	// maxErrorBody bounds how much of an error response is reported.
+	maxErrorBody = 512
+)
+
+// HyperliquidVaults reports the APY and TVL of Hyperliquid vaults.
+type HyperliquidVaults struct {
+	Client *hyperliquid.Client
+	// Vaults are the addresses of the vaults to report.
+	Vaults []string
+}
+
+// Name implements Source.
+func (*HyperliquidVaults) Name() string { return "hyperliquid-vaults" }
+
+// Opportunities implements Source. Vaults whose details cannot be fetched
+// are logged and left out.
+func (h *HyperliquidVaults) Opportunities(ctx context.Context) ([]Opportunity, error) {
+	var opportunities []Opportunity
+	for _, address := range h.Vaults {
+		details, err := h.Client.GetVaultDetails(ctx, address)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			log.Warn().Err(err).Str("vault", address).Msg("Failed to get vault details")
+			continue
+		}
+		opportunities = append(opportunities, Opportunity{
+			Protocol: "hyperliquid-vaults",
+			Chain:    chain.Hyperliquid,
+			Asset:    "USDC",
+			ID:       address,
+			// Vault returns stay in the vault and compound with it, so
+			// its APR is taken as the APY.
+			APY:    details.APR,
+			TVL:    details.TVL,
+			Lockup: vaultLockup,
+		})
+	}
+	return opportunities, nil
+}
+
+// Marinade reports the staking APY of Marinade's mSOL.
+type Marinade struct {
+	// APIURL defaults to DefaultMarinadeAPI.
+	APIURL string
+	Client *http.Client
+}
+
+// Name implements Source.
+func (*Marinade) Name() string { return "marinade" }
+
+// Opportunities implements Source.
+func (m *Marinade) Opportunities(ctx context.Context) ([]Opportunity, error) {
+	api := m.APIURL
+	if api == "" {
+		api = DefaultMarinadeAPI
+	}
+	var apy struct {
+		Value float64 `json:"value"`
+	}
+	if err := doJSON(ctx, m.Client, http.MethodGet, strings.TrimRight(api, "/")+"/msol/apy/30d", nil, &apy); err != nil {
+		return nil, err
+	}
+	return []Opportunity{{
+		Protocol: "marinade",
+		Chain:    chain.Solana,
+		Asset:    "SOL",
+		ID:       "mSOL",
+		APY:      apy.Value,
+		Lockup:   marinadeUnstakeDelay,
+	}}, nil
+}
+
+// UniswapV3 reports the fee APY of Uniswap V3 pools from a subgraph: their
+// average daily fees over the last week, annualized, over their TVL.
+type UniswapV3 struct {
+	// SubgraphURL is the GraphQL endpoint of a Uniswap V3 subgraph for the
+	// chain.
+	SubgraphURL string
+	Chain       chain.ID
+	// Pools are the addresses of the pools to report.
+	Pools  []string
+	Client *http.Client
+}
+
+// Name implements Source.
+func (*UniswapV3) Name() string { return "uniswap-v3" }
+
+// uniswapPoolsQuery fetches the TVL and last week of daily fees of pools.
+const uniswapPoolsQuery = `query($ids: [ID!]) {
+  pools(where: {id_in: $ids}) {
+    id
+    totalValueLockedUSD
+    token0 { symbol }
+    token1 { symbol }
+    poolDayData(first: 7, orderBy: date, orderDirection: desc) { feesUSD }
+  }
+}`
+
+// Opportunities implements Source.
+func (u *UniswapV3) Opportunities(ctx context.Context) ([]Opportunity, error) {
+	ids := make([]string, len(u.Pools))
+	for i, p := range u.Pools {
+		ids[i] = strings.ToLower(p)
+	}
+	req := map[string]any{"query": uniswapPoolsQuery, "variables": map[string]any{"ids": ids}}
+	var resp struct {
+		Data struct {
+			Pools []struct {
+				ID     string `json:"id"`
+				TVL    string `json:"totalValueLockedUSD"`
+				Token0 struct {
+					Symbol string `json:"symbol"`
+				} `json:"token0"`
+				Token1 struct {
+					Symbol string `json:"symbol"`
+				} `json:"token1"`
+				Days []struct {
+					Fees string `json:"feesUSD"`
+				} `json:"poolDayData"`
+			} `json:"pools"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := doJSON(ctx, u.Client, http.MethodPost, u.SubgraphURL, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("subgraph query failed: %s", resp.Errors[0].Message)
+	}
+
+	var opportunities []Opportunity
+	for _, p := range resp.Data.Pools {
+		tvl, _ := strconv.ParseFloat(p.TVL, 64)
+		var fees float64
+		for _, d := range p.Days {
+			f, _ := strconv.ParseFloat(d.Fees, 64)
+			fees += f
+		}
+		o := Opportunity{
+			Protocol: "uniswap-v3",
+			Chain:    u.Chain,
+			Asset:    p.Token0.Symbol + "/" + p.Token1.Symbol,
+			ID:       p.ID,
+			TVL:      tvl,
+		}
+		if tvl > 0 && len(p.Days) > 0 {
+			o.APY = fees / float64(len(p.Days)) * 365 / tvl
+		}
+		opportunities = append(opportunities, o)
+	}
+	return opportunities, nil
+}
+
+// doJSON sends a request with body, if not nil, encoded as JSON and decodes
+// the JSON response into out.
+func doJSON(ctx context.Context, client *http.Client, method, url string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errkind.Annotate(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err := fmt.Errorf("%s %s: %s: %s", method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(msg))
+		return errkind.FromHTTPStatus(resp.StatusCode, resp.Header.Get("Retry-After"), err)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errkind.Wrap(errkind.Transient, fmt.Errorf("failed to decode response from %s: %w", req.URL.Redacted(), err))
+	}
+	return nil
+}
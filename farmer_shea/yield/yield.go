@@ -0,0 +1,155 @@
+package yield
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sheawinkler/farmer-shea/chain"
+)
+
+// DefaultHorizon is how long capital is expected to stay in an
+// opportunity when no horizon is set. One-off costs are spread over it.
+const DefaultHorizon = 30 * 24 * time.Hour
+
+// year is the length of a year APYs are quoted over.
+const year = 365 * 24 * time.Hour
+
+// Opportunity is somewhere capital can earn yield, e.g. a lending reserve,
+// a vault or a liquidity pool, reported in the same terms by every source.
+type Opportunity struct {
+	// Source is the name of the source that reported the opportunity.
+	Source   string
+	Protocol string
+	Chain    chain.ID
+	Asset    string
+	// ID identifies the reserve, vault or pool within the protocol, e.g.
+	// its address.
+	ID string
+	// APY is the current annual yield as a fraction, e.g. 0.05 for 5%.
+	APY float64
+	// TVL is the value deposited, in USD, or 0 if unknown.
+	TVL float64
+	// Utilization is the share of deposits lent out, for lending markets.
+	Utilization float64
+	// Lockup is how long deposits take to withdraw.
+	Lockup time.Duration
+	// Fee is the cost of entering and exiting, as a fraction of the amount.
+	Fee float64
+}
+
+// Source reports the opportunities of a protocol.
+type Source interface {
+	Name() string
+	Opportunities(ctx context.Context) ([]Opportunity, error)
+}
+
+// Costs are the costs of moving capital into and out of an opportunity.
+type Costs struct {
+	// Amount is the capital to deploy, in USD. Gas and bridge costs are
+	// ignored if it is zero.
+	Amount float64
+	// Horizon is how long the capital stays. Opportunities locked for
+	// longer rank last. It defaults to DefaultHorizon.
+	Horizon time.Duration
+	// Gas is the cost of a transaction in USD, by chain.
+	Gas map[chain.ID]float64
+	// Home is the chain the capital is on, if any, and Bridge the cost in
+	// USD of bridging it to another chain.
+	Home   chain.ID
+	Bridge float64
+}
+
+// Net returns the APY of o once its fees, the gas to enter and exit it and
+// any bridging are paid out of the yield earned over the horizon.
+func (c Costs) Net(o Opportunity) float64 {
+	horizon := c.horizon()
+	cost := o.Fee
+	if c.Amount > 0 {
+		fixed := 2 * c.Gas[o.Chain]
+		if c.Home != "" && c.Home != o.Chain {
+			fixed += c.Bridge
+		}
+		cost += fixed / c.Amount
+	}
+	return o.APY - cost*float64(year)/float64(horizon)
+}
+
+func (c Costs) horizon() time.Duration {
+	if c.Horizon > 0 {
+		return c.Horizon
+	}
+	return DefaultHorizon
+}
+
+// Ranked is an opportunity with its yield net of costs.
+type Ranked struct {
+	Opportunity
+	NetAPY float64
+	// Locked is set if the opportunity's lockup exceeds the horizon.
+	Locked bool
+}
+
+// Rank orders opportunities by their yield net of costs, best first.
+// Opportunities locked for longer than the horizon rank after the rest.
+func Rank(opportunities []Opportunity, c Costs) []Ranked {
+	ranked := make([]Ranked, len(opportunities))
+	for i, o := range opportunities {
+		ranked[i] = Ranked{Opportunity: o, NetAPY: c.Net(o), Locked: o.Lockup > c.horizon()}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Locked != ranked[j].Locked {
+			return !ranked[i].Locked
+		}
+		return ranked[i].NetAPY > ranked[j].NetAPY
+	})
+	return ranked
+}
+
+// Best returns the best opportunity by yield net of costs.
+func Best(opportunities []Opportunity, c Costs) (Ranked, error) {
+	if len(opportunities) == 0 {
+		return Ranked{}, errors.New("no yield opportunities")
+	}
+	return Rank(opportunities, c)[0], nil
+}
+
+// Engine compares the opportunities of several sources.
+type Engine struct {
+	Sources []Source
+	Costs   Costs
+}
+
+// Compare collects the opportunities of every source concurrently and
+// ranks them. Sources that fail are reported in the returned error, which
+// accompanies the opportunities of the rest.
+func (e *Engine) Compare(ctx context.Context) ([]Ranked, error) {
+	var (
+		mu            sync.Mutex
+		wg            sync.WaitGroup
+		opportunities []Opportunity
+		errs          []error
+	)
+	for _, s := range e.Sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found, err := s.Opportunities(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+				return
+			}
+			for i := range found {
+				found[i].Source = s.Name()
+			}
+			opportunities = append(opportunities, found...)
+		}()
+	}
+	wg.Wait()
+	return Rank(opportunities, e.Costs), errors.Join(errs...)
+}